@@ -3,6 +3,9 @@ package main
 import (
 	"log"
 
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/junkd0g/sharingan/internal/prompts"
+	"github.com/junkd0g/sharingan/internal/resources"
 	"github.com/junkd0g/sharingan/internal/tools"
 	"github.com/mark3labs/mcp-go/server"
 )
@@ -13,7 +16,13 @@ func main() {
 		"1.0.0",
 	)
 
-	tools.Register(s)
+	// One cache for the whole session, so tools, resources and prompts reuse
+	// each other's analyses
+	cache := analyzer.NewCache(analyzer.DefaultCacheDir())
+
+	tools.Register(s, cache)
+	resources.Register(s, cache)
+	prompts.Register(s, cache)
 
 	if err := server.ServeStdio(s); err != nil {
 		log.Fatalf("Server error: %v", err)
@@ -2,7 +2,10 @@ module github.com/junkd0g/sharingan
 
 go 1.24
 
-require github.com/mark3labs/mcp-go v0.17.0
+require (
+	github.com/mark3labs/mcp-go v0.17.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
@@ -1,12 +1,20 @@
 package analyzer
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/printer"
+	"go/scanner"
 	"go/token"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // ComponentType represents the type of architectural component.
@@ -17,195 +25,779 @@ const (
 	ComponentService    ComponentType = "service"
 	ComponentRepository ComponentType = "repository"
 	ComponentAdapter    ComponentType = "adapter"
+	ComponentMiddleware ComponentType = "middleware"
 )
 
 // Component represents an architectural component in the codebase.
 type Component struct {
-	Name         string
-	Type         ComponentType
-	Package      string
-	FilePath     string
-	Dependencies []string // Names of dependencies (interface field types)
+	Name         string        `json:"name"`
+	Type         ComponentType `json:"type"`
+	Package      string        `json:"package"`
+	Module       string        `json:"module,omitempty"`     // Path of the module it is in, if the tree has go.mod files
+	ImportPath   string        `json:"importPath,omitempty"` // Import path of its package, e.g. "github.com/acme/orders/internal/store"
+	FilePath     string        `json:"filePath"`
+	Line         int           `json:"line"`                   // Line of the type declaration
+	Doc          string        `json:"doc"`                    // Doc comment of the type declaration
+	Methods      []Method      `json:"methods"`                // Exported methods, in declaration order
+	Dependencies []string      `json:"dependencies"`           // Names of dependencies (interface field types)
+	Function     bool          `json:"function,omitempty"`     // Declared as a function rather than a struct
+	Calls        []Call        `json:"calls,omitempty"`        // Calls its methods make to its dependencies, in source order
+	Edges        []Edge        `json:"edges,omitempty"`        // How each dependency is held, in the same order
+	TypeParams   []string      `json:"typeParams,omitempty"`   // Type parameters of a generic component, e.g. ["T any"]
+	ElementTypes []string      `json:"elementTypes,omitempty"` // Type arguments a generic component is instantiated with
+	Generated    string        `json:"generated,omitempty"`    // Generator of the file it is declared in, e.g. "sqlc"
+
+	Classification Classification `json:"classification"` // Why it was given its type
+}
+
+// Method is an exported method of a component.
+type Method struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"` // e.g. "Create(ctx context.Context, o Order) (*Order, error)"
+	FilePath  string `json:"filePath"`
+	Line      int    `json:"line"`
 }
 
 // Architecture represents the analyzed architecture of a service.
 type Architecture struct {
-	Components   []Component
-	Dependencies map[string][]string
+	Components   []Component         `json:"components"`
+	Dependencies map[string][]string `json:"dependencies"`
+	Repository   Repository          `json:"repository"`
+	Diagnostics  []Diagnostic        `json:"diagnostics"`     // What the analysis skipped, dropped or failed on
+	Routes       []Route             `json:"routes"`          // HTTP routes with their middleware, by path
+	Modules      []Module            `json:"modules"`         // Go modules in the tree, by directory
+	Workspace    bool                `json:"workspace"`       // The modules are those a go.work lists
+	Endpoints    []Endpoint          `json:"endpoints"`       // gRPC services, URLs and topics it serves or uses, for linking services
+	Build        *BuildConfig        `json:"build,omitempty"` // The build configuration files were selected by, if any
+	Generated    []GeneratedFile     `json:"generated"`       // Files written by code generators
+	Contracts    []Contract          `json:"contracts"`       // gRPC, OpenAPI and database interfaces from generated code
+	API          APISurface          `json:"api"`             // OpenAPI operations and the routes serving them
+
+	explanations []Explanation // How every struct was treated, for Explain
 }
 
 // Analyze analyzes a Go repository and extracts its core architecture.
 // It focuses on finding real architectural components (handlers, services, repositories)
 // and their dependencies, filtering out noise like DTOs, mocks, and configs.
-func Analyze(repoPath string) (*Architecture, error) {
-	arch := &Architecture{
-		Components:   []Component{},
-		Dependencies: make(map[string][]string),
+//
+// With a build configuration, only the files whose file name and //go:build
+// constraints match it are analyzed; an empty GOOS or GOARCH is the running
+// platform's. A nil build analyzes every file.
+//
+// The analysis stops early with the context's error if ctx is cancelled.
+func Analyze(ctx context.Context, repoPath string, build *BuildConfig) (*Architecture, error) {
+	scan, err := scanRepository(ctx, repoPath, build, nil)
+	if err != nil {
+		return nil, err
 	}
+	return buildArchitecture(ctx, repoPath, scan), nil
+}
 
-	// First pass: collect all interface names defined in the codebase
-	interfaces := make(map[string]bool)
+// fileFacts is everything the analysis needs from one source file. Facts
+// depend only on the file's content, so they can be cached by content hash;
+// anything that depends on other files is worked out in buildArchitecture.
+type fileFacts struct {
+	Path       string   `json:"path"` // Relative to the repository
+	Package    string   `json:"package"`
+	Generator  string   `json:"generator,omitempty"` // Set for generated files, see generatorOf
+	Interfaces []string `json:"interfaces"`
+	// Interfaces that embed others, such as ReadWriter, to the names they embed
+	InterfaceEmbeds map[string][]string `json:"interfaceEmbeds,omitempty"`
+	// Interfaces to the methods they declare
+	InterfaceMethods map[string][]string `json:"interfaceMethods,omitempty"`
+	Calls            []callFacts         `json:"calls,omitempty"`     // Calls methods make through receiver fields
+	Funcs            []funcFacts         `json:"funcs,omitempty"`     // Functions acting as components
+	Routes           []routeFacts        `json:"routes,omitempty"`    // HTTP routes registered in the file
+	Endpoints        []Endpoint          `json:"endpoints,omitempty"` // gRPC services, URLs and topics
+	Structs          []structFacts       `json:"structs"`
+	Methods          []methodFacts       `json:"methods"` // Exported methods, in declaration order
+}
+
+// structFacts describes a struct type declaration.
+type structFacts struct {
+	Name       string      `json:"name"`
+	Line       int         `json:"line"`
+	Doc        string      `json:"doc"`
+	FieldTypes []fieldType `json:"fieldTypes"` // Distinct named field types, in field order
+
+	TypeParams []string          `json:"typeParams,omitempty"`
+	Instances  []typeInstance    `json:"instances,omitempty"` // Generic types instantiated by fields
+	Fields     map[string]string `json:"fields,omitempty"`    // Field name to the named type it holds
+
+	WrapsHandler bool `json:"wrapsHandler,omitempty"` // A field holds a handler, such as next http.Handler
+}
+
+// methodFacts is an exported method and the type it is declared on.
+type methodFacts struct {
+	Receiver string `json:"receiver"`
+	Method
+	Middleware bool `json:"middleware,omitempty"` // Wraps a handler, as Wrap(next http.Handler) http.Handler does, or returns something that does
+	ServesHTTP bool `json:"servesHTTP,omitempty"` // A ServeHTTP method with the net/http handler signature
+}
+
+// scan is the result of scanning a repository.
+type scan struct {
+	files       []fileFacts           // Facts of every parsed file, in walk order
+	entries     map[string]cacheEntry // The same facts keyed by path, for the cache
+	diagnostics []Diagnostic          // Files and directories that were skipped or failed
+	specs       []specFacts           // OpenAPI documents, in walk order
+	build       *BuildConfig          // The build configuration files were selected by, if any
+}
+
+// scanRepository returns the facts of every Go source file in the repository
+// that is part of build, in walk order. Files whose content hash matches an entry in previous reuse
+// its facts instead of being parsed again. Files that cannot be read or parsed
+// are left out and reported as diagnostics, as are skipped directories.
+//
+// Files are read and parsed once each, by a pool of workers bounded by
+// GOMAXPROCS. Results are merged in walk order, so the outcome does not depend
+// on scheduling.
+func scanRepository(ctx context.Context, repoPath string, build *BuildConfig, previous map[string]cacheEntry) (*scan, error) {
+	reportProgress(ctx, Progress{Phase: PhaseScan})
+
+	config := build.withDefaults()
+	result := &scan{entries: make(map[string]cacheEntry), build: config}
+	var paths, specPaths []string
 	err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return skipOrContinue(info, err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// Only an unreadable repository root fails the analysis
+			if path == repoPath {
+				return err
+			}
+			result.diagnostics = append(result.diagnostics, Diagnostic{
+				Kind:     DiagnosticReadError,
+				FilePath: relativePath(repoPath, path),
+				Message:  err.Error(),
+			})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
-		if !isGoSourceFile(path) {
+		if info.IsDir() {
+			if reason := skipDirReason(info.Name()); reason != "" && path != repoPath {
+				if info.Name() != ".git" {
+					result.diagnostics = append(result.diagnostics, Diagnostic{
+						Kind:     DiagnosticSkippedDirectory,
+						FilePath: relativePath(repoPath, path),
+						Message:  reason,
+					})
+				}
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		collectInterfaces(path, interfaces)
+		if isGoSourceFile(path) {
+			paths = append(paths, path)
+		} else if isSpecCandidate(relativePath(repoPath, path)) && info.Size() <= maxSpecSize {
+			specPaths = append(specPaths, path)
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Second pass: find architectural components
-	err = filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return skipOrContinue(info, err)
-		}
-		if !isGoSourceFile(path) {
-			return nil
-		}
+	// Token positions are only needed while a file's facts are extracted, but
+	// a shared FileSet keeps the workers from allocating one per file.
+	fset := token.NewFileSet()
+	results := make([]scanResult, len(paths))
+	jobs := make(chan int)
+	done := make(chan struct{}, len(paths))
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = scanFile(fset, repoPath, paths[i], previous, config)
+				done <- struct{}{}
+			}
+		}()
+	}
 
-		components := analyzeFileForComponents(path, repoPath, interfaces)
-		arch.Components = append(arch.Components, components...)
-		return nil
-	})
+	// Workers only report to done, so progress is sent from this goroutine
+	step := max(len(paths)/100, 1)
+	reportProgress(ctx, Progress{Phase: PhaseParse, Total: len(paths)})
+	completed := 0
+	collect := func() {
+		completed++
+		if completed%step == 0 || completed == len(paths) {
+			reportProgress(ctx, Progress{Phase: PhaseParse, Current: completed, Total: len(paths)})
+		}
+	}
 
-	if err != nil {
+feed:
+	for i := 0; i < len(paths); {
+		select {
+		case jobs <- i:
+			i++
+		case <-done:
+			collect()
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	for completed < len(paths) {
+		<-done
+		collect()
+	}
 
-	// Build dependency map and resolve dependencies to actual component names
-	componentNames := make(map[string]bool)
-	for _, comp := range arch.Components {
-		componentNames[comp.Name] = true
+	for _, r := range results {
+		if r.entry.Hash != "" {
+			result.entries[r.entry.Facts.Path] = r.entry
+		}
+		if r.diagnostic != nil {
+			result.diagnostics = append(result.diagnostics, *r.diagnostic)
+			continue
+		}
+		result.files = append(result.files, r.entry.Facts)
 	}
 
-	// Filter dependencies to only include known components
-	for i := range arch.Components {
-		var validDeps []string
-		for _, dep := range arch.Components[i].Dependencies {
-			if componentNames[dep] {
-				validDeps = append(validDeps, dep)
-			}
+	for _, path := range specPaths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := scanSpec(repoPath, path, previous)
+		if r.entry.Hash != "" {
+			result.entries[relativePath(repoPath, path)] = r.entry
+		}
+		if r.diagnostic != nil {
+			result.diagnostics = append(result.diagnostics, *r.diagnostic)
+		} else if r.entry.Spec != nil {
+			result.specs = append(result.specs, *r.entry.Spec)
 		}
-		arch.Components[i].Dependencies = validDeps
-		arch.Dependencies[arch.Components[i].Name] = validDeps
 	}
 
-	return arch, nil
+	return result, nil
+}
+
+// scanResult is the outcome of scanning one file: its facts, or the
+// diagnostic explaining why there are none. A file excluded by build
+// constraints keeps the facts already cached for it, for other configurations.
+type scanResult struct {
+	entry      cacheEntry
+	diagnostic *Diagnostic
 }
 
-func skipOrContinue(info os.FileInfo, err error) error {
+// scanFile reads a file and extracts its facts, unless previous already has
+// them for the file's current content. With a config, files outside the build
+// are not parsed.
+func scanFile(fset *token.FileSet, repoPath, path string, previous map[string]cacheEntry, config *BuildConfig) scanResult {
+	relPath := relativePath(repoPath, path)
+	src, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return scanResult{diagnostic: &Diagnostic{Kind: DiagnosticReadError, FilePath: relPath, Message: err.Error()}}
 	}
-	if info.IsDir() {
-		name := info.Name()
-		if name == "vendor" || name == ".git" || name == "node_modules" || name == "mock" || name == "mocks" {
-			return filepath.SkipDir
+	hash := contentHash(src)
+
+	entry, cached := previous[relPath]
+	cached = cached && entry.Hash == hash
+	if config != nil && !config.matchFile(path, src) {
+		r := scanResult{diagnostic: &Diagnostic{
+			Kind:     DiagnosticExcludedFile,
+			FilePath: relPath,
+			Message:  fmt.Sprintf("not part of the build for %s", config),
+		}}
+		if cached {
+			r.entry = entry
 		}
+		return r
+	}
+	if cached {
+		return scanResult{entry: entry}
+	}
+
+	facts, err := parseFile(fset, relPath, src)
+	if err != nil {
+		return scanResult{diagnostic: parseDiagnostic(relPath, err)}
 	}
-	return nil
+	return scanResult{entry: cacheEntry{Hash: hash, Facts: facts}}
 }
 
-func isGoSourceFile(path string) bool {
-	return strings.HasSuffix(path, ".go") &&
-		!strings.HasSuffix(path, "_test.go") &&
-		!strings.Contains(path, "/mock") &&
-		!strings.Contains(path, "_mock")
+// scanSpec reads a YAML or JSON file and extracts the spec it holds, unless
+// previous already has it for the file's current content. Files that are not
+// specs are cached too, without one, so that they are not decoded again.
+func scanSpec(repoPath, path string, previous map[string]cacheEntry) scanResult {
+	relPath := relativePath(repoPath, path)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return scanResult{diagnostic: &Diagnostic{Kind: DiagnosticReadError, FilePath: relPath, Message: err.Error()}}
+	}
+	hash := contentHash(src)
+	if entry, ok := previous[relPath]; ok && entry.Hash == hash {
+		return scanResult{entry: entry}
+	}
+
+	spec, err := parseSpec(relPath, src)
+	if err != nil {
+		return scanResult{diagnostic: &Diagnostic{Kind: DiagnosticParseError, FilePath: relPath, Message: err.Error()}}
+	}
+	return scanResult{entry: cacheEntry{Hash: hash, Spec: spec}}
 }
 
-func collectInterfaces(filePath string, interfaces map[string]bool) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, 0)
+// relativePath returns path relative to the repository, falling back to the
+// path itself if it has no relative form.
+func relativePath(repoPath, path string) string {
+	relPath, err := filepath.Rel(repoPath, path)
 	if err != nil {
-		return
+		return path
 	}
+	return relPath
+}
 
-	ast.Inspect(node, func(n ast.Node) bool {
-		if typeSpec, ok := n.(*ast.TypeSpec); ok {
-			if _, isInterface := typeSpec.Type.(*ast.InterfaceType); isInterface {
-				interfaces[typeSpec.Name.Name] = true
-			}
+// parseDiagnostic describes a parse failure by its first error.
+func parseDiagnostic(relPath string, err error) *Diagnostic {
+	d := &Diagnostic{Kind: DiagnosticParseError, FilePath: relPath, Message: err.Error()}
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		d.Line = list[0].Pos.Line
+		d.Message = list[0].Msg
+		if len(list) > 1 {
+			d.Message += fmt.Sprintf(" (and %d more errors)", len(list)-1)
 		}
-		return true
-	})
+	}
+	return d
 }
 
-func analyzeFileForComponents(filePath, repoPath string, interfaces map[string]bool) []Component {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+// parseFile extracts the facts of a source file.
+func parseFile(fset *token.FileSet, relPath string, src []byte) (fileFacts, error) {
+	node, err := parser.ParseFile(fset, relPath, src, parser.ParseComments)
 	if err != nil {
-		return nil
+		return fileFacts{}, err
 	}
 
-	relPath, _ := filepath.Rel(repoPath, filePath)
-	pkgPath := filepath.Dir(relPath)
-	var components []Component
+	facts := fileFacts{
+		Path:       relPath,
+		Package:    node.Name.Name,
+		Generator:  generatorOf(node),
+		Interfaces: []string{},
+		Structs:    []structFacts{},
+		Methods:    collectMethods(fset, node, relPath),
+		Calls:      collectCalls(fset, node),
+		Routes:     collectRoutes(fset, node),
+		Endpoints:  collectEndpoints(fset, node),
+	}
+	funcs, funcCalls := collectFunctions(fset, node)
+	facts.Funcs = funcs
+	facts.Calls = append(facts.Calls, funcCalls...)
 
+	var declDoc *ast.CommentGroup
 	ast.Inspect(node, func(n ast.Node) bool {
-		typeSpec, ok := n.(*ast.TypeSpec)
-		if !ok {
+		if genDecl, ok := n.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+			// A lone "type X struct" keeps its doc comment on the declaration
+			declDoc = nil
+			if len(genDecl.Specs) == 1 {
+				declDoc = genDecl.Doc
+			}
 			return true
 		}
 
-		structType, ok := typeSpec.Type.(*ast.StructType)
+		typeSpec, ok := n.(*ast.TypeSpec)
 		if !ok {
 			return true
 		}
 
-		name := typeSpec.Name.Name
+		switch t := typeSpec.Type.(type) {
+		case *ast.InterfaceType:
+			facts.Interfaces = append(facts.Interfaces, typeSpec.Name.Name)
+			if methods := interfaceMethods(t); len(methods) > 0 {
+				if facts.InterfaceMethods == nil {
+					facts.InterfaceMethods = make(map[string][]string)
+				}
+				facts.InterfaceMethods[typeSpec.Name.Name] = methods
+			}
+			if embeds := embeddedInterfaces(t); len(embeds) > 0 {
+				if facts.InterfaceEmbeds == nil {
+					facts.InterfaceEmbeds = make(map[string][]string)
+				}
+				facts.InterfaceEmbeds[typeSpec.Name.Name] = embeds
+			}
+		case *ast.StructType:
+			doc := typeSpec.Doc
+			if doc == nil {
+				doc = declDoc
+			}
+			params := typeParams(fset, typeSpec.TypeParams)
+			paramNames := typeParamNames(params)
+			facts.Structs = append(facts.Structs, structFacts{
+				Name:       typeSpec.Name.Name,
+				Line:       fset.Position(typeSpec.Pos()).Line,
+				Doc:        strings.TrimSpace(doc.Text()),
+				FieldTypes: fieldTypes(t, paramNames),
+				Fields:     fieldNames(t),
+				TypeParams: params,
+				Instances:  typeInstances(t, paramNames),
+
+				WrapsHandler: wrapsHandler(t),
+			})
+		}
+		return true
+	})
+
+	return facts, nil
+}
+
+// buildArchitecture turns the facts of every file into components and their
+// dependencies.
+func buildArchitecture(ctx context.Context, repoPath string, scan *scan) *Architecture {
+	reportProgress(ctx, Progress{Phase: PhaseBuild})
+
+	arch := &Architecture{
+		Components:   []Component{},
+		Dependencies: make(map[string][]string),
+		Diagnostics:  append([]Diagnostic{}, scan.diagnostics...),
+		Build:        scan.build,
+	}
+	files := scan.files
+
+	// Interfaces and structs from the whole codebase decide which fields are
+	// dependencies, and what embedded structs contribute
+	types := newTypeIndex(files)
+	methods := make(map[string][]Method)
+	calls := make(map[string][]fileCall)
+	for _, file := range files {
+		for _, m := range file.Methods {
+			key := methodKey(filepath.Dir(file.Path), m.Receiver)
+			methods[key] = append(methods[key], m.Method)
+		}
+		for _, c := range file.Calls {
+			key := methodKey(filepath.Dir(file.Path), c.Receiver)
+			calls[key] = append(calls[key], fileCall{callFacts: c, path: file.Path})
+		}
+	}
+
+	for _, file := range files {
+		components, excluded, explanations := componentsFromFacts(file, types)
+		for i := range components {
+			components[i].Generated = file.Generator
+		}
+		arch.Components = append(arch.Components, components...)
+		arch.Diagnostics = append(arch.Diagnostics, excluded...)
+		arch.explanations = append(arch.explanations, explanations...)
+	}
+
+	elements := elementTypes(files)
+	for i := range arch.Components {
+		comp := &arch.Components[i]
+		comp.Methods = methods[methodKey(filepath.Dir(comp.FilePath), comp.Name)]
+		if comp.Methods == nil {
+			comp.Methods = []Method{}
+		}
+		if len(comp.TypeParams) > 0 {
+			comp.ElementTypes = elements[comp.Name]
+		}
+	}
+
+	arch.Repository = detectRepository(repoPath)
+	arch.Modules, arch.Workspace = detectModules(repoPath)
+	if arch.Modules == nil {
+		arch.Modules = []Module{}
+	}
+	// Modules declaring a component of each name, in walk order. In a
+	// workspace several modules can declare a Service or a Handler.
+	modulesByName := make(map[string][]string)
+	for i := range arch.Components {
+		comp := &arch.Components[i]
+		dir := filepath.ToSlash(filepath.Dir(comp.FilePath))
+		if mod, ok := moduleOf(arch.Modules, dir); ok {
+			comp.Module, comp.ImportPath = mod.Path, importPath(mod, dir)
+		}
+		if !slices.Contains(modulesByName[comp.Name], comp.Module) {
+			modulesByName[comp.Name] = append(modulesByName[comp.Name], comp.Module)
+		}
+	}
+	// targetModule returns the module of the component a dependency of a
+	// component in from resolves to. Dependencies are known by name only, so
+	// one in the component's own module wins, as an unqualified type would.
+	targetModule := func(from, name string) string {
+		modules := modulesByName[name]
+		if len(modules) == 0 || slices.Contains(modules, from) {
+			return from
+		}
+		return modules[0]
+	}
+
+	// Build dependency map and resolve dependencies to actual component names
+	componentNames := make(map[string]bool)
+	for _, comp := range arch.Components {
+		componentNames[comp.Name] = true
+	}
+
+	// Filter dependencies to only include known components
+	dropped := make(map[string][]string)
+	for i := range arch.Components {
+		comp := &arch.Components[i]
+		validDeps := []string{}
+		var validEdges []Edge
+		seen := make(map[string]bool)
+		keep := func(e Edge) {
+			if !seen[e.Target] {
+				seen[e.Target] = true
+				validDeps = append(validDeps, e.Target)
+				validEdges = append(validEdges, e)
+			}
+		}
+		for _, edge := range comp.Edges {
+			if componentNames[edge.Target] {
+				keep(edge)
+				continue
+			}
+
+			// A composite interface stands for the interfaces it embeds
+			if constituents := types.constituents(edge.Target, componentNames, make(map[string]bool)); len(constituents) > 0 {
+				for _, target := range constituents {
+					resolved := edge
+					resolved.Target = target
+					if resolved.Via == "" {
+						resolved.Via = edge.Target
+					}
+					keep(resolved)
+				}
+				continue
+			}
+
+			arch.Diagnostics = append(arch.Diagnostics, unresolvedDependency(*comp, edge.Target, types.interfaces))
+			dropped[comp.FilePath+"."+comp.Name] = append(dropped[comp.FilePath+"."+comp.Name], edge.Target)
+		}
+		for j := range validEdges {
+			if target := targetModule(comp.Module, validEdges[j].Target); target != comp.Module {
+				validEdges[j].CrossModule, validEdges[j].TargetModule = true, target
+			}
+		}
+		comp.Dependencies = validDeps
+		comp.Edges = validEdges
+		arch.Dependencies[comp.Name] = validDeps
+	}
+	// With the dependencies known, calls through fields can be attributed
+	for i := range arch.Components {
+		comp := &arch.Components[i]
+		dir := filepath.Dir(comp.FilePath)
+		if comp.Function {
+			resolveCalls(comp, types.functions[methodKey(dir, comp.Name)], calls[methodKey(dir, comp.Name)], types, componentNames)
+		} else if st, ok := types.lookupStruct(dir, comp.Name); ok {
+			resolveCalls(comp, st, calls[methodKey(dir, comp.Name)], types, componentNames)
+		}
+	}
+
+	// Functions applied as middleware are middleware, whatever their signature
+	middleware := usedAsMiddleware(files)
+	for i := range arch.Components {
+		comp := &arch.Components[i]
+		if comp.Function && comp.Type != ComponentMiddleware && middleware[comp.Name] {
+			comp.Type = ComponentMiddleware
+			comp.Classification.Rule = "middleware-func"
+			comp.Classification.Reason = "function applied as middleware to routes"
+		}
+		if comp.Type == ComponentMiddleware {
+			middleware[comp.Name] = true
+		}
+	}
+	arch.Routes = resolveRoutes(files, middleware, componentNames)
+	arch.Generated = []GeneratedFile{}
+	for _, file := range files {
+		if file.Generator != "" {
+			arch.Generated = append(arch.Generated, GeneratedFile{Path: file.Path, Generator: file.Generator})
+		}
+	}
+	arch.Contracts = collectContracts(files, arch.Components, types)
+	specs := []APISpec{}
+	operations := []APIOperation{}
+	for _, s := range scan.specs {
+		specs = append(specs, s.Spec)
+		operations = append(operations, s.Operations...)
+	}
+	arch.API = correlateAPI(specs, operations, arch.Routes, arch.Contracts)
+	arch.Endpoints = []Endpoint{}
+	for _, file := range files {
+		for _, e := range file.Endpoints {
+			e.FilePath = file.Path
+			arch.Endpoints = append(arch.Endpoints, e)
+		}
+	}
+
+	byKey := make(map[string]Component, len(arch.Components))
+	for _, comp := range arch.Components {
+		byKey[comp.FilePath+"."+comp.Name] = comp
+	}
+	for i := range arch.explanations {
+		e := &arch.explanations[i]
+		e.Dropped = dropped[e.FilePath+"."+e.Name]
+		if comp, ok := byKey[e.FilePath+"."+e.Name]; ok && comp.Type != e.Type && e.Component {
+			e.Type = comp.Type
+			e.Classification = &comp.Classification
+			e.Summary = fmt.Sprintf("%s, by the %s rule: %s", comp.Type, comp.Classification.Rule, comp.Classification.Reason)
+			e.Checks = []Check{{Rule: comp.Classification.Rule, Matched: true, Detail: comp.Classification.Reason}}
+		}
+	}
+
+	sortDiagnostics(arch.Diagnostics)
+
+	return arch
+}
+
+// skipDirReason returns why a directory is not analyzed, or "" if it is.
+func skipDirReason(name string) string {
+	switch name {
+	case "vendor":
+		return "vendored dependencies"
+	case ".git":
+		return "git metadata"
+	case "node_modules":
+		return "JavaScript dependencies"
+	case "mock", "mocks":
+		return "generated mocks"
+	}
+	return ""
+}
+
+func isGoSourceFile(path string) bool {
+	return strings.HasSuffix(path, ".go") &&
+		!strings.HasSuffix(path, "_test.go") &&
+		!strings.Contains(path, "/mock") &&
+		!strings.Contains(path, "_mock")
+}
+
+// componentsFromFacts returns the components declared in a file, diagnostics
+// for the structs excluded as noise and an explanation for every struct.
+func componentsFromFacts(file fileFacts, types *typeIndex) ([]Component, []Diagnostic, []Explanation) {
+	pkgPath := filepath.Dir(file.Path)
+	var components []Component
+	var excluded []Diagnostic
+	var explanations []Explanation
+
+	for _, st := range file.Structs {
+		explanation := Explanation{Name: st.Name, Package: file.Package, FilePath: file.Path, Line: st.Line}
 
 		// Skip noise: mocks, DTOs, configs, internal types
-		if shouldSkipStruct(name) {
-			return true
+		if skip, reason := shouldSkipStruct(st.Name); skip {
+			excluded = append(excluded, Diagnostic{
+				Kind:      DiagnosticExcludedStruct,
+				FilePath:  file.Path,
+				Line:      st.Line,
+				Component: st.Name,
+				Message:   fmt.Sprintf("struct %s was excluded: %s", st.Name, reason),
+			})
+			explanation.Excluded = reason
+			explanation.Summary = "excluded as noise by shouldSkipStruct: " + reason
+			explanations = append(explanations, explanation)
+			continue
 		}
 
-		// Extract interface-typed fields (these are the dependencies)
-		deps := extractInterfaceDependencies(structType, interfaces)
+		// Extract interface-typed fields (these are the dependencies), and
+		// those inherited from embedded structs
+		edges, fields := types.dependencies(pkgPath, st, make(map[string]bool))
+		explanation.Fields = fields
+		var deps []string
+		for _, e := range edges {
+			deps = append(deps, e.Target)
+		}
 
 		// Determine component type based on package path and struct characteristics
-		compType := detectComponentTypeFromContext(pkgPath, name, deps)
+		compType, classification, checks := detectComponentTypeFromContext(pkgPath, st.Name, deps, types.middlewareShape(pkgPath, st))
+		explanation.Checks = checks
 
 		// Only include if it's a real architectural component
 		if compType == "" {
-			return true
+			explanation.Summary = "not a component: no classification heuristic matched"
+			explanations = append(explanations, explanation)
+			continue
 		}
 
+		explanation.Component = true
+		explanation.Type = compType
+		explanation.Classification = &classification
+		explanation.Summary = fmt.Sprintf("%s, by the %s rule: %s", compType, classification.Rule, classification.Reason)
+		explanations = append(explanations, explanation)
+
 		components = append(components, Component{
-			Name:         name,
-			Type:         compType,
-			Package:      node.Name.Name,
-			FilePath:     relPath,
-			Dependencies: deps,
+			Name:           st.Name,
+			Type:           compType,
+			Package:        file.Package,
+			FilePath:       file.Path,
+			Line:           st.Line,
+			Doc:            st.Doc,
+			Dependencies:   deps,
+			Edges:          edges,
+			TypeParams:     st.TypeParams,
+			Classification: classification,
 		})
+	}
 
-		return true
-	})
+	for _, fn := range file.Funcs {
+		component, explanation := functionComponent(file, fn, types)
+		components = append(components, component)
+		explanations = append(explanations, explanation)
+	}
+
+	return components, excluded, explanations
+}
+
+// collectMethods returns the exported methods declared in a file.
+func collectMethods(fset *token.FileSet, node *ast.File, relPath string) []methodFacts {
+	methods := []methodFacts{}
+	for _, decl := range node.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 || !funcDecl.Name.IsExported() {
+			continue
+		}
 
-	return components
+		receiver := extractTypeName(funcDecl.Recv.List[0].Type)
+		if receiver == "" {
+			continue
+		}
+
+		var sig bytes.Buffer
+		if err := printer.Fprint(&sig, fset, funcDecl.Type); err != nil {
+			continue
+		}
+
+		methods = append(methods, methodFacts{
+			Receiver: receiver,
+			Method: Method{
+				Name:      funcDecl.Name.Name,
+				Signature: funcDecl.Name.Name + strings.TrimPrefix(sig.String(), "func"),
+				FilePath:  relPath,
+				Line:      fset.Position(funcDecl.Pos()).Line,
+			},
+			Middleware: middlewareSignature(funcDecl.Type) != "" || middlewareResult(funcDecl.Type) != "",
+			ServesHTTP: funcDecl.Name.Name == "ServeHTTP" && handlerSignature(funcDecl.Type) == "net/http",
+		})
+	}
+	return methods
+}
+
+func methodKey(dir, typeName string) string {
+	return dir + "." + typeName
 }
 
-func shouldSkipStruct(name string) bool {
+// shouldSkipStruct reports whether a struct is noise rather than an
+// architectural component, and why.
+func shouldSkipStruct(name string) (bool, string) {
 	lower := strings.ToLower(name)
 
 	// Skip mocks
 	if strings.Contains(lower, "mock") {
-		return true
+		return true, `mock: name contains "mock"`
 	}
 
 	// Skip DTOs (Request/Response structs)
-	if strings.HasSuffix(name, "Request") || strings.HasSuffix(name, "Response") {
-		return true
+	for _, suffix := range []string{"Request", "Response"} {
+		if strings.HasSuffix(name, suffix) {
+			return true, fmt.Sprintf("DTO: name ends in %q", suffix)
+		}
 	}
 
 	// Skip config structs (they're not architectural components)
-	if strings.HasSuffix(name, "Config") || strings.HasSuffix(name, "Conf") ||
-		strings.Contains(lower, "config") || name == "Config" {
-		return true
+	if strings.HasSuffix(name, "Conf") || strings.Contains(lower, "config") {
+		return true, `configuration: name contains "config" or ends in "Conf"`
 	}
 
 	// Skip common non-architectural types
@@ -213,43 +805,47 @@ func shouldSkipStruct(name string) bool {
 		"Structure", "Content", "Template", "Section", "Message", "Event", "Item", "Entry"}
 	for _, suffix := range skipSuffixes {
 		if strings.HasSuffix(name, suffix) {
-			return true
+			return true, fmt.Sprintf("value type: name ends in %q", suffix)
 		}
 	}
 
 	// Skip if name is too short (likely internal)
 	if len(name) <= 2 && name != "DB" {
-		return true
+		return true, "name is two characters or fewer"
 	}
 
 	// Skip unexported types
 	if name[0] >= 'a' && name[0] <= 'z' {
-		return true
+		return true, "unexported"
 	}
 
-	return false
+	return false, ""
 }
 
-func extractInterfaceDependencies(structType *ast.StructType, interfaces map[string]bool) []string {
-	var deps []string
-	if structType.Fields == nil {
-		return deps
-	}
-
-	seen := make(map[string]bool)
-	for _, field := range structType.Fields.List {
-		typeName := extractTypeName(field.Type)
-		if typeName == "" || seen[typeName] {
-			continue
+// extractInterfaceDependencies returns the field types that are dependencies,
+// with the reason each field type was or was not counted.
+func extractInterfaceDependencies(fieldTypes []fieldType, interfaces map[string]bool) ([]Edge, []FieldEvidence) {
+	var deps []Edge
+	var fields []FieldEvidence
+	for _, ft := range fieldTypes {
+		typeName := ft.Name
+		// Include if it's a known interface or looks like a dependency
+		field := FieldEvidence{Type: typeName, Kind: ft.Kind, Direction: ft.Direction, Dependency: true}
+		if interfaces[typeName] {
+			field.Reason = "interface declared in the repository"
+		} else if ok, pattern := looksLikeDependency(typeName); ok {
+			field.Reason = fmt.Sprintf("name contains %q", pattern)
+		} else {
+			field.Dependency = false
+			field.Reason = "not an interface declared in the repository, and the name matches no dependency pattern"
 		}
 
-		// Include if it's a known interface or looks like a dependency
-		if interfaces[typeName] || looksLikeDependency(typeName) {
-			deps = append(deps, typeName)
-			seen[typeName] = true
+		if field.Dependency {
+			deps = append(deps, Edge{Target: typeName, Kind: ft.Kind, Direction: ft.Direction})
 		}
+		fields = append(fields, field)
 	}
-	return deps
+	return deps, fields
 }
 
 func extractTypeName(expr ast.Expr) string {
@@ -260,12 +856,18 @@ func extractTypeName(expr ast.Expr) string {
 		return extractTypeName(t.X)
 	case *ast.SelectorExpr:
 		return t.Sel.Name
+	case *ast.IndexExpr: // Repository[User]
+		return extractTypeName(t.X)
+	case *ast.IndexListExpr: // Cache[string, Item]
+		return extractTypeName(t.X)
 	default:
 		return ""
 	}
 }
 
-func looksLikeDependency(name string) bool {
+// looksLikeDependency reports whether a type name suggests a dependency, and
+// the pattern it matched.
+func looksLikeDependency(name string) (bool, string) {
 	lower := strings.ToLower(name)
 	dependencyPatterns := []string{
 		"service", "store", "repo", "repository",
@@ -275,54 +877,8 @@ func looksLikeDependency(name string) bool {
 	}
 	for _, pattern := range dependencyPatterns {
 		if strings.Contains(lower, pattern) {
-			return true
-		}
-	}
-	return false
-}
-
-func detectComponentTypeFromContext(pkgPath, structName string, deps []string) ComponentType {
-	lower := strings.ToLower(pkgPath)
-	nameLower := strings.ToLower(structName)
-
-	// Handler/Transport layer
-	if strings.Contains(lower, "transport") || strings.Contains(lower, "http") ||
-		strings.Contains(lower, "handler") || strings.Contains(lower, "api") ||
-		strings.Contains(nameLower, "server") || strings.Contains(nameLower, "handler") {
-		if len(deps) > 0 { // Handlers should have dependencies
-			return ComponentHandler
-		}
-	}
-
-	// Repository/Persistence layer (check before service)
-	// But not if it's in a config package
-	if !strings.Contains(lower, "config") {
-		if strings.Contains(lower, "persistence") || strings.Contains(lower, "repository") ||
-			strings.Contains(lower, "repo") || strings.Contains(lower, "store") ||
-			structName == "DB" || strings.HasSuffix(structName, "Repository") ||
-			strings.HasSuffix(structName, "Store") {
-			return ComponentRepository
-		}
-	}
-
-	// Adapter layer
-	if strings.Contains(lower, "adapter") || strings.Contains(lower, "client") ||
-		strings.Contains(lower, "external") || strings.Contains(lower, "integration") {
-		return ComponentAdapter
-	}
-
-	// Service layer
-	if strings.Contains(lower, "service") || strings.Contains(lower, "usecase") ||
-		structName == "Service" || strings.HasSuffix(structName, "Service") {
-		if len(deps) > 0 { // Services should have dependencies
-			return ComponentService
+			return true, pattern
 		}
 	}
-
-	// If it has multiple dependencies, it's likely a service
-	if len(deps) >= 2 {
-		return ComponentService
-	}
-
-	return "" // Not an architectural component
+	return false, ""
 }
@@ -0,0 +1,1630 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeRepo creates a repository from a map of slash-separated paths to file contents.
+func writeRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return root
+}
+
+const orderServiceSource = `package service
+
+import "context"
+
+type OrderRepository interface {
+	Save(ctx context.Context, id string) error
+}
+
+// OrderService places and tracks orders.
+type OrderService struct {
+	repo OrderRepository
+}
+
+// Place stores a new order.
+func (s *OrderService) Place(ctx context.Context, id string) error {
+	return s.repo.Save(ctx, id)
+}
+
+func (s *OrderService) validate(id string) bool { return id != "" }
+`
+
+const orderStoreSource = `package store
+
+import "context"
+
+type OrderStore struct{}
+
+func (s OrderStore) Save(ctx context.Context, id string) error { return nil }
+`
+
+func TestAnalyzeSourceDetails(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/service/order.go": orderServiceSource,
+		"internal/store/order.go":   orderStoreSource,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	service := arch.Component("OrderService")
+	if service == nil {
+		t.Fatal("OrderService not found")
+	}
+	if service.Type != ComponentService {
+		t.Errorf("OrderService type = %s, want %s", service.Type, ComponentService)
+	}
+	if service.Line != 10 {
+		t.Errorf("OrderService line = %d, want 10", service.Line)
+	}
+	if service.Doc != "OrderService places and tracks orders." {
+		t.Errorf("OrderService doc = %q", service.Doc)
+	}
+	if len(service.Methods) != 1 {
+		t.Fatalf("OrderService has %d methods, want 1 exported method", len(service.Methods))
+	}
+	if got := service.Methods[0].Signature; got != "Place(ctx context.Context, id string) error" {
+		t.Errorf("Place signature = %q", got)
+	}
+
+	store := arch.Component("OrderStore")
+	if store == nil {
+		t.Fatal("OrderStore not found")
+	}
+	if len(store.Methods) != 1 || store.Methods[0].Line != 7 {
+		t.Errorf("OrderStore methods = %+v", store.Methods)
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	arch := &Architecture{
+		Components: []Component{
+			{Name: "OrderHandler", Type: ComponentHandler, Dependencies: []string{"OrderService", "OrderRepository"}},
+			{Name: "OrderService", Type: ComponentService, Dependencies: []string{"OrderRepository", "BillingService"}},
+			{Name: "BillingService", Type: ComponentService, Dependencies: []string{"OrderService"}},
+			{Name: "OrderRepository", Type: ComponentRepository, Dependencies: []string{"OrderHandler"}},
+		},
+	}
+
+	got := make(map[string]bool)
+	for _, v := range CheckRules(arch) {
+		got[v.Rule+":"+v.Component+":"+v.Target] = true
+	}
+
+	for _, want := range []string{
+		RuleLayering + ":OrderRepository:OrderHandler",
+		RuleSkipServiceTier + ":OrderHandler:OrderRepository",
+		RuleDependencyCycle + ":BillingService:",
+	} {
+		if !got[want] {
+			t.Errorf("missing violation %s, got %v", want, got)
+		}
+	}
+}
+
+func TestGraphQueries(t *testing.T) {
+	arch := &Architecture{
+		Components: []Component{
+			{Name: "OrderHandler", Type: ComponentHandler, Dependencies: []string{"OrderService", "PaymentService"}},
+			{Name: "OrderService", Type: ComponentService, Dependencies: []string{"PaymentService", "OrderRepository"}},
+			{Name: "PaymentService", Type: ComponentService, Dependencies: []string{"PaymentRepository"}},
+			{Name: "OrderRepository", Type: ComponentRepository, Dependencies: []string{}},
+			{Name: "PaymentRepository", Type: ComponentRepository, Dependencies: []string{}},
+		},
+	}
+
+	dependents := arch.Reachable("PaymentRepository", 0, true)
+	want := []Neighbor{{"PaymentService", 1}, {"OrderHandler", 2}, {"OrderService", 2}}
+	if !reflect.DeepEqual(dependents, want) {
+		t.Errorf("dependents of PaymentRepository = %v, want %v", dependents, want)
+	}
+
+	direct := arch.Reachable("OrderHandler", 1, false)
+	want = []Neighbor{{"OrderService", 1}, {"PaymentService", 1}}
+	if !reflect.DeepEqual(direct, want) {
+		t.Errorf("direct dependencies of OrderHandler = %v, want %v", direct, want)
+	}
+
+	paths, err := arch.Paths(t.Context(), "OrderHandler", "PaymentRepository", 0)
+	if err != nil {
+		t.Fatalf("Paths: %v", err)
+	}
+	wantPaths := [][]string{
+		{"OrderHandler", "PaymentService", "PaymentRepository"},
+		{"OrderHandler", "OrderService", "PaymentService", "PaymentRepository"},
+	}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("paths = %v, want %v", paths, wantPaths)
+	}
+
+	// A limit keeps the shortest paths, not the first ones found in name order
+	if paths, _ := arch.Paths(t.Context(), "OrderHandler", "PaymentRepository", 1); !reflect.DeepEqual(paths, wantPaths[:1]) {
+		t.Errorf("paths with limit 1 = %v, want %v", paths, wantPaths[:1])
+	}
+
+	if paths, _ := arch.Paths(t.Context(), "PaymentRepository", "OrderHandler", 0); len(paths) != 0 {
+		t.Errorf("paths against the dependency direction = %v, want none", paths)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := arch.Paths(ctx, "OrderHandler", "PaymentRepository", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Paths with a cancelled context: err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestImpact(t *testing.T) {
+	arch := &Architecture{
+		Components: []Component{
+			{Name: "OrderHandler", Type: ComponentHandler, FilePath: "http/order.go", Dependencies: []string{"OrderService"},
+				Methods: []Method{{Name: "Create", FilePath: "http/order.go"}}},
+			{Name: "AdminHandler", Type: ComponentHandler, FilePath: "http/admin.go", Dependencies: []string{"OrderRepository"}},
+			{Name: "OrderService", Type: ComponentService, FilePath: "service/order.go", Dependencies: []string{"OrderRepository"}},
+			{Name: "OrderRepository", Type: ComponentRepository, FilePath: "store/order.go", Dependencies: []string{},
+				Methods: []Method{{Name: "Save", FilePath: "store/order_save.go"}}},
+		},
+	}
+
+	diff := `diff --git a/store/order_save.go b/store/order_save.go
+--- a/store/order_save.go
++++ b/store/order_save.go
+@@ -1 +1 @@
+-package store
++package store // changed
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+`
+	files := DiffFiles(diff)
+	if !reflect.DeepEqual(files, []string{"store/order_save.go", "README.md"}) {
+		t.Fatalf("DiffFiles = %v", files)
+	}
+
+	impact, err := arch.Impact(files, nil)
+	if err != nil {
+		t.Fatalf("Impact failed: %v", err)
+	}
+
+	if len(impact.Changed) != 1 || impact.Changed[0].Name != "OrderRepository" || impact.Changed[0].Match != MatchMethod {
+		t.Errorf("changed = %+v, want OrderRepository matched by method", impact.Changed)
+	}
+	if !reflect.DeepEqual(impact.Unmatched, []string{"README.md"}) {
+		t.Errorf("unmatched = %v", impact.Unmatched)
+	}
+
+	var entryPoints []string
+	for _, entry := range impact.EntryPoints {
+		entryPoints = append(entryPoints, fmt.Sprintf("%s:%d", entry.Name, entry.Distance))
+	}
+	if !reflect.DeepEqual(entryPoints, []string{"AdminHandler:1", "OrderHandler:2"}) {
+		t.Errorf("entry points = %v", entryPoints)
+	}
+	if got := impact.EntryPoints[1].Path; !reflect.DeepEqual(got, []string{"OrderHandler", "OrderService", "OrderRepository"}) {
+		t.Errorf("OrderHandler path = %v", got)
+	}
+	if len(impact.Packages) != 3 || impact.Packages[0].Path != "store" {
+		t.Errorf("packages = %+v", impact.Packages)
+	}
+
+	if _, err := arch.Impact(nil, []string{"Missing"}); err == nil {
+		t.Error("expected an error for an unknown component")
+	}
+}
+
+func TestCache(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/service/order.go": orderServiceSource,
+		"internal/store/order.go":   orderStoreSource,
+	})
+	dir := t.TempDir()
+
+	want, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	got, err := NewCache(dir).Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze through the cache: %v", err)
+	}
+	if !reflect.DeepEqual(got.Components, want.Components) {
+		t.Errorf("cached components = %+v, want %+v", got.Components, want.Components)
+	}
+
+	// A new cache, as after a restart, starts from the entries on disk
+	restarted := NewCache(dir)
+	absRepo, _ := filepath.Abs(repo)
+	if entries := restarted.load(absRepo); len(entries) != 2 {
+		t.Fatalf("on-disk cache has %d files, want 2", len(entries))
+	}
+
+	changedSource := strings.Replace(orderStoreSource, "type OrderStore struct{}", "// OrderStore persists orders.\ntype OrderStore struct{}", 1)
+	if err := os.WriteFile(filepath.Join(repo, "internal/store/order.go"), []byte(changedSource), 0644); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+
+	got, err = restarted.Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to re-analyze: %v", err)
+	}
+	if store := got.Component("OrderStore"); store == nil || store.Doc != "OrderStore persists orders." {
+		t.Errorf("OrderStore after change = %+v, want the new doc comment", store)
+	}
+}
+
+func TestAnalyzeProgressAndCancellation(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/service/order.go": orderServiceSource,
+		"internal/store/order.go":   orderStoreSource,
+	})
+
+	var phases []string
+	var last Progress
+	ctx := WithProgress(t.Context(), func(p Progress) {
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+		last = p
+	})
+	if _, err := Analyze(ctx, repo, nil); err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if !reflect.DeepEqual(phases, []string{PhaseScan, PhaseParse, PhaseBuild}) {
+		t.Errorf("phases = %v", phases)
+	}
+	if last.Phase != PhaseBuild {
+		t.Errorf("last progress = %+v, want the build phase", last)
+	}
+
+	cancelled, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := NewCache("").Analyze(cancelled, repo, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Analyze with a cancelled context returned %v, want context.Canceled", err)
+	}
+}
+
+// manyPackagesRepo writes a repository of n service packages, each depending
+// on the next one's interface, with an unparseable file every tenth package.
+func manyPackagesRepo(t *testing.T, n int) string {
+	t.Helper()
+	files := make(map[string]string, n)
+	for i := range n {
+		files[fmt.Sprintf("internal/svc%03d/service.go", i)] = fmt.Sprintf(`package svc%03d
+
+type Next%03d interface {
+	Do() error
+}
+
+// Service%03d does step %d.
+type Service%03d struct {
+	next Next%03d
+}
+
+func (s *Service%03d) Do() error { return s.next.Do() }
+`, i, i+1, i, i, i, i+1, i)
+		if i%10 == 0 {
+			files[fmt.Sprintf("internal/svc%03d/broken.go", i)] = "package svc\n\nfunc {"
+		}
+	}
+	return writeRepo(t, files)
+}
+
+func TestAnalyzeWorkerCountDoesNotChangeResult(t *testing.T) {
+	repo := manyPackagesRepo(t, 60)
+
+	analyzeWith := func(procs int) []byte {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+		arch, err := Analyze(t.Context(), repo, nil)
+		if err != nil {
+			t.Fatalf("Failed to analyze with GOMAXPROCS %d: %v", procs, err)
+		}
+		out, err := json.Marshal(arch)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		return out
+	}
+
+	serial := analyzeWith(1)
+	for _, procs := range []int{2, 8} {
+		if parallel := analyzeWith(procs); !bytes.Equal(serial, parallel) {
+			t.Errorf("analysis with GOMAXPROCS %d differs from GOMAXPROCS 1", procs)
+		}
+	}
+}
+
+func TestAnalyzeCancelledWhileParsing(t *testing.T) {
+	repo := manyPackagesRepo(t, 400)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	parsed := 0
+	ctx = WithProgress(ctx, func(p Progress) {
+		// Cancel once the workers have started, with most files still to do
+		if p.Phase == PhaseParse && p.Current > 0 {
+			parsed = p.Current
+			cancel()
+		}
+	})
+	if _, err := Analyze(ctx, repo, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Analyze cancelled while parsing returned %v, want context.Canceled", err)
+	}
+	if parsed == 0 || parsed >= 400 {
+		t.Fatalf("cancelled after %d of 400 files, want part way through", parsed)
+	}
+
+	// Workers are waited for before Analyze returns, so none may be left
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d after a cancelled analysis, want at most %d", after, before)
+	}
+}
+
+func TestAnalyzeDiagnostics(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/service/order.go":     orderServiceSource,
+		"internal/service/dto.go":       "package service\n\ntype PlaceOrderRequest struct{ ID string }\n",
+		"internal/service/broken.go":    "package service\n\nfunc {\n",
+		"vendor/example.com/lib/lib.go": "package lib\n",
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	find := func(kind DiagnosticKind) *Diagnostic {
+		for i := range arch.Diagnostics {
+			if arch.Diagnostics[i].Kind == kind {
+				return &arch.Diagnostics[i]
+			}
+		}
+		t.Errorf("no %s diagnostic in %+v", kind, arch.Diagnostics)
+		return &Diagnostic{}
+	}
+
+	if d := find(DiagnosticParseError); d.FilePath != filepath.Join("internal", "service", "broken.go") || d.Line != 3 {
+		t.Errorf("parse error = %+v", d)
+	}
+	if d := find(DiagnosticSkippedDirectory); d.FilePath != "vendor" {
+		t.Errorf("skipped directory = %+v", d)
+	}
+	if d := find(DiagnosticExcludedStruct); d.Component != "PlaceOrderRequest" || !strings.Contains(d.Message, `"Request"`) {
+		t.Errorf("excluded struct = %+v", d)
+	}
+	if d := find(DiagnosticUnresolvedDependency); d.Component != "OrderService" || d.Target != "OrderRepository" {
+		t.Errorf("unresolved dependency = %+v", d)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/httpapi/order.go": "package httpapi\n\ntype OrderHandler struct {\n\tsvc   *OrderService\n\tlimit int\n}\n\ntype OrderService struct{}\n",
+		"internal/service/dto.go":   "package service\n\ntype PlaceOrderRequest struct{ ID string }\n\ntype Clock struct{}\n",
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	handler := arch.Component("OrderHandler")
+	if handler == nil {
+		t.Fatal("OrderHandler not found")
+	}
+	want := Classification{Rule: "handler", PackageKeyword: "http", PackageSegment: "httpapi", Dependencies: 1, MinDependencies: 1,
+		Reason: `package path segment "httpapi" contains "http", with 1 dependency`}
+	if handler.Classification != want {
+		t.Errorf("classification = %+v, want %+v", handler.Classification, want)
+	}
+
+	explanations := arch.Explain("OrderHandler")
+	if len(explanations) != 1 || !explanations[0].Component {
+		t.Fatalf("explanations = %+v", explanations)
+	}
+	if fields := explanations[0].Fields; len(fields) != 2 || !fields[0].Dependency || fields[1].Dependency {
+		t.Errorf("fields = %+v", fields)
+	}
+	if dropped := explanations[0].Dropped; len(dropped) != 1 || dropped[0] != "OrderService" {
+		t.Errorf("dropped = %v, want [OrderService]", dropped)
+	}
+
+	if e := arch.Explain("PlaceOrderRequest"); len(e) != 1 || e[0].Component || !strings.Contains(e[0].Excluded, `"Request"`) {
+		t.Errorf("PlaceOrderRequest explanation = %+v", e)
+	}
+
+	e := arch.Explain("Clock")
+	if len(e) != 1 || e[0].Component || e[0].Excluded != "" {
+		t.Fatalf("Clock explanation = %+v", e)
+	}
+	var rules []string
+	for _, check := range e[0].Checks {
+		if check.Matched {
+			t.Errorf("check %+v matched", check)
+		}
+		rules = append(rules, check.Rule)
+	}
+	if got := strings.Join(rules, ","); got != "middleware,handler,repository,adapter,service,service-fallback" {
+		t.Errorf("rules tried = %s", got)
+	}
+
+	if arch.Explain("Missing") != nil {
+		t.Error("Explain found a struct that does not exist")
+	}
+}
+
+func TestAnalyzeGenerics(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/store/store.go": `package store
+
+import "context"
+
+type Entity interface{ ID() string }
+
+type Repository[T Entity] struct {
+	db    DB
+	items map[string]T
+}
+
+func (r *Repository[T]) Get(ctx context.Context, id string) (T, error) {
+	var zero T
+	return zero, nil
+}
+
+type DB interface{}
+`,
+		"internal/service/user.go": `package service
+
+import (
+	"github.com/hashicorp/golang-lru/v2"
+	"example.com/app/internal/store"
+)
+
+type UserService struct {
+	users  store.Repository[User]
+	orders *store.Repository[Order]
+	cache  *lru.Cache[string, Session]
+}
+
+type User struct{}
+type Order struct{}
+type Session struct{}
+`,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	repository := arch.Component("Repository")
+	if repository == nil {
+		t.Fatalf("generic Repository not found in %+v", arch.Components)
+	}
+	if repository.DisplayName() != "Repository[T Entity]" {
+		t.Errorf("display name = %s", repository.DisplayName())
+	}
+	if !reflect.DeepEqual(repository.ElementTypes, []string{"Order", "User"}) {
+		t.Errorf("element types = %v, want [Order User]", repository.ElementTypes)
+	}
+	if len(repository.Methods) != 1 || repository.Methods[0].Name != "Get" {
+		t.Errorf("methods = %+v, want Get", repository.Methods)
+	}
+
+	service := arch.Component("UserService")
+	if service == nil {
+		t.Fatal("UserService not found")
+	}
+	if !reflect.DeepEqual(service.Dependencies, []string{"Repository"}) {
+		t.Errorf("dependencies = %v, want [Repository]", service.Dependencies)
+	}
+	if e := arch.Explain("UserService"); len(e) != 1 || len(e[0].Fields) != 2 || e[0].Fields[1].Type != "Cache" {
+		t.Errorf("fields = %+v, want Repository and Cache", e)
+	}
+}
+
+func TestAnalyzeCollectionAndFunctionDependencies(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/service/dispatch.go": `package service
+
+import "context"
+
+type Event struct{}
+type Job struct{}
+
+type EventHandler interface{ Handle(ctx context.Context, e Event) error }
+type PaymentClient interface{ Charge(ctx context.Context) error }
+type JobStore interface{ Save(j Job) error }
+
+type Dispatcher struct {
+	handlers []EventHandler
+	clients  map[string]PaymentClient
+	store    func(ctx context.Context) JobStore
+	out      chan<- Job
+	notify   func(ctx context.Context, e Event) error
+}
+
+type Worker struct {
+	in    <-chan Job
+	store JobStore
+}
+`,
+		"internal/client/payment.go": "package client\n\ntype PaymentClient struct{}\n",
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	e := arch.Explain("Dispatcher")
+	if len(e) != 1 {
+		t.Fatalf("Dispatcher explanations = %+v", e)
+	}
+	got := make(map[string]FieldEvidence)
+	for _, f := range e[0].Fields {
+		got[f.Type] = f
+	}
+	want := map[string]FieldEvidence{
+		"EventHandler":  {Kind: EdgeCollection, Dependency: true},
+		"PaymentClient": {Kind: EdgeCollection, Dependency: true},
+		"JobStore":      {Kind: EdgeCallback, Dependency: true},
+		"Job":           {Kind: EdgeChannel, Direction: DirectionSend},
+		"Event":         {Kind: EdgeCallback},
+	}
+	for name, w := range want {
+		f, ok := got[name]
+		if !ok || f.Kind != w.Kind || f.Direction != w.Direction || f.Dependency != w.Dependency {
+			t.Errorf("field %s = %+v, want kind %s, direction %q, dependency %v", name, f, w.Kind, w.Direction, w.Dependency)
+		}
+	}
+
+	if w := arch.Explain("Worker"); len(w) != 1 || w[0].Fields[0].Direction != DirectionReceive {
+		t.Errorf("Worker fields = %+v, want a receive channel first", w)
+	}
+
+	comp := arch.Component("Dispatcher")
+	if comp == nil {
+		t.Fatal("Dispatcher is not a component")
+	}
+	if want := []Edge{{Target: "PaymentClient", Kind: EdgeCollection}}; !reflect.DeepEqual(comp.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", comp.Edges, want)
+	}
+}
+
+func TestAnalyzeEmbedding(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/service/order.go": `package service
+
+type Logger interface{ Log(msg string) }
+type Reader interface{ Get(id string) error }
+type Writer interface{ Put(id string) error }
+type ReadWriteRepo interface {
+	Reader
+	Writer
+}
+
+type BaseService struct {
+	log Logger
+}
+
+type OrderService struct {
+	*BaseService
+	repo ReadWriteRepo
+}
+`,
+		"internal/store/order.go": `package store
+
+type Reader struct{}
+type Writer struct{}
+type Logger struct{}
+`,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	comp := arch.Component("OrderService")
+	if comp == nil {
+		t.Fatalf("OrderService not found in %+v", arch.Components)
+	}
+	want := []Edge{
+		{Target: "BaseService", Kind: EdgeEmbeds},
+		{Target: "Reader", Kind: EdgeDirect, Via: "ReadWriteRepo"},
+		{Target: "Writer", Kind: EdgeDirect, Via: "ReadWriteRepo"},
+		{Target: "Logger", Kind: EdgeDirect, Via: "BaseService"},
+	}
+	if !reflect.DeepEqual(comp.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", comp.Edges, want)
+	}
+	if !reflect.DeepEqual(comp.Dependencies, []string{"BaseService", "Reader", "Writer", "Logger"}) {
+		t.Errorf("dependencies = %v", comp.Dependencies)
+	}
+}
+
+func TestAnalyzeCalls(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/service/order.go": `package service
+
+import "context"
+
+type Logger interface{ Log(msg string) }
+type OrderReader interface{ Get(ctx context.Context, id string) error }
+type OrderWriter interface{ Save(ctx context.Context, id string) error }
+type OrderRepository interface {
+	OrderReader
+	OrderWriter
+}
+
+type base struct{ log Logger }
+
+type OrderService struct {
+	base
+	repo OrderRepository
+}
+`,
+		"internal/service/place.go": `package service
+
+import "context"
+
+func (s *OrderService) Place(ctx context.Context, id string) error {
+	if err := s.repo.Get(ctx, id); err == nil {
+		return nil
+	}
+	s.log.Log("placing " + id)
+	return s.repo.Save(ctx, id)
+}
+
+func (s *OrderService) String() string { return "orders" }
+`,
+		"internal/service/cancel.go": `package service
+
+import "context"
+
+func (s *OrderService) Cancel(ctx context.Context, ids []string) error {
+	repo := s.repo
+	for _, id := range ids {
+		if err := repo.Save(ctx, id); err != nil {
+			return err
+		}
+	}
+	done := func() { s.log.Log("cancelled") }
+	done()
+	return nil
+}
+
+// Calls on parameters are not traced
+func (s *OrderService) Audit(log Logger) { log.Log("audit") }
+`,
+		"internal/store/order.go": `package store
+
+type OrderReader struct{}
+type OrderWriter struct{}
+type Logger struct{}
+`,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	comp := arch.Component("OrderService")
+	if comp == nil {
+		t.Fatalf("OrderService not found in %+v", arch.Components)
+	}
+	cancel := filepath.Join("internal", "service", "cancel.go")
+	place := filepath.Join("internal", "service", "place.go")
+	wantCalls := []Call{
+		{Caller: "Cancel", Target: "OrderWriter", Method: "Save", FilePath: cancel, Line: 8},
+		{Caller: "Cancel", Target: "Logger", Method: "Log", FilePath: cancel, Line: 12},
+		{Caller: "Place", Target: "OrderReader", Method: "Get", FilePath: place, Line: 6},
+		{Caller: "Place", Target: "Logger", Method: "Log", FilePath: place, Line: 9},
+		{Caller: "Place", Target: "OrderWriter", Method: "Save", FilePath: place, Line: 10},
+	}
+	if !reflect.DeepEqual(comp.Calls, wantCalls) {
+		t.Errorf("calls = %+v, want %+v", comp.Calls, wantCalls)
+	}
+	if edge := comp.Edge("OrderWriter"); !reflect.DeepEqual(edge.Methods, []string{"Save"}) {
+		t.Errorf("edge to OrderWriter = %+v, want methods [Save]", edge)
+	}
+}
+
+func TestAnalyzeFunctionHandlers(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/api/orders.go": `package api
+
+import (
+	"net/http"
+
+	"example.com/app/internal/service"
+)
+
+// Health reports that the server is up.
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// NewOrderHandler serves orders.
+func NewOrderHandler(svc service.OrderService, logger Logger, prefix string) http.HandlerFunc {
+	logger.Log("starting")
+	return func(w http.ResponseWriter, r *http.Request) {
+		svc.Place(r.Context(), prefix)
+	}
+}
+
+// NewStructHandler returns a struct, not a closure.
+func NewStructHandler(svc service.OrderService) http.Handler {
+	return &structHandler{svc: svc}
+}
+
+func helper(w http.ResponseWriter, r *http.Request) {}
+
+type Logger interface{ Log(msg string) }
+type structHandler struct{ svc service.OrderService }
+`,
+		"internal/service/order.go": "package service\n\ntype OrderService struct{ repo OrderRepository }\n\ntype OrderRepository interface{}\n",
+		"internal/store/order.go":   "package store\n\ntype OrderRepository struct{}\n",
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	health := arch.Component("Health")
+	if health == nil || !health.Function || health.Type != ComponentHandler || health.Doc != "Health reports that the server is up." {
+		t.Errorf("Health = %+v", health)
+	}
+
+	handler := arch.Component("NewOrderHandler")
+	if handler == nil {
+		t.Fatalf("NewOrderHandler not found in %+v", arch.Components)
+	}
+	if handler.DisplayName() != "NewOrderHandler()" {
+		t.Errorf("display name = %s", handler.DisplayName())
+	}
+	// logger is only used while constructing, so it is not captured
+	if !reflect.DeepEqual(handler.Dependencies, []string{"OrderService"}) {
+		t.Errorf("dependencies = %v, want [OrderService]", handler.Dependencies)
+	}
+	wantCalls := []Call{{Caller: "NewOrderHandler", Target: "OrderService", Method: "Place", FilePath: filepath.Join("internal", "api", "orders.go"), Line: 18}}
+	if !reflect.DeepEqual(handler.Calls, wantCalls) {
+		t.Errorf("calls = %+v, want %+v", handler.Calls, wantCalls)
+	}
+
+	for _, name := range []string{"NewStructHandler", "helper"} {
+		if arch.Component(name) != nil {
+			t.Errorf("%s is a component", name)
+		}
+	}
+}
+
+func TestAnalyzeMiddlewareStructs(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/middleware/options.go": `package middleware
+
+import "time"
+
+// Settings configure the middleware in this package.
+type Settings struct {
+	Timeout time.Duration
+	Origins []string
+}
+`,
+		"internal/middleware/auth.go": `package middleware
+
+import "net/http"
+
+type Auth struct{ next http.Handler }
+
+func (a *Auth) ServeHTTP(w http.ResponseWriter, r *http.Request) { a.next.ServeHTTP(w, r) }
+
+type Limiter struct{ n int }
+
+type Logger interface{ Log(msg string) }
+
+type Tracer struct{ log Logger }
+`,
+		"internal/middleware/limit.go": `package middleware
+
+import "net/http"
+
+func (l *Limiter) Wrap(next http.Handler) http.Handler { return next }
+`,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	for _, name := range []string{"Auth", "Limiter", "Tracer"} {
+		if comp := arch.Component(name); comp == nil || comp.Type != ComponentMiddleware {
+			t.Errorf("%s = %+v, want middleware", name, comp)
+		}
+	}
+	if comp := arch.Component("Settings"); comp != nil {
+		t.Errorf("Settings = %+v, want no component: it neither has dependencies nor wraps a handler", comp)
+	}
+	if got := arch.Component("Limiter").Classification.Reason; !strings.Contains(got, "Wrap(next http.Handler) http.Handler") {
+		t.Errorf("Limiter classified because %q, want its Wrap method named", got)
+	}
+}
+
+func TestAnalyzeRoutes(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/api/routes.go": `package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"example.com/app/internal/auth"
+)
+
+type OrdersHandler struct{ svc OrderService }
+
+type OrderService interface{}
+
+func (h *OrdersHandler) List(w http.ResponseWriter, r *http.Request)   {}
+func (h *OrdersHandler) Create(w http.ResponseWriter, r *http.Request) {}
+
+func Health(w http.ResponseWriter, r *http.Request) {}
+
+// Recover turns panics into 500s.
+func Recover(next http.Handler) http.Handler { return next }
+
+func Routes(h *OrdersHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Get("/health", Health)
+	r.Route("/orders", func(r chi.Router) {
+		r.Use(auth.Required)
+		r.With(auth.RateLimit(100)).Get("/", h.List)
+		r.Post("/{id}", h.Create)
+	})
+	return Recover(r)
+}
+
+func Legacy(mux *http.ServeMux, h *OrdersHandler) {
+	mux.Handle("GET /legacy/orders", auth.Required(http.HandlerFunc(h.List)))
+	mux.Handle("/legacy/health", auth.Required(http.HandlerFunc(Health)))
+	cache.Get("key", &h)
+}
+`,
+		"internal/auth/auth.go": `package auth
+
+import "net/http"
+
+func Required(next http.Handler) http.Handler { return next }
+
+func RateLimit(n int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler { return next }
+}
+`,
+		"internal/service/order.go": "package service\n\ntype OrderService struct{}\n",
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	for _, name := range []string{"Recover", "Required", "RateLimit"} {
+		if comp := arch.Component(name); comp == nil || comp.Type != ComponentMiddleware {
+			t.Errorf("%s = %+v, want middleware", name, comp)
+		}
+	}
+
+	var pipelines []string
+	for _, r := range arch.Routes {
+		pipelines = append(pipelines, r.Pipeline())
+	}
+	want := []string{
+		"GET /health → Recover → Logger → Health",
+		"ANY /legacy/health → Required → Health",
+		"GET /legacy/orders → Required → OrdersHandler.List",
+		"GET /orders → Recover → Logger → Required → RateLimit → OrdersHandler.List",
+		"POST /orders/{id} → Recover → Logger → Required → OrdersHandler.Create",
+	}
+	if !reflect.DeepEqual(pipelines, want) {
+		t.Errorf("pipelines =\n%s\nwant\n%s", strings.Join(pipelines, "\n"), strings.Join(want, "\n"))
+	}
+
+	orders := arch.Routes[3]
+	if orders.Handler.Component != "OrdersHandler" || orders.Middleware[1].Component != "" || orders.Middleware[2].Component != "Required" {
+		t.Errorf("GET /orders steps = %+v → %+v", orders.Middleware, orders.Handler)
+	}
+}
+
+func TestAnalyzeModules(t *testing.T) {
+	files := map[string]string{
+		"orders/go.mod": "module example.com/orders\n\ngo 1.24\n",
+		"orders/internal/service/order.go": `package service
+
+type PaymentClient interface{ Charge() }
+type OrderRepository interface{ Save() }
+
+type OrderService struct {
+	payments PaymentClient
+	repo     OrderRepository
+}
+`,
+		"orders/internal/store/order.go": "package store\n\ntype OrderRepository struct{}\n",
+		"payments/go.mod":                "module example.com/payments // the payments module\n",
+		"payments/client/client.go":      "package client\n\ntype PaymentClient struct{}\n",
+	}
+	repo := writeRepo(t, files)
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	want := []Module{{Path: "example.com/orders", Dir: "orders", GoVersion: "1.24"}, {Path: "example.com/payments", Dir: "payments"}}
+	if !reflect.DeepEqual(arch.Modules, want) || arch.Workspace {
+		t.Errorf("modules = %+v (workspace %v), want %+v", arch.Modules, arch.Workspace, want)
+	}
+
+	svc := arch.Component("OrderService")
+	if svc == nil || svc.Module != "example.com/orders" || svc.ImportPath != "example.com/orders/internal/service" {
+		t.Fatalf("OrderService = %+v", svc)
+	}
+	if !svc.Edge("PaymentClient").CrossModule || svc.Edge("OrderRepository").CrossModule {
+		t.Errorf("edges = %+v, want only PaymentClient to cross modules", svc.Edges)
+	}
+
+	orders, err := arch.ForModule("orders")
+	if err != nil {
+		t.Fatalf("ForModule: %v", err)
+	}
+	if len(orders.Components) != 2 || !reflect.DeepEqual(orders.Dependencies["OrderService"], []string{"OrderRepository"}) {
+		t.Errorf("orders module = %+v", orders.Components)
+	}
+	if _, err := arch.ForModule("example.com/missing"); err == nil {
+		t.Error("ForModule found a module that does not exist")
+	}
+
+	// A go.work decides the modules, whatever other go.mod files there are
+	files["go.work"] = "go 1.24\n\nuse (\n\t./orders\n)\n"
+	repo = writeRepo(t, files)
+	arch, err = Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if len(arch.Modules) != 1 || arch.Modules[0].Path != "example.com/orders" || !arch.Workspace {
+		t.Errorf("workspace modules = %+v", arch.Modules)
+	}
+
+	// A directory inside a module belongs to it
+	arch, err = Analyze(t.Context(), filepath.Join(repo, "orders", "internal"), nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if len(arch.Modules) != 1 || arch.Modules[0].Path != "example.com/orders/internal" || arch.Modules[0].Dir != "." {
+		t.Errorf("enclosing module = %+v", arch.Modules)
+	}
+}
+
+func TestAnalyzeWorkspaceSameNames(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"go.work":        "go 1.24\n\nuse (\n\t./billing\n\t./shipping\n)\n",
+		"billing/go.mod": "module example.com/billing\n",
+		"billing/api/handler.go": `package api
+
+type Service interface{ Bill() }
+
+type Handler struct{ svc Service }
+`,
+		"billing/service/service.go": `package service
+
+type Repo interface{ Save() }
+
+type Service struct{ repo Repo }
+`,
+		"billing/store/repo.go": "package store\n\ntype Repo struct{}\n",
+		"shipping/go.mod":       "module example.com/shipping\n",
+		"shipping/api/handler.go": `package api
+
+type Service interface{ Ship() }
+
+type Dispatcher struct{ svc Service }
+`,
+		"shipping/service/service.go": `package service
+
+type Repo interface{ Save() }
+
+type Service struct{ repo Repo }
+`,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	inModule := func(a *Architecture, name, module string) *Component {
+		for i, comp := range a.Components {
+			if comp.Name == name && comp.Module == module {
+				return &a.Components[i]
+			}
+		}
+		t.Fatalf("%s not found in %s: %+v", name, module, a.Components)
+		return nil
+	}
+
+	// Each module's Service is its own, whichever module was walked first
+	if e := inModule(arch, "Handler", "example.com/billing").Edge("Service"); e.CrossModule {
+		t.Errorf("billing Handler → Service = %+v, want within billing", e)
+	}
+	if e := inModule(arch, "Dispatcher", "example.com/shipping").Edge("Service"); e.CrossModule {
+		t.Errorf("shipping Dispatcher → Service = %+v, want within shipping", e)
+	}
+	if e := inModule(arch, "Service", "example.com/shipping").Edge("Repo"); !e.CrossModule || e.TargetModule != "example.com/billing" {
+		t.Errorf("shipping Service → Repo = %+v, want a dependency on billing", e)
+	}
+
+	shipping, err := arch.ForModule("shipping")
+	if err != nil {
+		t.Fatalf("ForModule: %v", err)
+	}
+	var names []string
+	for _, comp := range shipping.Components {
+		if comp.Module != "example.com/shipping" {
+			t.Errorf("shipping module has %s from %s", comp.Name, comp.Module)
+		}
+		names = append(names, comp.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Dispatcher", "Service"}) {
+		t.Errorf("shipping components = %v, want [Dispatcher Service]", names)
+	}
+	if deps := inModule(shipping, "Dispatcher", "example.com/shipping").Dependencies; !reflect.DeepEqual(deps, []string{"Service"}) {
+		t.Errorf("shipping Dispatcher dependencies = %v, want [Service]", deps)
+	}
+	if deps := inModule(shipping, "Service", "example.com/shipping").Dependencies; len(deps) != 0 {
+		t.Errorf("shipping Service dependencies = %v, want none within the module", deps)
+	}
+}
+
+func TestAnalyzeBuildConfig(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/store/file_linux.go":   "package store\n\ntype Database interface{ Open() }\n\ntype FileStore struct{ db Database }\n",
+		"internal/store/file_windows.go": "package store\n\ntype FileStore struct{ db Database }\n",
+		"internal/store/fake.go":         "//go:build integration\n\npackage store\n\ntype FakeRepository struct{ db Database }\n",
+	})
+
+	count := func(arch *Architecture, name string) int {
+		n := 0
+		for _, comp := range arch.Components {
+			if comp.Name == name {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Without a configuration, every file is analyzed
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if count(arch, "FileStore") != 2 || count(arch, "FakeRepository") != 1 || arch.Build != nil {
+		t.Errorf("components = %+v, want every file analyzed", arch.Components)
+	}
+
+	linux := &BuildConfig{GOOS: "linux", GOARCH: "amd64"}
+	arch, err = Analyze(t.Context(), repo, linux)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if count(arch, "FileStore") != 1 || count(arch, "FakeRepository") != 0 {
+		t.Errorf("components = %+v, want only the linux FileStore", arch.Components)
+	}
+	if arch.Build.String() != "linux/amd64" {
+		t.Errorf("build = %q, want linux/amd64", arch.Build)
+	}
+	var excluded []string
+	for _, d := range arch.Diagnostics {
+		if d.Kind == DiagnosticExcludedFile {
+			excluded = append(excluded, d.FilePath)
+		}
+	}
+	want := []string{filepath.Join("internal", "store", "fake.go"), filepath.Join("internal", "store", "file_windows.go")}
+	if !reflect.DeepEqual(excluded, want) {
+		t.Errorf("excluded files = %v, want %v", excluded, want)
+	}
+
+	windows := &BuildConfig{GOOS: "windows", GOARCH: "amd64", Tags: []string{"integration"}}
+	arch, err = Analyze(t.Context(), repo, windows)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if comp := arch.Component("FileStore"); comp == nil || !strings.HasSuffix(comp.FilePath, "file_windows.go") || count(arch, "FakeRepository") != 1 {
+		t.Errorf("components = %+v, want the windows FileStore and FakeRepository", arch.Components)
+	}
+
+	// One cache serves every configuration, each giving what Analyze gives
+	cache := NewCache(t.TempDir())
+	for _, build := range []*BuildConfig{linux, windows, nil, linux} {
+		want, err := Analyze(t.Context(), repo, build)
+		if err != nil {
+			t.Fatalf("Failed to analyze: %v", err)
+		}
+		got, err := cache.Analyze(t.Context(), repo, build)
+		if err != nil {
+			t.Fatalf("Failed to analyze through the cache: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cached analysis for %q differs from a fresh one", build)
+		}
+	}
+}
+
+func TestGeneratedCode(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"internal/pb/payment_grpc.pb.go": `// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+type PaymentServiceServer interface {
+	Charge(ctx context.Context, in *ChargeRequest) (*ChargeReply, error)
+	Refund(ctx context.Context, in *RefundRequest) (*RefundReply, error)
+	mustEmbedUnimplementedPaymentServiceServer()
+}
+
+type UnimplementedPaymentServiceServer struct{}
+`,
+		"internal/handler/payment.go": `package handler
+
+type PaymentHandler struct {
+	pb.UnimplementedPaymentServiceServer
+	orders OrderService
+}
+
+func (h *PaymentHandler) Charge(ctx context.Context, in *pb.ChargeRequest) (*pb.ChargeReply, error) {
+	return nil, nil
+}
+`,
+		"internal/repository/sqlc/db.go": `// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.25.0
+
+package sqlc
+
+type DBTX interface {
+	Exec(ctx context.Context, query string, args ...any) error
+}
+
+type Queries struct {
+	db DBTX
+}
+`,
+		"internal/repository/sqlc/querier.go": `// Code generated by sqlc. DO NOT EDIT.
+
+package sqlc
+
+type Querier interface {
+	GetOrder(ctx context.Context, id int64) (Order, error)
+	CreateOrder(ctx context.Context, arg CreateOrderParams) error
+}
+`,
+		"internal/repository/sqlc/orders.sql.go": `// Code generated by sqlc. DO NOT EDIT.
+
+package sqlc
+
+func (q *Queries) GetOrder(ctx context.Context, id int64) (Order, error) { return Order{}, nil }
+
+func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) error { return nil }
+`,
+		"internal/api/api.gen.go": `// Package api provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.1.0 DO NOT EDIT.
+package api
+
+type ServerInterface interface {
+	ListOrders(ctx echo.Context) error
+}
+`,
+		"internal/api/server.gen.go": `// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.1.0 DO NOT EDIT.
+package api
+
+type ServerInterfaceWrapper struct {
+	Handler ServerInterface
+}
+
+func (w *ServerInterfaceWrapper) ListOrders(ctx echo.Context) error { return w.Handler.ListOrders(ctx) }
+
+func RegisterHandlers(e *echo.Echo, w *ServerInterfaceWrapper) {
+	e.GET("/orders", w.ListOrders)
+}
+
+const server = "https://orders.acme.internal"
+
+func ListOrdersRequest() (*http.Request, error) {
+	return http.NewRequest("GET", server+"/orders", nil)
+}
+`,
+		"internal/handler/orders.go": `package handler
+
+type OrdersHandler struct {
+	orders OrderService
+}
+
+func (h *OrdersHandler) ListOrders(ctx echo.Context) error { return nil }
+`,
+		"internal/service/order.go": `package service
+
+type OrderService struct {
+	queries  *sqlc.Queries
+	payments PaymentServiceServer
+}
+`,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if comp := arch.Component("Queries"); comp == nil || comp.Generated != "sqlc" {
+		t.Errorf("Queries = %+v, want a component generated by sqlc", comp)
+	}
+	generators := make(map[string]string)
+	for _, g := range arch.Generated {
+		generators[filepath.Base(g.Path)] = g.Generator
+	}
+	if generators["payment_grpc.pb.go"] != "protoc-gen-go-grpc" || generators["api.gen.go"] != "oapi-codegen" || generators["order.go"] != "" || len(generators) != 6 {
+		t.Errorf("generated files = %v", generators)
+	}
+
+	var contracts []string
+	for _, c := range arch.Contracts {
+		contracts = append(contracts, fmt.Sprintf("%s %s %v implemented by %v, used by %v", c.Kind, c.Name, c.Operations, c.ImplementedBy, c.UsedBy))
+	}
+	want := []string{
+		"openapi api [ListOrders] implemented by [OrdersHandler], used by []",
+		"grpc PaymentService [Charge Refund] implemented by [PaymentHandler], used by [OrderService]",
+		"db sqlc [GetOrder CreateOrder] implemented by [], used by [OrderService]",
+	}
+	if !reflect.DeepEqual(contracts, want) {
+		t.Errorf("contracts = %q, want %q", contracts, want)
+	}
+
+	hidden, err := arch.WithGenerated(GeneratedHide)
+	if err != nil {
+		t.Fatalf("WithGenerated: %v", err)
+	}
+	if hidden.Component("Queries") != nil || hidden.Component("ServerInterfaceWrapper") != nil || len(hidden.Contracts) != 3 {
+		t.Errorf("hidden = %+v, want the generated components left out and the contracts kept", hidden.Components)
+	}
+	if len(arch.Routes) != 1 || arch.Routes[0].Handler.Component != "ServerInterfaceWrapper" || len(hidden.Routes) != 0 {
+		t.Errorf("routes = %+v, hidden %+v, want the route served by the generated wrapper hidden with it", arch.Routes, hidden.Routes)
+	}
+	if len(arch.Endpoints) != 1 || len(hidden.Endpoints) != 0 {
+		t.Errorf("endpoints = %+v, hidden %+v, want the generated client's URL hidden", arch.Endpoints, hidden.Endpoints)
+	}
+	for _, d := range hidden.Diagnostics {
+		if d.Component == "Queries" || d.Component == "ServerInterfaceWrapper" {
+			t.Errorf("diagnostic %+v about a hidden component", d)
+		}
+	}
+	if e := hidden.Explain("Queries"); len(e) != 1 || e[0].Component || !strings.HasSuffix(e[0].Summary, "hidden as generated code") {
+		t.Errorf("explanation of hidden Queries = %+v", e)
+	}
+
+	collapsed, err := arch.WithGenerated(GeneratedCollapse)
+	if err != nil {
+		t.Fatalf("WithGenerated: %v", err)
+	}
+	if comp := collapsed.Component("generated/sqlc"); comp == nil || comp.Type != ComponentRepository || comp.Doc != "Generated by sqlc: Queries" {
+		t.Errorf("generated/sqlc = %+v", comp)
+	}
+	if len(collapsed.Routes) != 1 || collapsed.Routes[0].Handler.Component != "generated/oapi-codegen" || len(collapsed.Endpoints) != 1 {
+		t.Errorf("collapsed routes = %+v, endpoints %+v, want them kept with the group", collapsed.Routes, collapsed.Endpoints)
+	}
+	if e := collapsed.Explain("Queries"); len(e) != 1 || !strings.HasSuffix(e[0].Summary, "collapsed into generated/sqlc") {
+		t.Errorf("explanation of collapsed Queries = %+v", e)
+	}
+	if _, err := arch.WithGenerated("fold"); err == nil {
+		t.Error("WithGenerated accepted an unknown mode")
+	}
+}
+
+func TestAnalyzeOpenAPI(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"api/openapi.yaml": `openapi: 3.0.3
+info:
+  title: Orders API
+  version: "1.2.0"
+servers:
+  - url: https://api.example.com/v1 # production
+paths:
+  /orders:
+    get:
+      operationId: listOrders
+      summary: List orders
+    post:
+      operationId: createOrder
+  "/orders/{id}":
+    parameters:
+      - name: id
+        in: path
+    get:
+      operationId: getOrder
+      description: |
+        get: not a key
+  /refunds:
+    post:
+      operationId: createRefund
+`,
+		"api/admin.json": `{
+  "openapi": "3.1.0",
+  "info": {"title": "Admin API", "version": "1"},
+  "paths": {
+    "/admin/stats": {"get": {"operationId": "get-stats"}}
+  }
+}`,
+		"web/tsconfig.json": `{"compilerOptions": {"paths": {"@/*": ["src/*"]}}, "openapi": true}`,
+		"swagger.yaml":      "swagger: \"2.0\"\nopenapi: no\npaths:\n  /old:\n    get: {}\n",
+		"internal/handler/orders.go": `package handler
+
+type OrdersHandler struct{ svc OrderService }
+
+type OrderService interface{}
+
+func (h *OrdersHandler) List(w http.ResponseWriter, r *http.Request)   {}
+func (h *OrdersHandler) Create(w http.ResponseWriter, r *http.Request) {}
+func (h *OrdersHandler) Get(w http.ResponseWriter, r *http.Request)    {}
+
+func Health(w http.ResponseWriter, r *http.Request) {}
+
+func Routes(mux *http.ServeMux, h *OrdersHandler) {
+	mux.HandleFunc("GET /v1/orders", h.List)
+	mux.HandleFunc("POST /v1/orders", h.Create)
+	mux.HandleFunc("GET /v1/orders/{orderID}", h.Get)
+	mux.HandleFunc("/healthz", Health)
+}
+`,
+		"internal/admin/api.gen.go": `// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.1.0 DO NOT EDIT.
+package admin
+
+type ServerInterface interface {
+	GetStats(ctx echo.Context) error
+}
+`,
+		"internal/handler/admin.go": `package handler
+
+type AdminHandler struct{ svc OrderService }
+
+func (h *AdminHandler) GetStats(ctx echo.Context) error { return nil }
+`,
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+
+	wantSpecs := []APISpec{
+		{Path: filepath.Join("api", "admin.json"), Title: "Admin API", Version: "1"},
+		{Path: filepath.Join("api", "openapi.yaml"), Title: "Orders API", Version: "1.2.0", BasePath: "/v1"},
+	}
+	if !reflect.DeepEqual(arch.API.Specs, wantSpecs) {
+		t.Errorf("specs = %+v, want %+v", arch.API.Specs, wantSpecs)
+	}
+
+	var ops []string
+	for _, op := range arch.API.Operations {
+		ops = append(ops, fmt.Sprintf("%s %s %s → %s %s", op.Method, op.Path, op.OperationID, op.Route, op.Handler))
+	}
+	want := []string{
+		"GET /admin/stats get-stats →  AdminHandler.GetStats",
+		"GET /orders listOrders → GET /v1/orders OrdersHandler.List",
+		"POST /orders createOrder → POST /v1/orders OrdersHandler.Create",
+		"GET /orders/{id} getOrder → GET /v1/orders/{orderID} OrdersHandler.Get",
+		"POST /refunds createRefund →  ",
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("operations = %q, want %q", ops, want)
+	}
+	if len(arch.API.Unimplemented) != 1 || arch.API.Unimplemented[0].OperationID != "createRefund" || arch.API.Unimplemented[0].Line != 23 {
+		t.Errorf("unimplemented = %+v, want createRefund on line 23", arch.API.Unimplemented)
+	}
+	if len(arch.API.Undocumented) != 1 || arch.API.Undocumented[0].Path != "/healthz" {
+		t.Errorf("undocumented = %+v, want /healthz", arch.API.Undocumented)
+	}
+}
+
+func TestAnalyzeOpenAPIFiles(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"api/stats.yaml": `openapi: "3.0.0"
+info: {title: Stats API, version: "2"}
+x-operations:
+  metrics: &metrics
+    operationId: getMetrics
+paths:
+  /metrics: {get: *metrics}
+`,
+		"api/broken.yaml":             "openapi: 3.0.0\npaths: [\n",
+		"api/testdata/openapi.yaml":   "openapi: 3.0.0\npaths:\n  /fixture:\n    get: {}\n",
+		"deploy/values.yaml":          "docs:\n  openapi: 3.0.0\npaths:\n  /ignored:\n    get: {}\n",
+		"deploy/broken.yaml":          "replicas: [\n",
+		"internal/handler/metrics.go": "package handler\n",
+	})
+
+	arch, err := Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	wantSpecs := []APISpec{{Path: filepath.Join("api", "stats.yaml"), Title: "Stats API", Version: "2"}}
+	if !reflect.DeepEqual(arch.API.Specs, wantSpecs) {
+		t.Errorf("specs = %+v, want %+v", arch.API.Specs, wantSpecs)
+	}
+	if len(arch.API.Operations) != 1 || arch.API.Operations[0].OperationID != "getMetrics" || arch.API.Operations[0].Line != 7 {
+		t.Errorf("operations = %+v, want getMetrics on line 7", arch.API.Operations)
+	}
+	var parseErrors []string
+	for _, d := range arch.Diagnostics {
+		if d.Kind == DiagnosticParseError {
+			parseErrors = append(parseErrors, d.FilePath)
+		}
+	}
+	if want := []string{filepath.Join("api", "broken.yaml")}; !reflect.DeepEqual(parseErrors, want) {
+		t.Errorf("parse errors = %v, want %v", parseErrors, want)
+	}
+
+	// Specs are cached by content like Go files, and re-read when they change
+	cache := NewCache(t.TempDir())
+	if got, err := cache.Analyze(t.Context(), repo, nil); err != nil || !reflect.DeepEqual(got.API, arch.API) {
+		t.Errorf("cached API = %+v, %v, want %+v", got.API, err, arch.API)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "api", "stats.yaml"), []byte("openapi: 3.1.0\npaths:\n  /stats:\n    get: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.Analyze(t.Context(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if len(got.API.Operations) != 1 || got.API.Operations[0].Path != "/stats" {
+		t.Errorf("operations after the spec changed = %+v, want GET /stats", got.API.Operations)
+	}
+}
+
+func TestLinkServices(t *testing.T) {
+	repos := map[string]map[string]string{
+		"orders": {
+			"go.mod": "module example.com/orders\n",
+			"internal/handler/orders.go": `package handler
+
+import (
+	"net/http"
+
+	"example.com/orders/internal/pb"
+	"github.com/segmentio/kafka-go"
+)
+
+const ordersCreated = "orders.created"
+
+// See https://docs.example.org/orders for the API.
+const docs = "https://docs.example.org/orders"
+
+type StripeClient struct {
+	BaseURL string
+}
+
+type OrderHandler struct{}
+
+func (h *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {}
+
+func Routes(mux *http.ServeMux, h *OrderHandler, conn *grpc.ClientConn) {
+	mux.HandleFunc("GET /orders/{id}", h.Get)
+	_ = pb.NewPaymentServiceClient(conn)
+	_ = &kafka.Writer{Topic: ordersCreated}
+	stripe := &StripeClient{BaseURL: "https://api.stripe.com/v1"}
+	http.Get(stripe.BaseURL + "/charges")
+	http.Get("http://localhost:8080")
+}
+`,
+		},
+		"payments-svc": {
+			"go.mod": "module example.com/payments-svc\n",
+			"main.go": `package main
+
+func main() {
+	pb.RegisterPaymentServiceServer(srv, &server{})
+	_ = pb.NewLedgerServiceClient(conn)
+}
+`,
+		},
+		"shipping": {
+			"go.mod": "module example.com/shipping\n",
+			"main.go": `package main
+
+const gateway = "http://gateway.internal"
+
+func main() {
+	_ = kafka.NewReader(kafka.ReaderConfig{Topic: "orders.created"})
+	req, _ := http.NewRequestWithContext(ctx, "GET", gateway+"/orders/42", nil)
+	http.DefaultClient.Do(req)
+	http.Post("http://payments-svc.default.svc.cluster.local/refunds", "application/json", nil)
+	http.Get("https://ledger.acme.internal/entries")
+}
+`,
+		},
+		"api": {
+			"go.mod": "module example.com/api/v2\n",
+			"main.go": `package main
+
+func main() {}
+`,
+		},
+	}
+
+	var services []Service
+	for _, name := range []string{"orders", "payments-svc", "shipping", "api"} {
+		repo := writeRepo(t, repos[name])
+		arch, err := Analyze(t.Context(), repo, nil)
+		if err != nil {
+			t.Fatalf("Failed to analyze %s: %v", name, err)
+		}
+		services = append(services, NewService(repo, arch))
+	}
+	if services[3].Name != "api" {
+		t.Errorf("service of module example.com/api/v2 named %q, want api", services[3].Name)
+	}
+	services[1].Hosts = []string{"ledger.acme.internal"}
+	sys := LinkServices(services)
+
+	var links []string
+	for _, l := range sys.Links {
+		var via []string
+		for _, v := range l.Via {
+			via = append(via, v.Name)
+		}
+		links = append(links, fmt.Sprintf("%s → %s %s %s", l.From, l.To, l.Kind, strings.Join(via, ",")))
+	}
+	want := []string{
+		"orders → api.stripe.com http https://api.stripe.com/v1/charges",
+		"orders → payments-svc grpc PaymentService",
+		"orders → shipping topic orders.created",
+		"shipping → orders http GET /orders/{id}",
+		"shipping → payments-svc http http://payments-svc.default.svc.cluster.local/refunds,https://ledger.acme.internal/entries",
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("links = %q, want %q", links, want)
+	}
+
+	if len(sys.External) != 1 || sys.External[0].Name != "api.stripe.com" {
+		t.Errorf("external = %+v, want only api.stripe.com", sys.External)
+	}
+	if len(sys.Unmatched) != 1 || sys.Unmatched[0].Service != "payments-svc" || sys.Unmatched[0].Name != "LedgerService" {
+		t.Errorf("unmatched = %+v, want the LedgerService client", sys.Unmatched)
+	}
+}
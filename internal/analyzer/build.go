@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"bytes"
+	"go/build"
+	"io"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// BuildConfig selects the files an analysis includes by their build
+// constraints, as `go build` would for a target platform and set of tags.
+// Without one, every file is analyzed, whatever its constraints.
+type BuildConfig struct {
+	GOOS   string   `json:"goos"`   // Defaults to the running platform's
+	GOARCH string   `json:"goarch"` // Defaults to the running platform's
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// String describes the configuration, e.g. "linux/amd64, tags integration,cgo".
+func (c *BuildConfig) String() string {
+	if c == nil {
+		return ""
+	}
+	s := c.GOOS + "/" + c.GOARCH
+	if len(c.Tags) > 0 {
+		s += ", tags " + strings.Join(c.Tags, ",")
+	}
+	return s
+}
+
+// withDefaults returns a copy of c with an empty GOOS or GOARCH set to the
+// running platform's, or nil if c is nil.
+func (c *BuildConfig) withDefaults() *BuildConfig {
+	if c == nil {
+		return nil
+	}
+	config := *c
+	config.Tags = slices.Clone(c.Tags)
+	if config.GOOS == "" {
+		config.GOOS = runtime.GOOS
+	}
+	if config.GOARCH == "" {
+		config.GOARCH = runtime.GOARCH
+	}
+	return &config
+}
+
+// buildContext returns the go/build context matching files for c. cgo is
+// enabled as `go build` enables it: on the running platform if the toolchain
+// supports it, or anywhere with the "cgo" tag.
+func (c *BuildConfig) buildContext() build.Context {
+	ctx := build.Default
+	ctx.GOOS = c.GOOS
+	ctx.GOARCH = c.GOARCH
+	ctx.BuildTags = c.Tags
+	ctx.CgoEnabled = slices.Contains(c.Tags, "cgo") ||
+		(build.Default.CgoEnabled && c.GOOS == runtime.GOOS && c.GOARCH == runtime.GOARCH)
+	return ctx
+}
+
+// matchFile reports whether a file with the given content is part of the
+// build, by its name suffixes such as _linux.go and its build constraints.
+func (c *BuildConfig) matchFile(path string, src []byte) bool {
+	ctx := c.buildContext()
+	ctx.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(src)), nil
+	}
+	match, err := ctx.MatchFile(filepath.Dir(path), filepath.Base(path))
+	// A file whose constraints do not parse is left for the parser to report
+	return match || err != nil
+}
@@ -0,0 +1,202 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheVersion is stored with every on-disk cache. Bump it whenever fileFacts
+// or the way they are extracted changes, so stale caches are discarded.
+const cacheVersion = 13
+
+// Cache keeps the facts extracted from each source file, keyed by repository
+// path and file content hash, so that repeated analyses only re-parse the files
+// that changed. With a directory it also persists them across restarts.
+// A Cache is safe for concurrent use.
+type Cache struct {
+	dir   string
+	mu    sync.Mutex
+	repos map[string]*repoCache
+}
+
+// repoCache holds the cached facts of one repository. Its lock serialises
+// analyses of the repository, while other repositories proceed in parallel.
+type repoCache struct {
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is the facts of a file together with the hash of its content.
+// Entries of YAML and JSON files have a Spec if the file is an OpenAPI
+// document, and no facts.
+type cacheEntry struct {
+	Hash  string     `json:"hash"`
+	Facts fileFacts  `json:"facts"`
+	Spec  *specFacts `json:"spec,omitempty"`
+}
+
+// cacheFile is the on-disk form of a repository cache.
+type cacheFile struct {
+	Version  int                   `json:"version"`
+	RepoPath string                `json:"repoPath"`
+	Files    map[string]cacheEntry `json:"files"`
+}
+
+// NewCache creates a cache that persists to dir. An empty dir keeps the cache
+// in memory only.
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir:   dir,
+		repos: make(map[string]*repoCache),
+	}
+}
+
+// DefaultCacheDir returns the directory for the on-disk cache: $SHARINGAN_CACHE_DIR
+// if set, otherwise "sharingan" in the user cache directory. It returns an
+// empty string, disabling the on-disk cache, when $SHARINGAN_CACHE_DIR is "off"
+// or no user cache directory is available.
+func DefaultCacheDir() string {
+	if dir := os.Getenv("SHARINGAN_CACHE_DIR"); dir != "" {
+		if dir == "off" {
+			return ""
+		}
+		return dir
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sharingan")
+}
+
+// Analyze is like the package-level Analyze, but only parses the files that
+// changed since the repository was last analyzed through the cache. A
+// cancelled analysis leaves the cache as it was.
+//
+// Facts depend only on a file's content, so entries are shared by every build
+// configuration: the files of build are selected afresh on each analysis, and
+// those it excludes keep their entries for the next configuration.
+func (c *Cache) Analyze(ctx context.Context, repoPath string, build *BuildConfig) (*Architecture, error) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := c.repo(absPath)
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if !repo.loaded {
+		repo.entries = c.load(absPath)
+		repo.loaded = true
+	}
+
+	scan, err := scanRepository(ctx, repoPath, build, repo.entries)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed(repo.entries, scan.entries) {
+		repo.entries = scan.entries
+		// The cache is an optimisation: failing to persist it is not an error
+		_ = c.save(absPath, scan.entries)
+	}
+
+	return buildArchitecture(ctx, repoPath, scan), nil
+}
+
+func (c *Cache) repo(absPath string) *repoCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	repo, ok := c.repos[absPath]
+	if !ok {
+		repo = &repoCache{}
+		c.repos[absPath] = repo
+	}
+	return repo
+}
+
+// path returns the on-disk cache file of a repository.
+func (c *Cache) path(absPath string) string {
+	sum := sha256.Sum256([]byte(absPath))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// load reads the on-disk cache of a repository. A missing, unreadable or
+// outdated cache yields no entries.
+func (c *Cache) load(absPath string) map[string]cacheEntry {
+	if c.dir == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(c.path(absPath))
+	if err != nil {
+		return nil
+	}
+
+	var file cacheFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil
+	}
+	if file.Version != cacheVersion || file.RepoPath != absPath {
+		return nil
+	}
+	return file.Files
+}
+
+// save writes the on-disk cache of a repository, replacing it atomically.
+func (c *Cache) save(absPath string, entries map[string]cacheEntry) error {
+	if c.dir == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(cacheFile{Version: cacheVersion, RepoPath: absPath, Files: entries})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "cache-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(absPath))
+}
+
+// changed reports whether a scan produced different entries from the cached ones.
+func changed(cached, current map[string]cacheEntry) bool {
+	if len(cached) != len(current) {
+		return true
+	}
+	for path, entry := range current {
+		if cached[path].Hash != entry.Hash {
+			return true
+		}
+	}
+	return false
+}
+
+func contentHash(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,214 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+	"sort"
+)
+
+// Call is a call from a method of one component to a method of another.
+type Call struct {
+	Caller   string `json:"caller"` // Method of the calling component
+	Target   string `json:"target"` // Component called
+	Method   string `json:"method"` // Method called on the target
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+}
+
+// callFacts is a call made through a field of a method's receiver, such as
+// s.repo.Save(ctx, o) in a method of OrderService.
+type callFacts struct {
+	Receiver string `json:"receiver"` // Type the calling method is declared on
+	Caller   string `json:"caller"`
+	Field    string `json:"field"` // Receiver field the call goes through
+	Method   string `json:"method"`
+	Line     int    `json:"line"`
+}
+
+// collectCalls returns the calls every method in a file makes through fields
+// of its receiver. Calls are found syntactically, so the analysis does not
+// need the repository to build: a call is attributed to the declared type of
+// the field, which for interface fields is the interface itself.
+//
+// Calls through local variables holding a field, as in r := s.repo or
+// for _, c := range s.clients, and calls in closures inside the method count
+// as calls through the field. Calls on parameters, on values returned by
+// other calls or on package variables are not traced.
+func collectCalls(fset *token.FileSet, node *ast.File) []callFacts {
+	var calls []callFacts
+	for _, decl := range node.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 || funcDecl.Body == nil {
+			continue
+		}
+		recv := funcDecl.Recv.List[0]
+		receiver := extractTypeName(recv.Type)
+		if receiver == "" || len(recv.Names) == 0 || recv.Names[0].Name == "_" {
+			continue
+		}
+		fields := &fieldAliases{recvName: recv.Names[0].Name, locals: make(map[string]string)}
+
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if len(n.Lhs) == len(n.Rhs) {
+					for i, lhs := range n.Lhs {
+						fields.assign(lhs, n.Rhs[i])
+					}
+				}
+			case *ast.ValueSpec:
+				if len(n.Names) == len(n.Values) {
+					for i, name := range n.Names {
+						fields.assign(name, n.Values[i])
+					}
+				}
+			case *ast.RangeStmt:
+				// The value of a range over a collection field holds its elements
+				if n.Value != nil {
+					fields.assign(n.Value, n.X)
+				}
+			case *ast.CallExpr:
+				method, ok := n.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if field := fields.of(method.X); field != "" {
+					calls = append(calls, callFacts{
+						Receiver: receiver,
+						Caller:   funcDecl.Name.Name,
+						Field:    field,
+						Method:   method.Sel.Name,
+						Line:     fset.Position(n.Pos()).Line,
+					})
+				}
+			}
+			return true
+		})
+	}
+	return calls
+}
+
+// fieldAliases tracks, within one method, the local variables that hold a
+// field of the receiver. Assignments are taken in source order, which is
+// enough for the straight-line code methods mostly are.
+type fieldAliases struct {
+	recvName string
+	locals   map[string]string // Local variable to the receiver field it holds
+}
+
+// assign records that lhs now holds the field in rhs, or no field at all.
+func (f *fieldAliases) assign(lhs, rhs ast.Expr) {
+	ident, ok := lhs.(*ast.Ident)
+	if !ok || ident.Name == "_" {
+		return
+	}
+	if field := f.of(rhs); field != "" {
+		f.locals[ident.Name] = field
+	} else {
+		delete(f.locals, ident.Name)
+	}
+}
+
+// of returns the field in expressions such as s.repo or s.clients[name],
+// where s is the receiver, or in a local variable holding one, or "" for
+// anything else.
+func (f *fieldAliases) of(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.IndexExpr: // s.clients[name].Charge()
+		return f.of(t.X)
+	case *ast.ParenExpr:
+		return f.of(t.X)
+	case *ast.Ident: // r := s.repo; r.Save()
+		return f.locals[t.Name]
+	case *ast.SelectorExpr:
+		if ident, ok := t.X.(*ast.Ident); ok && ident.Name == f.recvName {
+			return t.Sel.Name
+		}
+	}
+	return ""
+}
+
+// fieldTypeName returns the named type of a struct's field, looking through
+// embedded structs for promoted fields, or "" if it has none.
+func (t *typeIndex) fieldTypeName(dir string, st structFacts, field string, visiting map[string]bool) string {
+	if typeName, ok := st.Fields[field]; ok {
+		return typeName
+	}
+
+	key := dir + "." + st.Name
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	for _, ft := range st.FieldTypes {
+		if ft.Kind != EdgeEmbeds {
+			continue
+		}
+		embedded, ok := t.lookupStruct(dir, ft.Name)
+		if !ok || visiting[embedded.dir+"."+embedded.facts.Name] {
+			continue
+		}
+		if typeName := t.fieldTypeName(embedded.dir, embedded.facts, field, visiting); typeName != "" {
+			return typeName
+		}
+	}
+	return ""
+}
+
+// callTargets resolves a call on a value of the named type to the components
+// it reaches. A composite interface reaches the constituent that declares the
+// method; if none is known to, it reaches all of them.
+func (t *typeIndex) callTargets(typeName, method string, components map[string]bool) []string {
+	if components[typeName] {
+		return []string{typeName}
+	}
+	constituents := t.constituents(typeName, components, make(map[string]bool))
+	for _, c := range constituents {
+		if slices.Contains(t.methods[c], method) {
+			return []string{c}
+		}
+	}
+	return constituents
+}
+
+// fileCall is a call together with the file it is made in.
+type fileCall struct {
+	callFacts
+	path string
+}
+
+// resolveCalls attributes the calls made by a component's methods to the
+// components it depends on, and annotates its edges with the methods used.
+func resolveCalls(comp *Component, st declaredStruct, calls []fileCall, types *typeIndex, components map[string]bool) {
+	used := make(map[string]map[string]bool)
+	for _, c := range calls {
+		typeName := types.fieldTypeName(st.dir, st.facts, c.Field, make(map[string]bool))
+		if typeName == "" {
+			continue
+		}
+		for _, target := range types.callTargets(typeName, c.Method, components) {
+			if target == comp.Name || !slices.Contains(comp.Dependencies, target) {
+				continue
+			}
+			comp.Calls = append(comp.Calls, Call{
+				Caller:   c.Caller,
+				Target:   target,
+				Method:   c.Method,
+				FilePath: c.path,
+				Line:     c.Line,
+			})
+			if used[target] == nil {
+				used[target] = make(map[string]bool)
+			}
+			used[target][c.Method] = true
+		}
+	}
+
+	for i := range comp.Edges {
+		e := &comp.Edges[i]
+		for method := range used[e.Target] {
+			e.Methods = append(e.Methods, method)
+		}
+		sort.Strings(e.Methods)
+	}
+}
@@ -0,0 +1,239 @@
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Classification is the evidence behind a component's type: which heuristic
+// in detectComponentTypeFromContext decided it, and what it matched.
+type Classification struct {
+	Rule            string `json:"rule"`                      // "middleware", "handler", "repository", "adapter", "service" or "service-fallback"
+	PackageKeyword  string `json:"packageKeyword,omitempty"`  // Keyword found in the package path, e.g. "http"
+	PackageSegment  string `json:"packageSegment,omitempty"`  // Path element containing the keyword, e.g. "httpapi"
+	NameMatch       string `json:"nameMatch,omitempty"`       // What matched in the name, e.g. `suffix "Service"`
+	Dependencies    int    `json:"dependencies"`              // Dependencies found when classified, before unresolved ones were dropped
+	MinDependencies int    `json:"minDependencies,omitempty"` // Dependencies the rule requires
+	ConfigRule      string `json:"configRule,omitempty"`      // How the config-package rule applied, if it did
+	Reason          string `json:"reason"`
+}
+
+// Check is one heuristic evaluated while classifying a struct.
+type Check struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Detail  string `json:"detail"`
+}
+
+// FieldEvidence explains whether a field type counts as a dependency.
+type FieldEvidence struct {
+	Type       string   `json:"type"`
+	Kind       EdgeKind `json:"kind"`
+	Direction  string   `json:"direction,omitempty"`
+	Via        string   `json:"via,omitempty"` // Embedded struct the field was inherited from
+	Dependency bool     `json:"dependency"`
+	Reason     string   `json:"reason"`
+}
+
+// Explanation traces how the analysis treated a struct, whether it became a
+// component, was excluded as noise or matched no heuristic.
+type Explanation struct {
+	Name      string        `json:"name"`
+	Package   string        `json:"package"`
+	FilePath  string        `json:"filePath"`
+	Line      int           `json:"line"`
+	Component bool          `json:"component"`
+	Type      ComponentType `json:"type,omitempty"`
+	Summary   string        `json:"summary"`
+
+	Excluded       string          `json:"excluded,omitempty"` // Why shouldSkipStruct dropped it
+	Fields         []FieldEvidence `json:"fields,omitempty"`
+	Checks         []Check         `json:"checks,omitempty"` // Heuristics in the order they were tried
+	Classification *Classification `json:"classification,omitempty"`
+	Dropped        []string        `json:"droppedDependencies,omitempty"` // Dependencies that are not components
+}
+
+// Explain returns the explanations for every struct with the given name. There
+// is more than one when packages declare structs of the same name.
+func (a *Architecture) Explain(name string) []Explanation {
+	var explanations []Explanation
+	for _, e := range a.explanations {
+		if e.Name == name {
+			explanations = append(explanations, e)
+		}
+	}
+	return explanations
+}
+
+// Keywords and name patterns used by detectComponentTypeFromContext.
+var (
+	middlewarePathKeywords = []string{"middleware"}
+	handlerPathKeywords    = []string{"transport", "http", "handler", "api"}
+	handlerNameKeywords    = []string{"server", "handler"}
+	repositoryPathKeywords = []string{"persistence", "repository", "repo", "store"}
+	repositoryNameSuffixes = []string{"Repository", "Store"}
+	adapterPathKeywords    = []string{"adapter", "client", "external", "integration"}
+	servicePathKeywords    = []string{"service", "usecase"}
+)
+
+// detectComponentTypeFromContext classifies a struct by its package path, name
+// and dependencies. middlewareShape describes how the struct wraps handlers,
+// if it does; see typeIndex.middlewareShape. It returns "" for structs that
+// are not components, along with the evidence for the decision and every
+// heuristic tried.
+func detectComponentTypeFromContext(pkgPath, structName string, deps []string, middlewareShape string) (ComponentType, Classification, []Check) {
+	lower := strings.ToLower(pkgPath)
+	nameLower := strings.ToLower(structName)
+	var checks []Check
+
+	// Middleware, which would otherwise pass for handlers. Options, configs
+	// and DTOs share its packages, so it needs a dependency or the shape of
+	// middleware too.
+	middleware := Classification{Rule: "middleware", Dependencies: len(deps)}
+	matchPath(&middleware, pkgPath, middlewarePathKeywords)
+	if middleware.PackageKeyword == "" && strings.Contains(nameLower, "middleware") {
+		middleware.NameMatch = `contains "middleware"`
+	}
+	switch {
+	case !middleware.matched():
+		checks = append(checks, Check{Rule: "middleware", Detail: "package path and name do not contain middleware"})
+	case middlewareShape != "":
+		middleware.Reason = middleware.describe() + ", with the shape of middleware: " + middlewareShape
+		return ComponentMiddleware, middleware, append(checks, Check{Rule: "middleware", Matched: true, Detail: middleware.Reason})
+	case len(deps) == 0:
+		checks = append(checks, Check{Rule: "middleware", Detail: middleware.describe() + ", but it has no dependencies and neither wraps a handler nor serves HTTP"})
+	default:
+		middleware.Reason = middleware.describe() + ", with " + dependencies(len(deps))
+		return ComponentMiddleware, middleware, append(checks, Check{Rule: "middleware", Matched: true, Detail: middleware.Reason})
+	}
+
+	// Handler/Transport layer
+	handler := Classification{Rule: "handler", Dependencies: len(deps), MinDependencies: 1}
+	matchPath(&handler, pkgPath, handlerPathKeywords)
+	if handler.PackageKeyword == "" {
+		if kw := findKeyword(nameLower, handlerNameKeywords); kw != "" {
+			handler.NameMatch = fmt.Sprintf("contains %q", kw)
+		}
+	}
+	switch {
+	case !handler.matched():
+		checks = append(checks, Check{Rule: "handler", Detail: "package path contains none of transport, http, handler, api; name contains neither server nor handler"})
+	case len(deps) == 0: // Handlers should have dependencies
+		checks = append(checks, Check{Rule: "handler", Detail: handler.describe() + ", but it has no dependencies and handlers need at least 1"})
+	default:
+		handler.Reason = handler.describe() + ", with " + dependencies(len(deps))
+		return ComponentHandler, handler, append(checks, Check{Rule: "handler", Matched: true, Detail: handler.Reason})
+	}
+
+	// Repository/Persistence layer (check before service)
+	// But not if it's in a config package
+	configRule := ""
+	if strings.Contains(lower, "config") {
+		configRule = `repository rule skipped: package path contains "config"`
+		checks = append(checks, Check{Rule: "repository", Detail: configRule})
+	} else {
+		repository := Classification{Rule: "repository", Dependencies: len(deps), ConfigRule: `package path does not contain "config"`}
+		matchPath(&repository, pkgPath, repositoryPathKeywords)
+		if repository.PackageKeyword == "" {
+			if structName == "DB" {
+				repository.NameMatch = `is "DB"`
+			} else if suffix := findSuffix(structName, repositoryNameSuffixes); suffix != "" {
+				repository.NameMatch = fmt.Sprintf("suffix %q", suffix)
+			}
+		}
+		if repository.matched() {
+			repository.Reason = repository.describe()
+			return ComponentRepository, repository, append(checks, Check{Rule: "repository", Matched: true, Detail: repository.Reason})
+		}
+		checks = append(checks, Check{Rule: "repository", Detail: `package path contains none of persistence, repository, repo, store; name is not "DB" and does not end in Repository or Store`})
+	}
+
+	// Adapter layer
+	adapter := Classification{Rule: "adapter", Dependencies: len(deps), ConfigRule: configRule}
+	matchPath(&adapter, pkgPath, adapterPathKeywords)
+	if adapter.matched() {
+		adapter.Reason = adapter.describe()
+		return ComponentAdapter, adapter, append(checks, Check{Rule: "adapter", Matched: true, Detail: adapter.Reason})
+	}
+	checks = append(checks, Check{Rule: "adapter", Detail: "package path contains none of adapter, client, external, integration"})
+
+	// Service layer
+	service := Classification{Rule: "service", Dependencies: len(deps), MinDependencies: 1, ConfigRule: configRule}
+	matchPath(&service, pkgPath, servicePathKeywords)
+	if service.PackageKeyword == "" && strings.HasSuffix(structName, "Service") {
+		service.NameMatch = `suffix "Service"`
+	}
+	switch {
+	case !service.matched():
+		checks = append(checks, Check{Rule: "service", Detail: "package path contains neither service nor usecase; name does not end in Service"})
+	case len(deps) == 0: // Services should have dependencies
+		checks = append(checks, Check{Rule: "service", Detail: service.describe() + ", but it has no dependencies and services need at least 1"})
+	default:
+		service.Reason = service.describe() + ", with " + dependencies(len(deps))
+		return ComponentService, service, append(checks, Check{Rule: "service", Matched: true, Detail: service.Reason})
+	}
+
+	// If it has multiple dependencies, it's likely a service
+	fallback := Classification{Rule: "service-fallback", Dependencies: len(deps), MinDependencies: 2, ConfigRule: configRule}
+	if len(deps) >= 2 {
+		fallback.Reason = fmt.Sprintf("no layer matched, but it has %s (at least 2)", dependencies(len(deps)))
+		return ComponentService, fallback, append(checks, Check{Rule: "service-fallback", Matched: true, Detail: fallback.Reason})
+	}
+	checks = append(checks, Check{Rule: "service-fallback", Detail: fmt.Sprintf("%s, fewer than the 2 needed to be treated as a service", dependencies(len(deps)))})
+
+	return "", Classification{}, checks // Not an architectural component
+}
+
+// matchPath records the first keyword found in the package path, and the
+// path element containing it.
+func matchPath(c *Classification, pkgPath string, keywords []string) {
+	kw := findKeyword(strings.ToLower(pkgPath), keywords)
+	if kw == "" {
+		return
+	}
+	c.PackageKeyword = kw
+	for _, segment := range strings.Split(filepath.ToSlash(pkgPath), "/") {
+		if strings.Contains(strings.ToLower(segment), kw) {
+			c.PackageSegment = segment
+			return
+		}
+	}
+}
+
+func (c Classification) matched() bool {
+	return c.PackageKeyword != "" || c.NameMatch != ""
+}
+
+// describe says what matched, e.g. `package path segment "httpapi" contains "http"`.
+func (c Classification) describe() string {
+	if c.PackageKeyword != "" {
+		return fmt.Sprintf("package path segment %q contains %q", c.PackageSegment, c.PackageKeyword)
+	}
+	return "name " + c.NameMatch
+}
+
+func dependencies(n int) string {
+	if n == 1 {
+		return "1 dependency"
+	}
+	return fmt.Sprintf("%d dependencies", n)
+}
+
+func findKeyword(s string, keywords []string) string {
+	for _, kw := range keywords {
+		if strings.Contains(s, kw) {
+			return kw
+		}
+	}
+	return ""
+}
+
+func findSuffix(name string, suffixes []string) string {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DiagnosticKind classifies a diagnostic.
+type DiagnosticKind string
+
+const (
+	DiagnosticParseError           DiagnosticKind = "parse-error"           // A source file does not parse
+	DiagnosticReadError            DiagnosticKind = "read-error"            // A file or directory could not be read
+	DiagnosticSkippedDirectory     DiagnosticKind = "skipped-directory"     // A directory the analysis does not descend into
+	DiagnosticExcludedFile         DiagnosticKind = "excluded-file"         // A source file outside the build configuration
+	DiagnosticExcludedStruct       DiagnosticKind = "excluded-struct"       // A struct dropped as noise by shouldSkipStruct
+	DiagnosticUnresolvedDependency DiagnosticKind = "unresolved-dependency" // A dependency that is not a component
+)
+
+// Diagnostic records something the analysis skipped, dropped or failed on,
+// so that a missing component or dependency can be traced back to its cause.
+type Diagnostic struct {
+	Kind      DiagnosticKind `json:"kind"`
+	FilePath  string         `json:"filePath"` // Relative to the repository
+	Line      int            `json:"line,omitempty"`
+	Component string         `json:"component,omitempty"` // The struct or component concerned
+	Target    string         `json:"target,omitempty"`    // The unresolved dependency
+	Message   string         `json:"message"`
+}
+
+// DiagnosticKinds lists the kinds in the order they are presented.
+var DiagnosticKinds = []DiagnosticKind{
+	DiagnosticParseError,
+	DiagnosticReadError,
+	DiagnosticUnresolvedDependency,
+	DiagnosticExcludedStruct,
+	DiagnosticSkippedDirectory,
+	DiagnosticExcludedFile,
+}
+
+// DiagnosticCounts returns the number of diagnostics of each kind.
+func (a *Architecture) DiagnosticCounts() map[DiagnosticKind]int {
+	counts := make(map[DiagnosticKind]int)
+	for _, d := range a.Diagnostics {
+		counts[d.Kind]++
+	}
+	return counts
+}
+
+// unresolvedDependency describes a dependency dropped because no component has its name.
+func unresolvedDependency(comp Component, dep string, interfaces map[string]bool) Diagnostic {
+	reason := "is not declared in the repository or is not a component"
+	if interfaces[dep] {
+		reason = "is an interface, and no component has its name"
+	}
+	return Diagnostic{
+		Kind:      DiagnosticUnresolvedDependency,
+		FilePath:  comp.FilePath,
+		Line:      comp.Line,
+		Component: comp.Name,
+		Target:    dep,
+		Message:   fmt.Sprintf("dependency %s of %s was dropped: it %s", dep, comp.Name, reason),
+	}
+}
+
+// sortDiagnostics orders diagnostics by file and line, keeping the order in
+// which they were found for the same position.
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].FilePath != diagnostics[j].FilePath {
+			return diagnostics[i].FilePath < diagnostics[j].FilePath
+		}
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+}
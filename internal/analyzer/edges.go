@@ -0,0 +1,186 @@
+package analyzer
+
+import (
+	"go/ast"
+)
+
+// EdgeKind describes how a component holds a dependency.
+type EdgeKind string
+
+const (
+	EdgeDirect     EdgeKind = "direct"     // A field of the dependency's type, or a pointer to it
+	EdgeCollection EdgeKind = "collection" // A slice, array or map of the dependency: one-to-many
+	EdgeCallback   EdgeKind = "callback"   // A function that takes or returns the dependency
+	EdgeChannel    EdgeKind = "channel"    // A channel of the dependency
+	EdgeEmbeds     EdgeKind = "embeds"     // An embedded field of the dependency's type
+)
+
+// Channel directions, as seen from the component holding the channel.
+const (
+	DirectionSend    = "send"    // chan<- T
+	DirectionReceive = "receive" // <-chan T
+	DirectionBoth    = "both"    // chan T
+)
+
+// Edge annotates a dependency with how it is held.
+type Edge struct {
+	Target    string   `json:"target"`
+	Kind      EdgeKind `json:"kind"`
+	Direction string   `json:"direction,omitempty"` // For channels: send, receive or both
+	Via       string   `json:"via,omitempty"`       // Embedded struct or composite interface the dependency was reached through
+	Methods   []string `json:"methods,omitempty"`   // Methods of the dependency the component calls
+
+	CrossModule  bool   `json:"crossModule,omitempty"`  // The dependency is in another module
+	TargetModule string `json:"targetModule,omitempty"` // That module's path, for cross-module dependencies
+}
+
+// Edge returns how the component holds the named dependency. Dependencies
+// without an annotation are direct.
+func (c Component) Edge(dep string) Edge {
+	for _, e := range c.Edges {
+		if e.Target == dep {
+			return e
+		}
+	}
+	return Edge{Target: dep, Kind: EdgeDirect}
+}
+
+// fieldType is a named type a struct field refers to, and how.
+type fieldType struct {
+	Name      string   `json:"name"`
+	Kind      EdgeKind `json:"kind"`
+	Direction string   `json:"direction,omitempty"`
+}
+
+// fieldTypes returns the distinct named types of a struct's fields, in order.
+// Fields typed by one of the struct's type parameters are left out.
+func fieldTypes(structType *ast.StructType, params map[string]bool) []fieldType {
+	types := []fieldType{}
+	if structType.Fields == nil {
+		return types
+	}
+
+	seen := make(map[string]bool)
+	for _, field := range structType.Fields.List {
+		named := fieldTypesOf(field.Type)
+		if len(field.Names) == 0 {
+			// Embedded fields can only be a named type or a pointer to one
+			named = wrapFieldTypes(named, EdgeEmbeds, "")
+		}
+		for _, ft := range named {
+			if seen[ft.Name] || params[ft.Name] {
+				continue
+			}
+			types = append(types, ft)
+			seen[ft.Name] = true
+		}
+	}
+	return types
+}
+
+// fieldTypesOf returns the named types in a field's type. Slices, arrays and
+// maps are looked through to their elements, channels to the values they
+// carry, and function types to their parameters and results. The outermost
+// of these decides the kind, so []func() Client is a collection.
+func fieldTypesOf(expr ast.Expr) []fieldType {
+	switch t := expr.(type) {
+	case *ast.ArrayType:
+		return wrapFieldTypes(fieldTypesOf(t.Elt), EdgeCollection, "")
+	case *ast.MapType:
+		// Keys are almost always names or IDs; the values are what is held
+		return wrapFieldTypes(fieldTypesOf(t.Value), EdgeCollection, "")
+	case *ast.ChanType:
+		direction := DirectionBoth
+		switch t.Dir {
+		case ast.SEND:
+			direction = DirectionSend
+		case ast.RECV:
+			direction = DirectionReceive
+		}
+		return wrapFieldTypes(fieldTypesOf(t.Value), EdgeChannel, direction)
+	case *ast.FuncType:
+		var types []fieldType
+		for _, list := range []*ast.FieldList{t.Params, t.Results} {
+			if list == nil {
+				continue
+			}
+			for _, param := range list.List {
+				types = append(types, fieldTypesOf(param.Type)...)
+			}
+		}
+		return wrapFieldTypes(types, EdgeCallback, "")
+	case *ast.Ellipsis:
+		return fieldTypesOf(t.Elt)
+	case *ast.StarExpr:
+		return fieldTypesOf(t.X)
+	}
+
+	if name := extractTypeName(expr); name != "" {
+		return []fieldType{{Name: name, Kind: EdgeDirect}}
+	}
+	return nil
+}
+
+func wrapFieldTypes(types []fieldType, kind EdgeKind, direction string) []fieldType {
+	for i := range types {
+		types[i].Kind, types[i].Direction = kind, direction
+	}
+	return types
+}
+
+// interfaceMethods returns the names of the methods an interface declares,
+// not counting those of interfaces it embeds.
+func interfaceMethods(iface *ast.InterfaceType) []string {
+	var methods []string
+	if iface.Methods == nil {
+		return methods
+	}
+	for _, field := range iface.Methods.List {
+		for _, name := range field.Names {
+			methods = append(methods, name.Name)
+		}
+	}
+	return methods
+}
+
+// fieldNames maps each named field of a struct, embedded ones included, to
+// the named type it holds. Fields such as func or channel types that mention
+// several named types are left out.
+func fieldNames(structType *ast.StructType) map[string]string {
+	names := make(map[string]string)
+	if structType.Fields == nil {
+		return names
+	}
+	for _, field := range structType.Fields.List {
+		types := fieldTypesOf(field.Type)
+		if len(types) != 1 {
+			continue
+		}
+		if len(field.Names) == 0 {
+			names[types[0].Name] = types[0].Name // An embedded field is named after its type
+		}
+		for _, name := range field.Names {
+			names[name.Name] = types[0].Name
+		}
+	}
+	return names
+}
+
+// embeddedInterfaces returns the named interfaces an interface embeds, such as
+// Reader and Writer in interface { Reader; Writer }. Type sets in constraints,
+// such as ~int | ~string, are not interfaces and are left out.
+func embeddedInterfaces(iface *ast.InterfaceType) []string {
+	var embeds []string
+	if iface.Methods == nil {
+		return embeds
+	}
+	for _, field := range iface.Methods.List {
+		if len(field.Names) > 0 {
+			continue // A method
+		}
+		if name := extractTypeName(field.Type); name != "" && !isPredeclared(field.Type) {
+			embeds = append(embeds, name)
+		}
+	}
+	return embeds
+}
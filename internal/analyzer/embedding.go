@@ -0,0 +1,159 @@
+package analyzer
+
+import (
+	"path/filepath"
+)
+
+// typeIndex holds the declarations from the whole codebase that deciding a
+// struct's dependencies needs: which names are interfaces, what composite
+// interfaces embed, and the structs that may be embedded.
+type typeIndex struct {
+	interfaces map[string]bool
+	composites map[string][]string         // Interface name to the interfaces it embeds
+	methods    map[string][]string         // Interface name to the methods it declares
+	structs    map[string][]declaredStruct // Struct name to its declarations
+	functions  map[string]declaredStruct   // Function components by methodKey, their captured parameters as fields
+
+	// Struct methods by methodKey that wrap handlers, and structs that serve HTTP
+	middlewareMethods map[string]string
+	servesHTTP        map[string]bool
+}
+
+type declaredStruct struct {
+	dir   string // Package directory, relative to the repository
+	facts structFacts
+}
+
+func newTypeIndex(files []fileFacts) *typeIndex {
+	types := &typeIndex{
+		interfaces: make(map[string]bool),
+		composites: make(map[string][]string),
+		methods:    make(map[string][]string),
+		structs:    make(map[string][]declaredStruct),
+		functions:  make(map[string]declaredStruct),
+
+		middlewareMethods: make(map[string]string),
+		servesHTTP:        make(map[string]bool),
+	}
+	for _, file := range files {
+		for _, name := range file.Interfaces {
+			types.interfaces[name] = true
+		}
+		for name, embeds := range file.InterfaceEmbeds {
+			types.composites[name] = embeds
+		}
+		for name, methods := range file.InterfaceMethods {
+			types.methods[name] = methods
+		}
+		for _, st := range file.Structs {
+			types.structs[st.Name] = append(types.structs[st.Name], declaredStruct{dir: filepath.Dir(file.Path), facts: st})
+		}
+		for _, fn := range file.Funcs {
+			dir := filepath.Dir(file.Path)
+			types.functions[methodKey(dir, fn.Name)] = declaredStruct{dir: dir, facts: structFacts{Name: fn.Name, Fields: fn.ParamNames}}
+		}
+		for _, m := range file.Methods {
+			key := methodKey(filepath.Dir(file.Path), m.Receiver)
+			if m.Middleware && types.middlewareMethods[key] == "" {
+				types.middlewareMethods[key] = m.Signature
+			}
+			if m.ServesHTTP {
+				types.servesHTTP[key] = true
+			}
+		}
+	}
+	return types
+}
+
+// middlewareShape describes how a struct has the shape of middleware, with a
+// method wrapping a handler or a ServeHTTP method and a handler to pass
+// requests on to, or returns "" if it has neither.
+func (t *typeIndex) middlewareShape(dir string, st structFacts) string {
+	key := methodKey(dir, st.Name)
+	if sig := t.middlewareMethods[key]; sig != "" {
+		return "method " + sig
+	}
+	if t.servesHTTP[key] && st.WrapsHandler {
+		return "ServeHTTP with a wrapped handler"
+	}
+	return ""
+}
+
+// lookupStruct finds the struct an embedded field refers to, preferring one
+// declared in the embedding struct's package.
+func (t *typeIndex) lookupStruct(dir, name string) (declaredStruct, bool) {
+	candidates := t.structs[name]
+	for _, c := range candidates {
+		if c.dir == dir {
+			return c, true
+		}
+	}
+	if len(candidates) == 0 {
+		return declaredStruct{}, false
+	}
+	return candidates[0], true
+}
+
+// dependencies returns a struct's dependencies, including those it inherits
+// from embedded structs, with the evidence for each field. Inherited edges and
+// evidence name the embedded struct they came through in Via. visiting guards
+// against embedding cycles.
+func (t *typeIndex) dependencies(dir string, st structFacts, visiting map[string]bool) ([]Edge, []FieldEvidence) {
+	edges, fields := extractInterfaceDependencies(st.FieldTypes, t.interfaces)
+
+	key := dir + "." + st.Name
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	seen := map[string]bool{st.Name: true}
+	for _, e := range edges {
+		seen[e.Target] = true
+	}
+	for _, ft := range st.FieldTypes {
+		if ft.Kind != EdgeEmbeds {
+			continue
+		}
+		embedded, ok := t.lookupStruct(dir, ft.Name)
+		if !ok || visiting[embedded.dir+"."+embedded.facts.Name] {
+			continue
+		}
+
+		inheritedEdges, inheritedFields := t.dependencies(embedded.dir, embedded.facts, visiting)
+		for _, e := range inheritedEdges {
+			if seen[e.Target] {
+				continue
+			}
+			seen[e.Target] = true
+			if e.Via == "" {
+				e.Via = ft.Name
+			}
+			edges = append(edges, e)
+		}
+		for _, f := range inheritedFields {
+			if f.Via == "" {
+				f.Via = ft.Name
+			}
+			fields = append(fields, f)
+		}
+	}
+	return edges, fields
+}
+
+// constituents resolves a composite interface to the components among the
+// interfaces it embeds, directly or through other composites.
+func (t *typeIndex) constituents(name string, components map[string]bool, seen map[string]bool) []string {
+	if seen[name] {
+		return nil
+	}
+	seen[name] = true
+
+	var resolved []string
+	for _, embedded := range t.composites[name] {
+		if components[embedded] {
+			resolved = append(resolved, embedded)
+			continue
+		}
+		resolved = append(resolved, t.constituents(embedded, components, seen)...)
+	}
+	return resolved
+}
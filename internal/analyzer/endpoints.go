@@ -0,0 +1,270 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// EndpointKind is the protocol services talk to each other over.
+type EndpointKind string
+
+const (
+	EndpointGRPC  EndpointKind = "grpc"  // A gRPC service, e.g. "PaymentService"
+	EndpointHTTP  EndpointKind = "http"  // An HTTP base URL a client calls
+	EndpointTopic EndpointKind = "topic" // A message topic or subject
+)
+
+// Endpoint roles.
+const (
+	RoleProvides = "provides" // Serves the gRPC service, or consumes from the topic
+	RoleConsumes = "consumes" // Calls the gRPC service or URL, or produces to the topic
+)
+
+// Endpoint is a point where a service meets others: a gRPC service it serves
+// or calls, a URL it calls, or a topic it produces to or consumes from. The
+// routes a service serves are its Routes. For topics, the consumer provides
+// the endpoint and the producer consumes it, so that every link runs from the
+// consuming role to the providing one, as requests and messages flow.
+type Endpoint struct {
+	Kind     EndpointKind `json:"kind"`
+	Role     string       `json:"role"`
+	Name     string       `json:"name"` // gRPC service, URL or topic
+	FilePath string       `json:"filePath"`
+	Line     int          `json:"line"`
+}
+
+// Method names of messaging clients whose first argument is the topic, for
+// NATS, sarama, Google Pub/Sub and similar libraries.
+var (
+	producerMethods = map[string]bool{"Publish": true, "PublishMsg": true, "Produce": true, "Topic": true}
+	consumerMethods = map[string]bool{"Subscribe": true, "QueueSubscribe": true, "ChanSubscribe": true, "ConsumePartition": true}
+)
+
+// httpCalls are the functions and methods that take a URL to request, named
+// as in net/http: http.Get and http.NewRequest, or a client's Get and Post.
+var httpCalls = map[string]bool{
+	"Get": true, "Head": true, "Post": true, "PostForm": true, "Put": true, "Patch": true, "Delete": true,
+	"NewRequest": true, "NewRequestWithContext": true,
+}
+
+// urlLit is a URL and the literal it was written in.
+type urlLit struct {
+	url string
+	pos token.Pos
+}
+
+// collectEndpoints returns the endpoints a file declares:
+//   - gRPC servers registered with RegisterXServer(srv, impl), and clients
+//     created with NewXClient(conn), as protoc-gen-go-grpc generates them
+//   - HTTP and HTTPS URLs requested with one of httpCalls, such as
+//     http.Get(url) or http.NewRequest("GET", url, nil)
+//   - topics given to producer and consumer methods, or in the Topic field of
+//     a Writer, Reader, Producer or Consumer config, as with kafka-go
+//
+// Topics may be string literals or constants declared in the file. URLs may
+// also reach the call through a variable or struct field assigned in the
+// file, with a path appended, as in c.baseURL + "/orders"; URLs that are
+// never requested, such as links in docs or schema IDs, are left out.
+func collectEndpoints(fset *token.FileSet, node *ast.File) []Endpoint {
+	consts := make(map[string]string)
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if i < len(vs.Values) {
+					if s, ok := stringLit(vs.Values[i]); ok {
+						consts[name.Name] = s
+					}
+				}
+			}
+		}
+	}
+	value := func(expr ast.Expr) (string, bool) {
+		if ident, ok := expr.(*ast.Ident); ok {
+			s, ok := consts[ident.Name]
+			return s, ok
+		}
+		return stringLit(expr)
+	}
+
+	urls := collectURLs(node)
+
+	var endpoints []Endpoint
+	add := func(kind EndpointKind, role, name string, pos token.Pos) {
+		if name != "" {
+			endpoints = append(endpoints, Endpoint{Kind: kind, Role: role, Name: name, Line: fset.Position(pos).Line})
+		}
+	}
+
+	requested := make(map[urlLit]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.CallExpr:
+			name := extractTypeName(t.Fun)
+			if _, isSel := t.Fun.(*ast.SelectorExpr); !isSel {
+				break
+			}
+			if httpCalls[name] {
+				for _, arg := range t.Args {
+					if u, ok := urls.of(arg); ok && !requested[u] {
+						requested[u] = true
+						add(EndpointHTTP, RoleConsumes, u.url, u.pos)
+					}
+				}
+			}
+			switch {
+			case len(t.Args) == 2 && strings.HasPrefix(name, "Register") && strings.HasSuffix(name, "Server"):
+				add(EndpointGRPC, RoleProvides, strings.TrimSuffix(strings.TrimPrefix(name, "Register"), "Server"), t.Pos())
+			case len(t.Args) == 1 && strings.HasPrefix(name, "New") && strings.HasSuffix(name, "Client") && isGRPCConn(t.Args[0]):
+				add(EndpointGRPC, RoleConsumes, strings.TrimSuffix(strings.TrimPrefix(name, "New"), "Client"), t.Pos())
+			case len(t.Args) > 0 && (producerMethods[name] || consumerMethods[name]):
+				if topic, ok := value(t.Args[0]); ok {
+					role := RoleConsumes
+					if consumerMethods[name] {
+						role = RoleProvides
+					}
+					add(EndpointTopic, role, topic, t.Pos())
+				}
+			}
+		case *ast.CompositeLit:
+			typeName := extractTypeName(t.Type)
+			role := ""
+			switch {
+			case strings.Contains(typeName, "Writer") || strings.Contains(typeName, "Producer"):
+				role = RoleConsumes
+			case strings.Contains(typeName, "Reader") || strings.Contains(typeName, "Consumer"):
+				role = RoleProvides
+			}
+			if role == "" {
+				break
+			}
+			for _, elt := range t.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "Topic" {
+					continue
+				}
+				if topic, ok := value(kv.Value); ok {
+					add(EndpointTopic, role, topic, kv.Pos())
+				}
+			}
+		}
+		return true
+	})
+	return endpoints
+}
+
+// urlValues maps the variables, constants and struct fields of a file that
+// are assigned a URL, by name, fields with a leading dot, to that URL.
+type urlValues map[string]urlLit
+
+// collectURLs finds the names in a file that hold URLs. Names are not scoped:
+// a variable assigned a URL anywhere in the file holds it everywhere, which
+// is what base URLs in client structs and constants need.
+func collectURLs(node *ast.File) urlValues {
+	urls := make(urlValues)
+	assign := func(lhs, rhs ast.Expr) {
+		u, ok := urls.of(rhs)
+		if !ok {
+			return
+		}
+		switch t := lhs.(type) {
+		case *ast.Ident: // base := "http://..."
+			urls[t.Name] = u
+		case *ast.SelectorExpr: // c.baseURL = "http://..."
+			urls["."+t.Sel.Name] = u
+		}
+	}
+	// Twice, so that names assigned from names declared later resolve too
+	for range 2 {
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch t := n.(type) {
+			case *ast.ValueSpec:
+				for i, name := range t.Names {
+					if i < len(t.Values) {
+						assign(name, t.Values[i])
+					}
+				}
+			case *ast.AssignStmt:
+				if len(t.Lhs) == len(t.Rhs) {
+					for i := range t.Lhs {
+						assign(t.Lhs[i], t.Rhs[i])
+					}
+				}
+			case *ast.KeyValueExpr: // Client{BaseURL: "http://..."}
+				if key, ok := t.Key.(*ast.Ident); ok {
+					if u, ok := urls.of(t.Value); ok {
+						urls["."+key.Name] = u
+					}
+				}
+			}
+			return true
+		})
+	}
+	return urls
+}
+
+// of returns the URL an expression evaluates to: a URL literal, a name
+// holding one, one with a literal path appended, or a format string given
+// to fmt.Sprintf.
+func (urls urlValues) of(expr ast.Expr) (urlLit, bool) {
+	switch t := expr.(type) {
+	case *ast.BasicLit:
+		if s, ok := stringLit(t); ok && isURL(s) {
+			return urlLit{url: s, pos: t.Pos()}, true
+		}
+	case *ast.Ident:
+		u, ok := urls[t.Name]
+		return u, ok
+	case *ast.SelectorExpr:
+		u, ok := urls["."+t.Sel.Name]
+		return u, ok
+	case *ast.ParenExpr:
+		return urls.of(t.X)
+	case *ast.BinaryExpr:
+		if t.Op != token.ADD {
+			break
+		}
+		u, ok := urls.of(t.X)
+		if suffix, isLit := stringLit(t.Y); ok && isLit {
+			u.url += suffix
+		}
+		return u, ok
+	case *ast.CallExpr:
+		if extractTypeName(t.Fun) == "Sprintf" && len(t.Args) > 0 {
+			return urls.of(t.Args[0])
+		}
+	}
+	return urlLit{}, false
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// isGRPCConn reports whether a client constructor's argument looks like a
+// gRPC connection: a variable named conn, cc or similar, or a grpc call.
+func isGRPCConn(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		name := strings.ToLower(t.Name)
+		return strings.Contains(name, "conn") || name == "cc"
+	case *ast.SelectorExpr:
+		return isGRPCConn(t.Sel)
+	case *ast.CallExpr:
+		sel, ok := t.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		return ok && pkg.Name == "grpc"
+	}
+	return false
+}
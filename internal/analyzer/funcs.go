@@ -0,0 +1,308 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// funcFacts describes a function that acts as a component: an HTTP handler
+// or middleware declared as a plain function, or a constructor returning a
+// closure, such as func NewOrderHandler(svc OrderService) http.HandlerFunc.
+type funcFacts struct {
+	Name       string `json:"name"`
+	Line       int    `json:"line"`
+	Doc        string `json:"doc"`
+	Framework  string `json:"framework"`            // Whose handler signature it has or returns, e.g. "net/http"
+	Closure    bool   `json:"closure"`              // A constructor returning a closure
+	Middleware bool   `json:"middleware,omitempty"` // Wraps a handler, or returns something that does
+
+	// The constructor parameters the closure captures, which are its
+	// dependencies, and their names for attributing calls
+	Params     []fieldType       `json:"params,omitempty"`
+	ParamNames map[string]string `json:"paramNames,omitempty"`
+}
+
+// handlerTypes are the named handler types of common HTTP frameworks, by
+// package name and type.
+var handlerTypes = map[string]string{
+	"http.HandlerFunc": "net/http",
+	"http.Handler":     "net/http",
+	"gin.HandlerFunc":  "gin",
+	"echo.HandlerFunc": "echo",
+	"fiber.Handler":    "fiber",
+	"chi.HandlerFunc":  "chi",
+}
+
+// middlewareTypes are the named middleware types of common HTTP frameworks.
+var middlewareTypes = map[string]string{
+	"mux.MiddlewareFunc":  "gorilla/mux",
+	"echo.MiddlewareFunc": "echo",
+}
+
+// middlewareSignature returns the framework whose middleware signature a
+// function type has, taking and returning a handler as
+// func(next http.Handler) http.Handler does, or "" if it has none.
+func middlewareSignature(ft *ast.FuncType) string {
+	if ft.Params == nil || len(ft.Params.List) != 1 || len(ft.Params.List[0].Names) > 1 {
+		return ""
+	}
+	if ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 {
+		return ""
+	}
+	framework := handlerTypes[qualifiedTypeName(ft.Params.List[0].Type)]
+	if framework == "" || handlerTypes[qualifiedTypeName(ft.Results.List[0].Type)] != framework {
+		return ""
+	}
+	return framework
+}
+
+// middlewareResult returns the framework whose middleware a function returns,
+// as a named middleware type or a function type with a middleware signature.
+func middlewareResult(ft *ast.FuncType) string {
+	if ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 {
+		return ""
+	}
+	result := ft.Results.List[0].Type
+	if lit, ok := result.(*ast.FuncType); ok {
+		return middlewareSignature(lit)
+	}
+	return middlewareTypes[qualifiedTypeName(result)]
+}
+
+// handlerSignature returns the framework whose handler signature a function
+// type has, or "" if it has none.
+func handlerSignature(ft *ast.FuncType) string {
+	var params []string
+	if ft.Params != nil {
+		for _, field := range ft.Params.List {
+			typeName := qualifiedTypeName(field.Type)
+			for range max(len(field.Names), 1) {
+				params = append(params, typeName)
+			}
+		}
+	}
+	switch strings.Join(params, ",") {
+	case "http.ResponseWriter,*http.Request":
+		return "net/http"
+	case "*gin.Context":
+		return "gin"
+	case "echo.Context":
+		return "echo"
+	case "*fiber.Ctx":
+		return "fiber"
+	}
+	return ""
+}
+
+// handlerResult returns the framework whose handler a function returns, as a
+// named handler type or a function type with a handler signature.
+func handlerResult(ft *ast.FuncType) string {
+	if ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 {
+		return ""
+	}
+	result := ft.Results.List[0].Type
+	if lit, ok := result.(*ast.FuncType); ok {
+		return handlerSignature(lit)
+	}
+	return handlerTypes[qualifiedTypeName(result)]
+}
+
+// wrapsHandler reports whether a struct has a field holding a handler, like
+// the next http.Handler a ServeHTTP middleware passes requests on to.
+func wrapsHandler(structType *ast.StructType) bool {
+	if structType.Fields == nil {
+		return false
+	}
+	for _, field := range structType.Fields.List {
+		if handlerTypes[qualifiedTypeName(field.Type)] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// qualifiedTypeName prints a type as written, e.g. "*http.Request", for the
+// handful of shapes handler signatures use.
+func qualifiedTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + qualifiedTypeName(t.X)
+	case *ast.SelectorExpr:
+		return qualifiedTypeName(t.X) + "." + t.Sel.Name
+	}
+	return ""
+}
+
+// collectFunctions returns the exported functions in a file that act as
+// components, and the calls their closures make through captured parameters.
+// Unexported functions are left out, as unexported structs are.
+func collectFunctions(fset *token.FileSet, node *ast.File) ([]funcFacts, []callFacts) {
+	var funcs []funcFacts
+	var calls []callFacts
+	for _, decl := range node.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Recv != nil || funcDecl.Body == nil || !funcDecl.Name.IsExported() {
+			continue
+		}
+
+		fn := funcFacts{
+			Name: funcDecl.Name.Name,
+			Line: fset.Position(funcDecl.Pos()).Line,
+			Doc:  strings.TrimSpace(funcDecl.Doc.Text()),
+		}
+		if fn.Framework = middlewareSignature(funcDecl.Type); fn.Framework != "" {
+			fn.Middleware = true
+			funcs = append(funcs, fn)
+			continue
+		}
+		if fn.Framework = handlerSignature(funcDecl.Type); fn.Framework != "" {
+			funcs = append(funcs, fn)
+			continue
+		}
+		if fn.Framework = middlewareResult(funcDecl.Type); fn.Framework != "" {
+			fn.Middleware = true
+		} else if fn.Framework = handlerResult(funcDecl.Type); fn.Framework == "" {
+			continue
+		}
+		closures := returnedClosures(funcDecl.Body)
+		if len(closures) == 0 {
+			if fn.Middleware {
+				funcs = append(funcs, fn) // Configured middleware, such as RateLimit(100)
+			}
+			continue // Returns a handler built some other way, such as a struct
+		}
+		fn.Closure = true
+
+		// Parameters the closures refer to are captured, and so are dependencies
+		params := make(map[string]ast.Expr)
+		for _, field := range funcDecl.Type.Params.List {
+			for _, name := range field.Names {
+				params[name.Name] = field.Type
+			}
+		}
+		captured := make(map[string]bool)
+		for _, closure := range closures {
+			ast.Inspect(closure.Body, func(n ast.Node) bool {
+				switch t := n.(type) {
+				case *ast.Ident:
+					if _, ok := params[t.Name]; ok {
+						captured[t.Name] = true
+					}
+				case *ast.CallExpr:
+					if sel, ok := t.Fun.(*ast.SelectorExpr); ok {
+						if ident, ok := sel.X.(*ast.Ident); ok && params[ident.Name] != nil {
+							calls = append(calls, callFacts{
+								Receiver: fn.Name,
+								Caller:   fn.Name,
+								Field:    ident.Name,
+								Method:   sel.Sel.Name,
+								Line:     fset.Position(t.Pos()).Line,
+							})
+						}
+					}
+				}
+				return true
+			})
+		}
+
+		fn.ParamNames = make(map[string]string)
+		seen := make(map[string]bool)
+		for _, field := range funcDecl.Type.Params.List {
+			types := fieldTypesOf(field.Type)
+			for _, name := range field.Names {
+				if !captured[name.Name] {
+					continue
+				}
+				if len(types) == 1 {
+					fn.ParamNames[name.Name] = types[0].Name
+				}
+				for _, ft := range types {
+					if !seen[ft.Name] {
+						seen[ft.Name] = true
+						fn.Params = append(fn.Params, ft)
+					}
+				}
+			}
+		}
+		funcs = append(funcs, fn)
+	}
+	return funcs, calls
+}
+
+// returnedClosures returns the function literals a function body returns,
+// directly or converted, as in return http.HandlerFunc(func(...) {...}).
+// Returns inside nested function literals are not the function's own.
+func returnedClosures(body *ast.BlockStmt) []*ast.FuncLit {
+	var closures []*ast.FuncLit
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			for _, result := range t.Results {
+				if call, ok := result.(*ast.CallExpr); ok && len(call.Args) == 1 {
+					result = call.Args[0]
+				}
+				if lit, ok := result.(*ast.FuncLit); ok {
+					closures = append(closures, lit)
+				}
+			}
+		}
+		return true
+	})
+	return closures
+}
+
+// functionComponent turns a function's facts into a handler or middleware
+// component, with the explanation for it.
+func functionComponent(file fileFacts, fn funcFacts, types *typeIndex) (Component, Explanation) {
+	edges, fields := extractInterfaceDependencies(fn.Params, types.interfaces)
+	var deps []string
+	for _, e := range edges {
+		deps = append(deps, e.Target)
+	}
+
+	componentType, kind := ComponentHandler, "handler"
+	if fn.Middleware {
+		componentType, kind = ComponentMiddleware, "middleware"
+	}
+	classification := Classification{Rule: kind + "-func", Dependencies: len(deps)}
+	switch {
+	case fn.Closure:
+		classification.Reason = fmt.Sprintf("constructor returning a %s %s closure, which captures %s", fn.Framework, kind, dependencies(len(deps)))
+	case fn.Middleware:
+		classification.Reason = fmt.Sprintf("function with the signature of %s middleware, or returning it", fn.Framework)
+	default:
+		classification.Reason = fmt.Sprintf("function with the signature of a %s handler", fn.Framework)
+	}
+
+	comp := Component{
+		Name:           fn.Name,
+		Type:           componentType,
+		Package:        file.Package,
+		FilePath:       file.Path,
+		Line:           fn.Line,
+		Doc:            fn.Doc,
+		Dependencies:   deps,
+		Edges:          edges,
+		Function:       true,
+		Classification: classification,
+	}
+	explanation := Explanation{
+		Name:           fn.Name,
+		Package:        file.Package,
+		FilePath:       file.Path,
+		Line:           fn.Line,
+		Component:      true,
+		Type:           componentType,
+		Summary:        fmt.Sprintf("%s, by the %s rule: %s", componentType, classification.Rule, classification.Reason),
+		Fields:         fields,
+		Checks:         []Check{{Rule: classification.Rule, Matched: true, Detail: classification.Reason}},
+		Classification: &classification,
+	}
+	return comp, explanation
+}
@@ -0,0 +1,383 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// GeneratedFile is a source file written by a code generator.
+type GeneratedFile struct {
+	Path      string `json:"path"`
+	Generator string `json:"generator"` // e.g. "protoc-gen-go", "sqlc" or "mockgen"
+}
+
+// GeneratedMode is how generated components appear in an architecture.
+type GeneratedMode string
+
+const (
+	GeneratedShow     GeneratedMode = "show"     // Like hand-written ones, marked with their generator
+	GeneratedHide     GeneratedMode = "hide"     // Left out, with the dependencies on them
+	GeneratedCollapse GeneratedMode = "collapse" // Merged into one component per generator
+)
+
+// ContractKind is the kind of interface a generated contract defines.
+type ContractKind string
+
+const (
+	ContractGRPC    ContractKind = "grpc"    // A gRPC service, from protoc-gen-go-grpc
+	ContractOpenAPI ContractKind = "openapi" // An OpenAPI server interface, from oapi-codegen
+	ContractDB      ContractKind = "db"      // Database queries, from sqlc
+)
+
+// ContractKinds lists the kinds in the order they are documented.
+var ContractKinds = []ContractKind{ContractGRPC, ContractOpenAPI, ContractDB}
+
+// Contract is an interface generated from a schema: a gRPC service, an
+// OpenAPI server or a set of database queries. Generated code is authoritative
+// for these, so the contract lists the operations the schema defines and the
+// hand-written components implementing or using them.
+type Contract struct {
+	Kind          ContractKind `json:"kind"`
+	Name          string       `json:"name"`      // The gRPC service, or the package of an OpenAPI server or queries
+	Interface     string       `json:"interface"` // e.g. "PaymentServiceServer", "ServerInterface" or "Querier"
+	Generator     string       `json:"generator"`
+	FilePath      string       `json:"filePath"`
+	Operations    []string     `json:"operations"`
+	ImplementedBy []string     `json:"implementedBy"` // Components declaring every operation, or embedding the generated Unimplemented server
+	UsedBy        []string     `json:"usedBy"`        // Components holding the interface, or a generated type implementing it
+}
+
+// generatedComment is the header Go tools recognise in generated files.
+var generatedComment = regexp.MustCompile(`^// Code generated (.*)DO NOT EDIT\.$`)
+
+// generatorOf returns the generator named in a file's "Code generated ... DO
+// NOT EDIT." header, lowercased and without its import path or version, e.g.
+// "protoc-gen-go" for "Code generated by protoc-gen-go. DO NOT EDIT.". A
+// header naming no generator gives "generated", and a hand-written file "".
+func generatorOf(node *ast.File) string {
+	for _, group := range node.Comments {
+		if group.Pos() > node.Package {
+			break
+		}
+		for _, c := range group.List {
+			m := generatedComment.FindStringSubmatch(c.Text)
+			if m == nil {
+				continue
+			}
+			_, by, ok := strings.Cut(m[1], "by ")
+			fields := strings.Fields(by)
+			if !ok || len(fields) == 0 {
+				return "generated"
+			}
+			// As in github.com/oapi-codegen/oapi-codegen/v2
+			name := trimMajorVersion(strings.TrimRight(fields[0], ".,;:"))
+			return strings.ToLower(path.Base(name))
+		}
+	}
+	return ""
+}
+
+// collectContracts finds the contracts declared in generated files, in walk
+// order, and the components implementing and using them.
+func collectContracts(files []fileFacts, components []Component, types *typeIndex) []Contract {
+	contracts := []Contract{}
+	for _, file := range files {
+		if file.Generator == "" {
+			continue
+		}
+		for _, name := range file.Interfaces {
+			methods := file.InterfaceMethods[name]
+			contract := Contract{Interface: name, Generator: file.Generator, FilePath: file.Path, ImplementedBy: []string{}, UsedBy: []string{}}
+			switch {
+			case strings.HasSuffix(name, "Server") && slices.Contains(methods, "mustEmbedUnimplemented"+name):
+				contract.Kind, contract.Name = ContractGRPC, strings.TrimSuffix(name, "Server")
+			case name == "ServerInterface" || name == "StrictServerInterface":
+				contract.Kind, contract.Name = ContractOpenAPI, file.Package
+			case name == "Querier":
+				contract.Kind, contract.Name = ContractDB, file.Package
+			default:
+				continue
+			}
+			for _, m := range methods {
+				if ast.IsExported(m) {
+					contract.Operations = append(contract.Operations, m)
+				}
+			}
+			contracts = append(contracts, contract)
+		}
+	}
+	if len(contracts) == 0 {
+		return contracts
+	}
+
+	// Types implementing a contract, generated ones such as sqlc's Queries
+	// included, by the methods they declare
+	declared := make(map[string][]string)
+	generated := make(map[string][]string) // Directory to its generated structs
+	for _, file := range files {
+		for _, m := range file.Methods {
+			key := methodKey(filepath.Dir(file.Path), m.Receiver)
+			declared[key] = append(declared[key], m.Name)
+		}
+		if file.Generator != "" {
+			for _, st := range file.Structs {
+				generated[filepath.Dir(file.Path)] = append(generated[filepath.Dir(file.Path)], st.Name)
+			}
+		}
+	}
+	implements := func(key string, c Contract) bool {
+		for _, op := range c.Operations {
+			if !slices.Contains(declared[key], op) {
+				return false
+			}
+		}
+		return len(c.Operations) > 0
+	}
+
+	for i := range contracts {
+		c := &contracts[i]
+		held := map[string]bool{c.Interface: true}
+		dir := filepath.Dir(c.FilePath)
+		for _, name := range generated[dir] {
+			if implements(methodKey(dir, name), *c) {
+				held[name] = true
+			}
+		}
+		for _, comp := range components {
+			if comp.Generated != "" || comp.Function {
+				continue
+			}
+			st, ok := types.lookupStruct(filepath.Dir(comp.FilePath), comp.Name)
+			if !ok {
+				continue
+			}
+			if implements(methodKey(filepath.Dir(comp.FilePath), comp.Name), *c) {
+				c.ImplementedBy = append(c.ImplementedBy, comp.Name)
+			}
+			for _, ft := range st.facts.FieldTypes {
+				if ft.Kind == EdgeEmbeds && ft.Name == "Unimplemented"+c.Interface && !slices.Contains(c.ImplementedBy, comp.Name) {
+					c.ImplementedBy = append(c.ImplementedBy, comp.Name)
+				}
+				if ft.Kind != EdgeEmbeds && held[ft.Name] && !slices.Contains(c.UsedBy, comp.Name) {
+					c.UsedBy = append(c.UsedBy, comp.Name)
+				}
+			}
+		}
+	}
+	return contracts
+}
+
+// WithGenerated returns the architecture with its generated components shown,
+// hidden or collapsed into one component per generator, named like
+// "generated/sqlc". A collapsed component takes the most common type of the
+// components it stands for and their dependencies on hand-written ones, and
+// dependencies on any of them become dependencies on it. Routes, endpoints,
+// diagnostics and explanations follow the components: hiding a generated
+// handler hides its routes. Contracts are kept whatever the mode.
+func (a *Architecture) WithGenerated(mode GeneratedMode) (*Architecture, error) {
+	switch mode {
+	case "", GeneratedShow:
+		return a, nil
+	case GeneratedHide, GeneratedCollapse:
+	default:
+		return nil, fmt.Errorf("unknown generated mode %q: use show, hide or collapse", mode)
+	}
+
+	// Generated component names to what replaces them, "" for nothing
+	replace := make(map[string]string)
+	groups := make(map[string]*Component)
+	var order []string
+	for _, comp := range a.Components {
+		if comp.Generated == "" {
+			continue
+		}
+		if mode == GeneratedHide {
+			replace[comp.Name] = ""
+			continue
+		}
+		name := "generated/" + comp.Generated
+		replace[comp.Name] = name
+		if groups[name] == nil {
+			groups[name] = &Component{
+				Name:      name,
+				Package:   comp.Package,
+				Module:    comp.Module,
+				FilePath:  comp.FilePath,
+				Line:      comp.Line,
+				Methods:   []Method{},
+				Generated: comp.Generated,
+				Classification: Classification{
+					Rule:   "generated",
+					Reason: fmt.Sprintf("components generated by %s, collapsed", comp.Generated),
+				},
+			}
+			order = append(order, name)
+		}
+	}
+	if len(replace) == 0 {
+		return a, nil
+	}
+
+	sub := *a
+	sub.Components = []Component{}
+	sub.Dependencies = make(map[string][]string)
+	redirect := func(comp *Component) {
+		deps := []string{}
+		var edges []Edge
+		seen := make(map[string]bool)
+		for _, e := range comp.Edges {
+			if target, ok := replace[e.Target]; ok {
+				if target == "" || target == comp.Name {
+					continue
+				}
+				e.Target = target
+			}
+			if !seen[e.Target] {
+				seen[e.Target] = true
+				deps = append(deps, e.Target)
+				edges = append(edges, e)
+			}
+		}
+		var calls []Call
+		for _, c := range comp.Calls {
+			if target, ok := replace[c.Target]; ok {
+				if target == "" || target == comp.Name {
+					continue
+				}
+				c.Target = target
+			}
+			calls = append(calls, c)
+		}
+		comp.Dependencies, comp.Edges, comp.Calls = deps, edges, calls
+	}
+
+	typeCounts := make(map[string]map[ComponentType]int)
+	members := make(map[string][]string)
+	for _, comp := range a.Components {
+		if comp.Generated == "" {
+			redirect(&comp)
+			sub.Components = append(sub.Components, comp)
+			continue
+		}
+		group := replace[comp.Name]
+		if group == "" {
+			continue
+		}
+		g := groups[group]
+		members[group] = append(members[group], comp.Name)
+		comp.Name = group // So that dependencies among its members are dropped
+		redirect(&comp)
+		for _, e := range comp.Edges {
+			if !slices.Contains(g.Dependencies, e.Target) {
+				g.Dependencies = append(g.Dependencies, e.Target)
+				g.Edges = append(g.Edges, e)
+			}
+		}
+		g.Calls = append(g.Calls, comp.Calls...)
+		if typeCounts[group] == nil {
+			typeCounts[group] = make(map[ComponentType]int)
+		}
+		typeCounts[group][comp.Type]++
+	}
+
+	for _, name := range order {
+		g := groups[name]
+		// Ties go to the type nearest the transport layer
+		for _, t := range []ComponentType{ComponentMiddleware, ComponentHandler, ComponentService, ComponentAdapter, ComponentRepository} {
+			if typeCounts[name][t] > typeCounts[name][g.Type] {
+				g.Type = t
+			}
+		}
+		g.Doc = fmt.Sprintf("Generated by %s: %s", g.Generated, strings.Join(members[name], ", "))
+		if g.Dependencies == nil {
+			g.Dependencies = []string{}
+		}
+		sub.Components = append(sub.Components, *g)
+	}
+	for _, comp := range sub.Components {
+		sub.Dependencies[comp.Name] = comp.Dependencies
+	}
+
+	// Routes served by generated handlers point at what replaced them. Hidden
+	// handlers take their routes with them, and hidden middleware leaves the
+	// pipelines it is in.
+	step := func(s RouteStep) RouteStep {
+		if target, ok := replace[s.Component]; ok {
+			s.Component = target
+		}
+		return s
+	}
+	route := func(r Route) (Route, bool) {
+		if target, ok := replace[r.Handler.Component]; ok && target == "" {
+			return r, false
+		}
+		middleware := []RouteStep{}
+		for _, m := range r.Middleware {
+			if target, ok := replace[m.Component]; !ok || target != "" {
+				middleware = append(middleware, step(m))
+			}
+		}
+		r.Middleware = middleware
+		r.Handler = step(r.Handler)
+		return r, true
+	}
+	sub.Routes = []Route{}
+	for _, r := range a.Routes {
+		if r, ok := route(r); ok {
+			sub.Routes = append(sub.Routes, r)
+		}
+	}
+	sub.API.Undocumented = []Route{}
+	for _, r := range a.API.Undocumented {
+		if r, ok := route(r); ok {
+			sub.API.Undocumented = append(sub.API.Undocumented, r)
+		}
+	}
+	sub.API.Operations = slices.Clone(a.API.Operations)
+	for i := range sub.API.Operations {
+		sub.API.Operations[i].Component = step(RouteStep{Component: sub.API.Operations[i].Component}).Component
+	}
+
+	// What the analysis found in generated files goes with the components
+	// declared there: hidden, or attributed to their group
+	generated := make(map[string]bool)
+	for _, g := range a.Generated {
+		generated[g.Path] = true
+	}
+	sub.Endpoints = []Endpoint{}
+	for _, e := range a.Endpoints {
+		if mode != GeneratedHide || !generated[e.FilePath] {
+			sub.Endpoints = append(sub.Endpoints, e)
+		}
+	}
+	sub.Diagnostics = []Diagnostic{}
+	for _, d := range a.Diagnostics {
+		if target, ok := replace[d.Component]; ok && generated[d.FilePath] {
+			if target == "" {
+				continue
+			}
+			d.Component = target
+		}
+		sub.Diagnostics = append(sub.Diagnostics, d)
+	}
+	sub.explanations = slices.Clone(a.explanations)
+	for i := range sub.explanations {
+		e := &sub.explanations[i]
+		target, ok := replace[e.Name]
+		if !ok || !e.Component || !generated[e.FilePath] {
+			continue
+		}
+		if target == "" {
+			e.Component = false
+			e.Summary += "; hidden as generated code"
+		} else {
+			e.Summary += "; collapsed into " + target
+		}
+	}
+	return &sub, nil
+}
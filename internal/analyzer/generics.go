@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// typeInstance is an instantiation of a generic type in a struct field, such
+// as Repository[User] or lru.Cache[string, Item].
+type typeInstance struct {
+	Type string   `json:"type"`           // Name of the generic type, e.g. "Repository"
+	Args []string `json:"args,omitempty"` // Named type arguments, e.g. ["User"]
+}
+
+// DisplayName returns the component name with its type parameters, e.g.
+// "Repository[T any]". Generic components appear once however many times they
+// are instantiated. Function components are shown called, e.g. "ListOrders()".
+func (c Component) DisplayName() string {
+	if c.Function {
+		return c.Name + "()"
+	}
+	if len(c.TypeParams) == 0 {
+		return c.Name
+	}
+	return c.Name + "[" + strings.Join(c.TypeParams, ", ") + "]"
+}
+
+// typeParams returns a generic type's parameters with their constraints, one
+// per name, e.g. ["K comparable", "V any"].
+func typeParams(fset *token.FileSet, list *ast.FieldList) []string {
+	if list == nil {
+		return nil
+	}
+	var params []string
+	for _, field := range list.List {
+		var constraint bytes.Buffer
+		if err := printer.Fprint(&constraint, fset, field.Type); err != nil {
+			continue
+		}
+		for _, name := range field.Names {
+			params = append(params, name.Name+" "+constraint.String())
+		}
+	}
+	return params
+}
+
+// typeParamNames returns the names declared by typeParams.
+func typeParamNames(params []string) map[string]bool {
+	names := make(map[string]bool, len(params))
+	for _, param := range params {
+		name, _, _ := strings.Cut(param, " ")
+		names[name] = true
+	}
+	return names
+}
+
+// typeInstances returns the generic types instantiated by a struct's fields.
+// Type arguments that are predeclared, such as string, or are the struct's own
+// type parameters are left out: they do not link the type to anything.
+func typeInstances(structType *ast.StructType, params map[string]bool) []typeInstance {
+	var instances []typeInstance
+	if structType.Fields == nil {
+		return instances
+	}
+	for _, field := range structType.Fields.List {
+		expr := field.Type
+		if star, ok := expr.(*ast.StarExpr); ok {
+			expr = star.X
+		}
+
+		var base ast.Expr
+		var indices []ast.Expr
+		switch t := expr.(type) {
+		case *ast.IndexExpr:
+			base, indices = t.X, []ast.Expr{t.Index}
+		case *ast.IndexListExpr:
+			base, indices = t.X, t.Indices
+		default:
+			continue
+		}
+
+		instance := typeInstance{Type: extractTypeName(base)}
+		if instance.Type == "" {
+			continue
+		}
+		for _, index := range indices {
+			arg := extractTypeName(index)
+			if arg == "" || params[arg] || isPredeclared(index) {
+				continue
+			}
+			instance.Args = append(instance.Args, arg)
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// isPredeclared reports whether expr names a predeclared type, such as string
+// or error.
+func isPredeclared(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = types.Universe.Lookup(ident.Name).(*types.TypeName)
+	return ok
+}
+
+// elementTypes collects, for every generic type, the named type arguments it
+// is instantiated with anywhere in the repository, sorted.
+func elementTypes(files []fileFacts) map[string][]string {
+	seen := make(map[string]map[string]bool)
+	for _, file := range files {
+		for _, st := range file.Structs {
+			for _, instance := range st.Instances {
+				if seen[instance.Type] == nil {
+					seen[instance.Type] = make(map[string]bool)
+				}
+				for _, arg := range instance.Args {
+					seen[instance.Type][arg] = true
+				}
+			}
+		}
+	}
+
+	elements := make(map[string][]string, len(seen))
+	for name, args := range seen {
+		for arg := range args {
+			elements[name] = append(elements[name], arg)
+		}
+		sort.Strings(elements[name])
+	}
+	return elements
+}
@@ -0,0 +1,145 @@
+package analyzer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repository describes the version control checkout a repository was analyzed from.
+type Repository struct {
+	Commit    string `json:"commit,omitempty"`    // HEAD commit SHA, empty if not a git checkout
+	RemoteURL string `json:"remoteUrl,omitempty"` // URL of the "origin" remote, if any
+	Subdir    string `json:"subdir,omitempty"`    // Slash-separated path of the analyzed directory inside the checkout
+}
+
+// detectRepository reads the git metadata of the checkout containing repoPath.
+// It reads .git directly rather than shelling out, and leaves fields empty when
+// something cannot be determined.
+func detectRepository(repoPath string) Repository {
+	var repo Repository
+
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return repo
+	}
+
+	root := absPath
+	for {
+		if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return repo // Not inside a git checkout
+		}
+		root = parent
+	}
+
+	if subdir, err := filepath.Rel(root, absPath); err == nil && subdir != "." {
+		repo.Subdir = filepath.ToSlash(subdir)
+	}
+
+	gitDir := resolveGitDir(filepath.Join(root, ".git"))
+	repo.Commit = readHead(gitDir)
+	repo.RemoteURL = readOriginURL(gitDir)
+	return repo
+}
+
+// resolveGitDir follows the "gitdir:" indirection used by worktrees and submodules.
+func resolveGitDir(dotGit string) string {
+	info, err := os.Stat(dotGit)
+	if err != nil || info.IsDir() {
+		return dotGit
+	}
+
+	content, err := os.ReadFile(dotGit)
+	if err != nil {
+		return dotGit
+	}
+	target := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(content)), "gitdir:"))
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(dotGit), target)
+	}
+	return target
+}
+
+// gitDirs returns the git directory followed by the common directory that
+// worktrees share refs and config with, if there is one.
+func gitDirs(gitDir string) []string {
+	dirs := []string{gitDir}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		dirs = append(dirs, commonDir)
+	}
+	return dirs
+}
+
+func readHead(gitDir string) string {
+	content, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(content))
+
+	ref, isRef := strings.CutPrefix(head, "ref: ")
+	if !isRef {
+		return head // Detached HEAD
+	}
+
+	for _, dir := range gitDirs(gitDir) {
+		if sha, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(sha))
+		}
+		if sha := readPackedRef(filepath.Join(dir, "packed-refs"), ref); sha != "" {
+			return sha
+		}
+	}
+	return ""
+}
+
+func readPackedRef(path, ref string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+func readOriginURL(gitDir string) string {
+	for _, dir := range gitDirs(gitDir) {
+		file, err := os.Open(filepath.Join(dir, "config"))
+		if err != nil {
+			continue
+		}
+
+		inOrigin := false
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "[") {
+				inOrigin = line == `[remote "origin"]`
+				continue
+			}
+			if key, value, ok := strings.Cut(line, "="); ok && inOrigin && strings.TrimSpace(key) == "url" {
+				file.Close()
+				return strings.TrimSpace(value)
+			}
+		}
+		file.Close()
+	}
+	return ""
+}
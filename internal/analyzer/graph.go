@@ -0,0 +1,262 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+)
+
+// StronglyConnected returns the strongly connected components of the graph
+// formed by nodes and edges, using Tarjan's algorithm. Components are returned
+// dependencies first: every component comes after the components it has edges
+// to. Edges to nodes outside nodes are ignored.
+func StronglyConnected(nodes []string, edges map[string][]string) [][]string {
+	t := &tarjan{
+		edges:   edges,
+		known:   make(map[string]bool, len(nodes)),
+		index:   make(map[string]int, len(nodes)),
+		lowlink: make(map[string]int, len(nodes)),
+		onStack: make(map[string]bool, len(nodes)),
+	}
+	for _, node := range nodes {
+		t.known[node] = true
+	}
+	for _, node := range nodes {
+		if _, visited := t.index[node]; !visited {
+			t.strongConnect(node)
+		}
+	}
+	return t.components
+}
+
+// Cycles returns the dependency cycles between components, each sorted by
+// name, in a deterministic order.
+func (a *Architecture) Cycles() [][]string {
+	names := make([]string, 0, len(a.Components))
+	edges := make(map[string][]string, len(a.Components))
+	for _, comp := range a.Components {
+		names = append(names, comp.Name)
+		edges[comp.Name] = comp.Dependencies
+	}
+	sort.Strings(names)
+
+	var cycles [][]string
+	for _, component := range StronglyConnected(names, edges) {
+		if len(component) < 2 {
+			continue
+		}
+		sort.Strings(component)
+		cycles = append(cycles, component)
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+type tarjan struct {
+	edges      map[string][]string
+	known      map[string]bool
+	index      map[string]int
+	lowlink    map[string]int
+	onStack    map[string]bool
+	stack      []string
+	next       int
+	components [][]string
+}
+
+func (t *tarjan) strongConnect(node string) {
+	t.index[node] = t.next
+	t.lowlink[node] = t.next
+	t.next++
+	t.stack = append(t.stack, node)
+	t.onStack[node] = true
+
+	for _, target := range t.edges[node] {
+		if !t.known[target] {
+			continue
+		}
+		if _, visited := t.index[target]; !visited {
+			t.strongConnect(target)
+			t.lowlink[node] = min(t.lowlink[node], t.lowlink[target])
+		} else if t.onStack[target] {
+			t.lowlink[node] = min(t.lowlink[node], t.index[target])
+		}
+	}
+
+	if t.lowlink[node] != t.index[node] {
+		return
+	}
+
+	var component []string
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[top] = false
+		component = append(component, top)
+		if top == node {
+			break
+		}
+	}
+	t.components = append(t.components, component)
+}
+
+// Component returns the component with the given name, or nil.
+func (a *Architecture) Component(name string) *Component {
+	for i := range a.Components {
+		if a.Components[i].Name == name {
+			return &a.Components[i]
+		}
+	}
+	return nil
+}
+
+// Dependents returns the names of the components that depend directly on
+// the named component, in the order they were found.
+func (a *Architecture) Dependents(name string) []string {
+	dependents := []string{}
+	for _, comp := range a.Components {
+		for _, dep := range comp.Dependencies {
+			if dep == name {
+				dependents = append(dependents, comp.Name)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// ComponentDetail is a component together with the components that depend on it.
+type ComponentDetail struct {
+	Component
+	DependedBy []string `json:"dependedBy"`
+}
+
+// Detail returns the named component with its dependents, or nil if there is
+// no such component.
+func (a *Architecture) Detail(name string) *ComponentDetail {
+	comp := a.Component(name)
+	if comp == nil {
+		return nil
+	}
+	return &ComponentDetail{Component: *comp, DependedBy: a.Dependents(name)}
+}
+
+// Neighbor is a component reached from another, with the number of
+// dependency edges between them.
+type Neighbor struct {
+	Name     string `json:"name"`
+	Distance int    `json:"distance"`
+}
+
+// Reachable returns the components reachable from the named component by
+// following dependencies, or dependents when reverse is set, up to depth
+// edges away. A depth of zero or less means no limit. Neighbors are ordered
+// by distance, then name.
+func (a *Architecture) Reachable(name string, depth int, reverse bool) []Neighbor {
+	edges := make(map[string][]string, len(a.Components))
+	for _, comp := range a.Components {
+		if reverse {
+			for _, dep := range comp.Dependencies {
+				edges[dep] = append(edges[dep], comp.Name)
+			}
+		} else {
+			edges[comp.Name] = comp.Dependencies
+		}
+	}
+
+	neighbors := []Neighbor{}
+	seen := map[string]bool{name: true}
+	frontier := []string{name}
+	for distance := 1; len(frontier) > 0 && (depth <= 0 || distance <= depth); distance++ {
+		var next []string
+		for _, node := range frontier {
+			for _, target := range edges[node] {
+				if seen[target] {
+					continue
+				}
+				seen[target] = true
+				next = append(next, target)
+			}
+		}
+		sort.Strings(next)
+		for _, node := range next {
+			neighbors = append(neighbors, Neighbor{Name: node, Distance: distance})
+		}
+		frontier = next
+	}
+	return neighbors
+}
+
+// Paths returns the dependency paths from one component to another, each
+// starting with from and ending with to, shortest first and then in name
+// order. Paths never visit a component twice. The search stops after limit
+// paths, which are then the limit shortest ones; zero or less means no limit.
+//
+// Paths are found by iterative deepening, one length at a time, through only
+// the components from which to can be reached. The number of paths can still
+// grow exponentially with the size of the graph, so the search gives up with
+// the context's error once it is cancelled.
+func (a *Architecture) Paths(ctx context.Context, from, to string, limit int) ([][]string, error) {
+	edges := make(map[string][]string, len(a.Components))
+	for _, comp := range a.Components {
+		deps := append([]string(nil), comp.Dependencies...)
+		sort.Strings(deps)
+		edges[comp.Name] = deps
+	}
+
+	// The fewest edges from each component to to; components missing from it
+	// cannot lead there
+	toTarget := map[string]int{to: 0}
+	for _, n := range a.Reachable(to, 0, true) {
+		toTarget[n.Name] = n.Distance
+	}
+
+	paths := [][]string{}
+	onPath := map[string]bool{}
+	path := []string{from}
+	onPath[from] = true
+	// walk extends the path by exactly remaining edges, ending at to
+	var walk func(node string, remaining int) error
+	walk = func(node string, remaining int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, target := range edges[node] {
+			if limit > 0 && len(paths) >= limit {
+				return nil
+			}
+			if target == to {
+				// A path ends the first time it reaches to, which may be
+				// its start when from and to are the same and the paths
+				// are cycles.
+				if remaining == 1 {
+					paths = append(paths, append(append([]string(nil), path...), to))
+				}
+				continue
+			}
+			dist, ok := toTarget[target]
+			if !ok || onPath[target] || dist > remaining-1 {
+				continue
+			}
+			path = append(path, target)
+			onPath[target] = true
+			err := walk(target, remaining-1)
+			path = path[:len(path)-1]
+			onPath[target] = false
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, ok := toTarget[from]; !ok {
+		return paths, nil
+	}
+	for length := 1; length <= len(a.Components); length++ {
+		if limit > 0 && len(paths) >= limit {
+			break
+		}
+		if err := walk(from, length); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
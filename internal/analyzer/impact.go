@@ -0,0 +1,267 @@
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// How a changed component was matched to the change.
+const (
+	MatchComponent = "component" // Named explicitly
+	MatchFile      = "file"      // The file declares the component
+	MatchMethod    = "method"    // The file declares one of the component's methods
+	MatchPackage   = "package"   // The file declares no component, but shares its package
+)
+
+// Impact is the blast radius of a change: the components changed directly and
+// everything that depends on them, up to the handlers serving requests.
+type Impact struct {
+	Changed     []ChangedComponent  `json:"changed"`
+	Unmatched   []string            `json:"unmatched"`   // Changed files that map to no component
+	Affected    []AffectedComponent `json:"affected"`    // Changed and dependent components, nearest first
+	EntryPoints []AffectedComponent `json:"entryPoints"` // Affected handlers, nearest first
+	Packages    []AffectedPackage   `json:"packages"`    // Packages of the affected components, nearest first
+}
+
+// ChangedComponent is a component touched directly by a change.
+type ChangedComponent struct {
+	Name  string        `json:"name"`
+	Type  ComponentType `json:"type"`
+	Match string        `json:"match"`
+	Files []string      `json:"files,omitempty"` // Changed files that matched it
+}
+
+// AffectedComponent is a component that is changed or depends on a changed
+// component, directly or transitively.
+type AffectedComponent struct {
+	Name     string        `json:"name"`
+	Type     ComponentType `json:"type"`
+	Package  string        `json:"package"`
+	FilePath string        `json:"filePath"`
+	Distance int           `json:"distance"`          // Dependency edges to the nearest changed component
+	Path     []string      `json:"path"`              // From this component down to the changed one
+	Methods  []string      `json:"methods,omitempty"` // Exported methods of affected handlers, the endpoints they serve
+}
+
+// AffectedPackage is a package, by directory, containing affected components.
+type AffectedPackage struct {
+	Path       string   `json:"path"`
+	Distance   int      `json:"distance"` // Distance of its nearest affected component
+	Components []string `json:"components"`
+}
+
+// Impact computes the impact of changing the given files, relative to the
+// repository root, and the named components.
+func (a *Architecture) Impact(files, components []string) (*Impact, error) {
+	impact := &Impact{
+		Changed:     []ChangedComponent{},
+		Unmatched:   []string{},
+		Affected:    []AffectedComponent{},
+		EntryPoints: []AffectedComponent{},
+		Packages:    []AffectedPackage{},
+	}
+
+	changed := make(map[string]*ChangedComponent)
+	var order []string
+	mark := func(name, match, file string) {
+		c, ok := changed[name]
+		if !ok {
+			c = &ChangedComponent{Name: name, Type: a.Component(name).Type, Match: match}
+			changed[name] = c
+			order = append(order, name)
+		}
+		if file != "" {
+			c.Files = append(c.Files, file)
+		}
+	}
+
+	for _, name := range components {
+		if a.Component(name) == nil {
+			return nil, fmt.Errorf("component %q not found", name)
+		}
+		mark(name, MatchComponent, "")
+	}
+
+	for _, file := range files {
+		file = filepath.ToSlash(filepath.Clean(file))
+		if !strings.HasSuffix(file, ".go") {
+			impact.Unmatched = append(impact.Unmatched, file)
+			continue
+		}
+
+		matched := false
+		for _, comp := range a.Components {
+			if filepath.ToSlash(comp.FilePath) == file {
+				mark(comp.Name, MatchFile, file)
+				matched = true
+				continue
+			}
+			for _, method := range comp.Methods {
+				if filepath.ToSlash(method.FilePath) == file {
+					mark(comp.Name, MatchMethod, file)
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			continue
+		}
+
+		// Files declaring only interfaces, helpers or types the analysis skips
+		// can still affect anything in the same package.
+		dir := filepath.ToSlash(filepath.Dir(file))
+		for _, comp := range a.Components {
+			if filepath.ToSlash(filepath.Dir(comp.FilePath)) == dir {
+				mark(comp.Name, MatchPackage, file)
+				matched = true
+			}
+		}
+		if !matched {
+			impact.Unmatched = append(impact.Unmatched, file)
+		}
+	}
+
+	for _, name := range order {
+		impact.Changed = append(impact.Changed, *changed[name])
+	}
+
+	// Breadth-first search against the dependency edges, from every changed
+	// component at once, recording the component each one was reached through.
+	dependents := make(map[string][]string)
+	for _, comp := range a.Components {
+		for _, dep := range comp.Dependencies {
+			dependents[dep] = append(dependents[dep], comp.Name)
+		}
+	}
+
+	distance := make(map[string]int)
+	via := make(map[string]string)
+	frontier := append([]string(nil), order...)
+	sort.Strings(frontier)
+	for _, name := range frontier {
+		distance[name] = 0
+	}
+	for d := 1; len(frontier) > 0; d++ {
+		var next []string
+		for _, node := range frontier {
+			for _, dependent := range dependents[node] {
+				if _, seen := distance[dependent]; seen {
+					continue
+				}
+				distance[dependent] = d
+				via[dependent] = node
+				next = append(next, dependent)
+			}
+		}
+		sort.Strings(next)
+		frontier = next
+	}
+
+	for name, d := range distance {
+		comp := a.Component(name)
+		path := []string{name}
+		for node := name; via[node] != ""; node = via[node] {
+			path = append(path, via[node])
+		}
+
+		affected := AffectedComponent{
+			Name:     name,
+			Type:     comp.Type,
+			Package:  comp.Package,
+			FilePath: comp.FilePath,
+			Distance: d,
+			Path:     path,
+		}
+		if comp.Type == ComponentHandler {
+			for _, method := range comp.Methods {
+				affected.Methods = append(affected.Methods, method.Name)
+			}
+			impact.EntryPoints = append(impact.EntryPoints, affected)
+		}
+		impact.Affected = append(impact.Affected, affected)
+	}
+	sortAffected(impact.Affected)
+	sortAffected(impact.EntryPoints)
+
+	packages := make(map[string]*AffectedPackage)
+	for _, affected := range impact.Affected {
+		dir := filepath.ToSlash(filepath.Dir(affected.FilePath))
+		pkg, ok := packages[dir]
+		if !ok {
+			// Affected is sorted, so the first component seen is the nearest
+			pkg = &AffectedPackage{Path: dir, Distance: affected.Distance}
+			packages[dir] = pkg
+		}
+		pkg.Components = append(pkg.Components, affected.Name)
+	}
+	for _, pkg := range packages {
+		impact.Packages = append(impact.Packages, *pkg)
+	}
+	sort.Slice(impact.Packages, func(i, j int) bool {
+		if impact.Packages[i].Distance != impact.Packages[j].Distance {
+			return impact.Packages[i].Distance < impact.Packages[j].Distance
+		}
+		return impact.Packages[i].Path < impact.Packages[j].Path
+	})
+
+	return impact, nil
+}
+
+func sortAffected(components []AffectedComponent) {
+	sort.Slice(components, func(i, j int) bool {
+		if components[i].Distance != components[j].Distance {
+			return components[i].Distance < components[j].Distance
+		}
+		return components[i].Name < components[j].Name
+	})
+}
+
+// DiffFiles returns the files changed in a unified diff, such as the output of
+// git diff, in the order they appear. Deleted files are included under their
+// old name.
+func DiffFiles(diff string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		path = strings.TrimSpace(path)
+		if i := strings.IndexByte(path, '\t'); i >= 0 {
+			path = path[:i] // Timestamps after the name in non-git diffs
+		}
+		if path == "" || path == "/dev/null" || seen[path] {
+			return
+		}
+		seen[path] = true
+		files = append(files, path)
+	}
+
+	var oldPath string
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			oldPath = trimDiffPrefix(strings.TrimPrefix(line, "--- "), "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath := trimDiffPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			if newPath == "/dev/null" {
+				add(oldPath)
+			} else {
+				add(newPath)
+			}
+		case strings.HasPrefix(line, "rename from "):
+			add(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			add(strings.TrimPrefix(line, "rename to "))
+		}
+	}
+	return files
+}
+
+func trimDiffPrefix(path, prefix string) string {
+	path = strings.TrimRight(path, "\r")
+	if path == "/dev/null" {
+		return path
+	}
+	return strings.TrimPrefix(path, prefix)
+}
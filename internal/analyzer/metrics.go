@@ -0,0 +1,79 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// Metrics summarises the size and coupling of an analyzed architecture.
+type Metrics struct {
+	TotalComponents   int                   `json:"totalComponents"`
+	TotalDependencies int                   `json:"totalDependencies"`
+	ComponentsByType  map[ComponentType]int `json:"componentsByType"`
+	PackageCount      int                   `json:"packageCount"`
+	AvgDependencies   float64               `json:"avgDependencies"`
+	Cycles            [][]string            `json:"cycles"`
+	Components        []ComponentMetrics    `json:"components"` // Most coupled first
+}
+
+// ComponentMetrics holds the coupling of a single component.
+type ComponentMetrics struct {
+	Name        string        `json:"name"`
+	Type        ComponentType `json:"type"`
+	Package     string        `json:"package"`
+	FanIn       int           `json:"fanIn"`       // Components that depend on this one
+	FanOut      int           `json:"fanOut"`      // Components this one depends on
+	Instability float64       `json:"instability"` // FanOut / (FanIn + FanOut), 0 is maximally stable
+}
+
+// ComputeMetrics calculates coupling metrics for an architecture.
+func ComputeMetrics(arch *Architecture) Metrics {
+	metrics := Metrics{
+		TotalComponents:  len(arch.Components),
+		ComponentsByType: make(map[ComponentType]int),
+		Cycles:           arch.Cycles(),
+		Components:       make([]ComponentMetrics, 0, len(arch.Components)),
+	}
+	if metrics.Cycles == nil {
+		metrics.Cycles = [][]string{}
+	}
+
+	fanIn := make(map[string]int)
+	packages := make(map[string]bool)
+	for _, comp := range arch.Components {
+		metrics.ComponentsByType[comp.Type]++
+		metrics.TotalDependencies += len(comp.Dependencies)
+		packages[filepath.Dir(comp.FilePath)] = true
+		for _, dep := range comp.Dependencies {
+			fanIn[dep]++
+		}
+	}
+	metrics.PackageCount = len(packages)
+	if len(arch.Components) > 0 {
+		metrics.AvgDependencies = float64(metrics.TotalDependencies) / float64(len(arch.Components))
+	}
+
+	for _, comp := range arch.Components {
+		cm := ComponentMetrics{
+			Name:    comp.Name,
+			Type:    comp.Type,
+			Package: comp.Package,
+			FanIn:   fanIn[comp.Name],
+			FanOut:  len(comp.Dependencies),
+		}
+		if total := cm.FanIn + cm.FanOut; total > 0 {
+			cm.Instability = float64(cm.FanOut) / float64(total)
+		}
+		metrics.Components = append(metrics.Components, cm)
+	}
+
+	sort.SliceStable(metrics.Components, func(i, j int) bool {
+		a, b := metrics.Components[i], metrics.Components[j]
+		if a.FanIn+a.FanOut != b.FanIn+b.FanOut {
+			return a.FanIn+a.FanOut > b.FanIn+b.FanOut
+		}
+		return a.Name < b.Name
+	})
+
+	return metrics
+}
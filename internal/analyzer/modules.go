@@ -0,0 +1,315 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Module is a Go module in the analyzed tree.
+type Module struct {
+	Path      string `json:"path"`                // Module path from go.mod, e.g. "github.com/acme/orders"
+	Dir       string `json:"dir"`                 // Slash-separated directory relative to the analyzed tree, "." for its root
+	GoVersion string `json:"goVersion,omitempty"` // The go directive, e.g. "1.24"
+}
+
+// detectModules finds the modules in the tree at repoPath. A go.work at the
+// root decides them, through its use directives; otherwise every go.mod in the
+// tree is one. When the tree is a directory inside a module, that module is
+// reported with its root at ".", and its path extended to the directory. The
+// second result reports whether a go.work was used.
+func detectModules(repoPath string) ([]Module, bool) {
+	var modules []Module
+	workspace := false
+	if dirs, err := readWorkspace(filepath.Join(repoPath, "go.work")); err == nil {
+		workspace = true
+		for _, dir := range dirs {
+			if mod, err := readModule(filepath.Join(repoPath, filepath.FromSlash(dir), "go.mod")); err == nil {
+				mod.Dir = path.Clean(dir)
+				modules = append(modules, mod)
+			}
+		}
+	} else {
+		_ = filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if p != repoPath && (skipDirReason(d.Name()) != "" || d.Name() == "testdata") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Name() != "go.mod" {
+				return nil
+			}
+			if mod, err := readModule(p); err == nil {
+				rel, _ := filepath.Rel(repoPath, filepath.Dir(p))
+				mod.Dir = filepath.ToSlash(rel)
+				modules = append(modules, mod)
+			}
+			return nil
+		})
+	}
+
+	if !workspace && !hasRoot(modules) {
+		if mod, ok := enclosingModule(repoPath); ok {
+			modules = append(modules, mod)
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Dir < modules[j].Dir })
+	return modules, workspace
+}
+
+func hasRoot(modules []Module) bool {
+	for _, m := range modules {
+		if m.Dir == "." {
+			return true
+		}
+	}
+	return false
+}
+
+// enclosingModule returns the module containing repoPath from a go.mod in a
+// parent directory, as if rooted at repoPath.
+func enclosingModule(repoPath string) (Module, bool) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return Module{}, false
+	}
+	for dir := filepath.Dir(absPath); ; dir = filepath.Dir(dir) {
+		if mod, err := readModule(filepath.Join(dir, "go.mod")); err == nil {
+			rel, _ := filepath.Rel(dir, absPath)
+			mod.Path = path.Join(mod.Path, filepath.ToSlash(rel))
+			mod.Dir = "."
+			return mod, true
+		}
+		if filepath.Dir(dir) == dir {
+			return Module{}, false
+		}
+	}
+}
+
+// readModule reads the module path and go version from a go.mod file.
+func readModule(goMod string) (Module, error) {
+	var mod Module
+	err := readDirectives(goMod, func(verb string, args []string) {
+		switch {
+		case verb == "module" && len(args) > 0:
+			mod.Path = args[0]
+		case verb == "go" && len(args) > 0:
+			mod.GoVersion = args[0]
+		}
+	})
+	if err == nil && mod.Path == "" {
+		err = fmt.Errorf("%s: no module directive", goMod)
+	}
+	return mod, err
+}
+
+// readWorkspace returns the module directories a go.work file uses.
+func readWorkspace(goWork string) ([]string, error) {
+	var dirs []string
+	err := readDirectives(goWork, func(verb string, args []string) {
+		if verb == "use" && len(args) > 0 {
+			dirs = append(dirs, filepath.ToSlash(args[0]))
+		}
+	})
+	return dirs, err
+}
+
+// readDirectives calls fn with each directive in a go.mod or go.work file,
+// expanding blocks such as use ( ./a ./b ) into one call per line. Quoted
+// arguments are unquoted and comments dropped; that is all the syntax module
+// and use directives need.
+func readDirectives(name string, fn func(verb string, args []string)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	block := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if unquoted, err := strconv.Unquote(field); err == nil {
+				fields[i] = unquoted
+			}
+		}
+		switch {
+		case len(fields) == 0:
+		case block != "":
+			if fields[0] == ")" {
+				block = ""
+			} else {
+				fn(block, fields)
+			}
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		default:
+			fn(fields[0], fields[1:])
+		}
+	}
+	return scanner.Err()
+}
+
+// moduleOf returns the module a slash-separated directory belongs to, the one
+// with the longest matching root, and whether there is one.
+func moduleOf(modules []Module, dir string) (Module, bool) {
+	var best Module
+	found := false
+	for _, m := range modules {
+		if m.Dir == "." || dir == m.Dir || strings.HasPrefix(dir, m.Dir+"/") {
+			if !found || len(m.Dir) > len(best.Dir) || best.Dir == "." {
+				best, found = m, true
+			}
+		}
+	}
+	return best, found
+}
+
+// trimMajorVersion drops a major version suffix from an import path, as in
+// github.com/acme/payments/v2, so that its last element names the module.
+func trimMajorVersion(p string) string {
+	if base := path.Base(p); len(base) > 1 && base[0] == 'v' && strings.Trim(base[1:], "0123456789") == "" {
+		return path.Dir(p)
+	}
+	return p
+}
+
+// importPath returns the import path of a package directory in a module.
+func importPath(mod Module, dir string) string {
+	if mod.Dir == "." {
+		return path.Join(mod.Path, dir)
+	}
+	return path.Join(mod.Path, strings.TrimPrefix(dir, mod.Dir))
+}
+
+// ForModule returns the part of the architecture inside one module, named by
+// its module path or its directory. Components are taken by the file they are
+// declared in, so a workspace can have a Service in every module. Dependencies
+// on components in other modules are left out, as they would be analysing the
+// module on its own.
+func (a *Architecture) ForModule(name string) (*Architecture, error) {
+	var mod Module
+	found := false
+	for _, m := range a.Modules {
+		if m.Path == name || m.Dir == strings.Trim(filepath.ToSlash(name), "/") {
+			mod, found = m, true
+			break
+		}
+	}
+	if !found {
+		var names []string
+		for _, m := range a.Modules {
+			names = append(names, m.Path)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown module %q: no go.mod found", name)
+		}
+		return nil, fmt.Errorf("unknown module %q: use one of %s", name, strings.Join(names, ", "))
+	}
+
+	in := func(filePath string) bool {
+		m, ok := moduleOf(a.Modules, filepath.ToSlash(filepath.Dir(filePath)))
+		return ok && m.Dir == mod.Dir
+	}
+	sub := &Architecture{
+		Components:   []Component{},
+		Dependencies: make(map[string][]string),
+		Repository:   a.Repository,
+		Diagnostics:  []Diagnostic{},
+		Routes:       []Route{},
+		Modules:      []Module{mod},
+		Workspace:    a.Workspace,
+		Build:        a.Build,
+		Endpoints:    []Endpoint{},
+		Generated:    []GeneratedFile{},
+		Contracts:    []Contract{},
+		API:          APISurface{Specs: []APISpec{}, Operations: []APIOperation{}, Unimplemented: []APIOperation{}, Undocumented: []Route{}},
+	}
+	for _, comp := range a.Components {
+		if !in(comp.FilePath) {
+			continue
+		}
+		deps := []string{}
+		var edges []Edge
+		kept := make(map[string]bool)
+		for _, e := range comp.Edges {
+			if !e.CrossModule {
+				kept[e.Target] = true
+				deps = append(deps, e.Target)
+				edges = append(edges, e)
+			}
+		}
+		var calls []Call
+		for _, c := range comp.Calls {
+			if kept[c.Target] {
+				calls = append(calls, c)
+			}
+		}
+		comp.Dependencies, comp.Edges, comp.Calls = deps, edges, calls
+		sub.Components = append(sub.Components, comp)
+		sub.Dependencies[comp.Name] = deps
+	}
+	for _, d := range a.Diagnostics {
+		if in(d.FilePath) {
+			sub.Diagnostics = append(sub.Diagnostics, d)
+		}
+	}
+	for _, r := range a.Routes {
+		if in(r.FilePath) {
+			sub.Routes = append(sub.Routes, r)
+		}
+	}
+	for _, e := range a.Endpoints {
+		if in(e.FilePath) {
+			sub.Endpoints = append(sub.Endpoints, e)
+		}
+	}
+	for _, g := range a.Generated {
+		if in(g.Path) {
+			sub.Generated = append(sub.Generated, g)
+		}
+	}
+	for _, c := range a.Contracts {
+		if in(c.FilePath) {
+			sub.Contracts = append(sub.Contracts, c)
+		}
+	}
+	for _, s := range a.API.Specs {
+		if in(s.Path) {
+			sub.API.Specs = append(sub.API.Specs, s)
+		}
+	}
+	for _, op := range a.API.Operations {
+		if in(op.SpecPath) {
+			sub.API.Operations = append(sub.API.Operations, op)
+		}
+	}
+	for _, op := range a.API.Unimplemented {
+		if in(op.SpecPath) {
+			sub.API.Unimplemented = append(sub.API.Unimplemented, op)
+		}
+	}
+	for _, r := range a.API.Undocumented {
+		if in(r.FilePath) {
+			sub.API.Undocumented = append(sub.API.Undocumented, r)
+		}
+	}
+	for _, e := range a.explanations {
+		if in(e.FilePath) {
+			sub.explanations = append(sub.explanations, e)
+		}
+	}
+	return sub, nil
+}
@@ -0,0 +1,271 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APISpec is an OpenAPI 3 document found in the repository.
+type APISpec struct {
+	Path     string `json:"path"` // Relative to the repository
+	Title    string `json:"title"`
+	Version  string `json:"version"`            // Of the API, from info.version
+	BasePath string `json:"basePath,omitempty"` // Path of the first server URL, e.g. "/v1"
+}
+
+// APIOperation is an operation of an OpenAPI spec and what implements it.
+type APIOperation struct {
+	Method      string `json:"method"` // e.g. "GET"
+	Path        string `json:"path"`   // As the spec gives it, e.g. "/orders/{id}"
+	OperationID string `json:"operationId,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	SpecPath    string `json:"specPath"`
+	Line        int    `json:"line,omitempty"` // Of the operation in the spec
+
+	Implemented bool   `json:"implemented"`
+	Route       string `json:"route,omitempty"`     // The route serving it, e.g. "GET /orders/{orderID}"
+	Handler     string `json:"handler,omitempty"`   // e.g. "OrdersHandler.Get"
+	Component   string `json:"component,omitempty"` // The component implementing it
+}
+
+// APISurface is the HTTP API of a service as its OpenAPI specs document it,
+// correlated with the routes and handlers serving it.
+type APISurface struct {
+	Specs         []APISpec      `json:"specs"`
+	Operations    []APIOperation `json:"operations"`    // Of every spec, in spec order
+	Unimplemented []APIOperation `json:"unimplemented"` // Operations no route or handler serves
+	Undocumented  []Route        `json:"undocumented"`  // Routes no operation describes, when there are specs
+}
+
+// httpMethods are the operation keys of an OpenAPI path item, in the order
+// operations are listed.
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maxSpecSize bounds the files read as possible specs.
+const maxSpecSize = 10 << 20
+
+// isSpecCandidate reports whether a file may be an OpenAPI document: a YAML or
+// JSON file outside testdata directories, whose fixtures are not the
+// service's API.
+func isSpecCandidate(relPath string) bool {
+	if slices.Contains(strings.Split(filepath.ToSlash(filepath.Dir(relPath)), "/"), "testdata") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(relPath)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// specFacts is what an OpenAPI document declares. Like fileFacts, it depends
+// only on the document's content, so it is cached by content hash.
+type specFacts struct {
+	Spec       APISpec        `json:"spec"`
+	Operations []APIOperation `json:"operations"` // In document order
+}
+
+// specHeader matches a document that declares itself a spec, to tell specs
+// that do not decode from other broken YAML and JSON files.
+var specHeader = regexp.MustCompile(`(?m)^[\s{]*["']?(openapi|swagger)["']?\s*:`)
+
+// parseSpec decodes an OpenAPI 3 document, in YAML or JSON. It returns nil for
+// files whose top-level openapi key is missing or not a 3.x version, Swagger 2
+// documents included, and an error for specs that do not decode.
+func parseSpec(relPath string, src []byte) (*specFacts, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		if specHeader.Match(src) {
+			return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+		}
+		return nil, nil
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if !strings.HasPrefix(yamlScalar(yamlValue(root, "openapi")), "3") {
+		return nil, nil
+	}
+
+	facts := &specFacts{Spec: APISpec{Path: relPath}, Operations: []APIOperation{}}
+	info := yamlValue(root, "info")
+	facts.Spec.Title = yamlScalar(yamlValue(info, "title"))
+	facts.Spec.Version = yamlScalar(yamlValue(info, "version"))
+	if servers := yamlValue(root, "servers"); servers != nil && servers.Kind == yaml.SequenceNode && len(servers.Content) > 0 {
+		facts.Spec.BasePath = basePath(yamlScalar(yamlValue(servers.Content[0], "url")))
+	}
+
+	paths := yamlValue(root, "paths")
+	if paths == nil || paths.Kind != yaml.MappingNode {
+		return facts, nil
+	}
+	for i := 0; i+1 < len(paths.Content); i += 2 {
+		path, item := paths.Content[i].Value, yamlResolve(paths.Content[i+1])
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(item.Content); j += 2 {
+			key := item.Content[j]
+			if !slices.Contains(httpMethods, key.Value) {
+				continue
+			}
+			op := item.Content[j+1]
+			facts.Operations = append(facts.Operations, APIOperation{
+				Method:      strings.ToUpper(key.Value),
+				Path:        path,
+				OperationID: yamlScalar(yamlValue(op, "operationId")),
+				Summary:     yamlScalar(yamlValue(op, "summary")),
+				SpecPath:    relPath,
+				Line:        key.Line,
+			})
+		}
+	}
+	return facts, nil
+}
+
+// yamlValue returns the value of a key of a mapping node, or nil if the node
+// is not a mapping or has no such key.
+func yamlValue(node *yaml.Node, key string) *yaml.Node {
+	node = yamlResolve(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return yamlResolve(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// yamlScalar returns the value of a scalar node, or "" for any other node.
+func yamlScalar(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// yamlResolve follows an alias to the node it refers to.
+func yamlResolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// basePath returns the path of a server URL, without a trailing slash.
+func basePath(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// correlateAPI matches spec operations to the routes serving them, by method
+// and path with any parameter matching any other, under the spec's base path
+// or not. An operation without a route is still implemented if its
+// operationId names a method of a component implementing an oapi-codegen
+// server interface, as RegisterHandlers routes to it.
+func correlateAPI(specs []APISpec, operations []APIOperation, routes []Route, contracts []Contract) APISurface {
+	api := APISurface{Specs: specs, Operations: operations, Unimplemented: []APIOperation{}, Undocumented: []Route{}}
+	if len(specs) == 0 {
+		return api
+	}
+	bases := make(map[string]string)
+	for _, spec := range specs {
+		bases[spec.Path] = spec.BasePath
+	}
+
+	documented := make([]bool, len(routes))
+	for i := range api.Operations {
+		op := &api.Operations[i]
+		match := -1
+		for j, r := range routes {
+			if r.Method != op.Method && r.Method != "ANY" {
+				continue
+			}
+			if !samePath(r.Path, op.Path) && !samePath(r.Path, bases[op.SpecPath]+op.Path) {
+				continue
+			}
+			documented[j] = true
+			if match < 0 || (routes[match].Method == "ANY" && r.Method == op.Method) {
+				match = j
+			}
+		}
+
+		if match >= 0 {
+			r := routes[match]
+			op.Implemented = true
+			op.Route = r.Method + " " + r.Path
+			op.Handler, op.Component = r.Handler.Name, r.Handler.Component
+			continue
+		}
+		if op.OperationID != "" {
+			method := exportedName(op.OperationID)
+			for _, c := range contracts {
+				if c.Kind == ContractOpenAPI && slices.Contains(c.Operations, method) && len(c.ImplementedBy) > 0 {
+					op.Implemented = true
+					op.Component = c.ImplementedBy[0]
+					op.Handler = op.Component + "." + method
+					break
+				}
+			}
+		}
+		if !op.Implemented {
+			api.Unimplemented = append(api.Unimplemented, *op)
+		}
+	}
+
+	for j, r := range routes {
+		if !documented[j] {
+			api.Undocumented = append(api.Undocumented, r)
+		}
+	}
+	return api
+}
+
+// samePath reports whether two paths are the same route, taking parameters
+// such as {id}, {orderID} and :id as equal.
+func samePath(a, b string) bool {
+	as := strings.Split(strings.Trim(a, "/"), "/")
+	bs := strings.Split(strings.Trim(b, "/"), "/")
+	if len(as) != len(bs) {
+		return false
+	}
+	param := func(s string) bool { return strings.HasPrefix(s, "{") || strings.HasPrefix(s, ":") || s == "*" }
+	for i := range as {
+		if as[i] != bs[i] && !(param(as[i]) && param(bs[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// exportedName turns an operationId into the Go method name oapi-codegen
+// gives it, e.g. "list-orders" and "listOrders" into "ListOrders".
+func exportedName(operationID string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range operationID {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
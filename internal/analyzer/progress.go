@@ -0,0 +1,36 @@
+package analyzer
+
+import "context"
+
+// Analysis phases reported through WithProgress.
+const (
+	PhaseScan  = "scanning" // Walking the repository for source files
+	PhaseParse = "parsing"  // Parsing files, or reusing cached facts
+	PhaseBuild = "building" // Resolving components and dependencies
+)
+
+// Progress describes how far an analysis has come.
+type Progress struct {
+	Phase   string
+	Current int // Files done so far in the parsing phase
+	Total   int // Files to parse, or zero when not yet known
+}
+
+// ProgressFunc receives progress updates. It is called from the goroutine
+// running the analysis and should return quickly.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes Analyze report its progress to fn.
+// Parsing progress is reported in roughly one percent steps.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress sends an update to the ProgressFunc of ctx, if any.
+func reportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(p)
+	}
+}
@@ -0,0 +1,568 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Route is an HTTP route, with the middleware its requests pass through on
+// the way to the handler.
+type Route struct {
+	Method     string      `json:"method"` // e.g. "GET", or "ANY" when the route matches every method
+	Path       string      `json:"path"`   // Including the prefixes of enclosing groups
+	Middleware []RouteStep `json:"middleware"`
+	Handler    RouteStep   `json:"handler"`
+	FilePath   string      `json:"filePath"`
+	Line       int         `json:"line"`
+}
+
+// RouteStep is a middleware or handler in a route's pipeline.
+type RouteStep struct {
+	Name      string `json:"name"`                // As registered, e.g. "Auth" or "OrdersHandler.List"
+	Component string `json:"component,omitempty"` // The component it belongs to, if known
+}
+
+// Pipeline describes the route as the steps a request takes, e.g.
+// "GET /orders → Auth → RateLimit → OrdersHandler.List".
+func (r Route) Pipeline() string {
+	steps := []string{r.Method + " " + r.Path}
+	for _, m := range r.Middleware {
+		steps = append(steps, m.Name)
+	}
+	steps = append(steps, r.Handler.Name)
+	return strings.Join(steps, " → ")
+}
+
+// routeFacts is a route registered in a file. Its handler argument is kept
+// unwrapped, since which of the wrapping calls are middleware is only known
+// once every file has been seen.
+type routeFacts struct {
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Middleware []RouteStep `json:"middleware"` // Applied with Use, or registered ahead of the handler
+	Chain      []routeRef  `json:"chain"`      // The handler argument, from the outermost call in
+	Line       int         `json:"line"`
+}
+
+// routeRef is one level of a handler argument such as Auth(RateLimit(h.List)).
+type routeRef struct {
+	RouteStep
+	Call bool `json:"call,omitempty"` // A call, whose argument is the next level if there is one
+}
+
+// Router methods that register a route for one HTTP method, in the casing
+// the common frameworks use: GET for gin and echo, Get for chi and fiber.
+var routeMethods = map[string]string{
+	"GET": "GET", "POST": "POST", "PUT": "PUT", "PATCH": "PATCH", "DELETE": "DELETE",
+	"HEAD": "HEAD", "OPTIONS": "OPTIONS", "CONNECT": "CONNECT", "TRACE": "TRACE", "Any": "ANY",
+	"Get": "GET", "Post": "POST", "Put": "PUT", "Patch": "PATCH", "Delete": "DELETE",
+	"Head": "HEAD", "Options": "OPTIONS", "Connect": "CONNECT", "Trace": "TRACE", "All": "ANY",
+	"Handle": "ANY", "HandleFunc": "ANY",
+}
+
+// router is a router or route group found while collecting routes.
+type router struct {
+	parent     *router
+	prefix     string
+	middleware []RouteStep // Applied with Use, or when the group was created
+	wrappers   []RouteStep // Functions wrapping the whole router, outermost first
+}
+
+type pendingRoute struct {
+	router *router
+	facts  routeFacts
+}
+
+// routeScope tracks routers and the types of local variables in a function.
+type routeScope struct {
+	routers map[string]*router
+	locals  map[string]string // Variable to its type name, where it can be told
+}
+
+// collectRoutes returns the routes registered in a file. Routers and groups
+// are followed within each function: gin and echo groups, chi Route, Group,
+// With and Mount, gorilla/mux subrouters and Methods, Go 1.22 method patterns,
+// and functions wrapping a whole router, such as Recover(mux).
+func collectRoutes(fset *token.FileSet, node *ast.File) []routeFacts {
+	handlerFirst := false // echo takes the handler before route middleware
+	for _, imp := range node.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); strings.Contains(path, "labstack/echo") {
+			handlerFirst = true
+		}
+	}
+
+	var routes []routeFacts
+	for _, decl := range node.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		scope := &routeScope{routers: make(map[string]*router), locals: make(map[string]string)}
+		scope.addParams(funcDecl.Type)
+		if funcDecl.Recv != nil {
+			scope.addParams(&ast.FuncType{Params: funcDecl.Recv})
+		}
+
+		c := &routeCollector{fset: fset, handlerFirst: handlerFirst}
+		c.walk(funcDecl.Body, scope)
+		for _, p := range c.pending {
+			routes = append(routes, p.materialize())
+		}
+	}
+	return routes
+}
+
+type routeCollector struct {
+	fset         *token.FileSet
+	handlerFirst bool
+	pending      []pendingRoute
+}
+
+func (s *routeScope) addParams(ft *ast.FuncType) {
+	if ft.Params == nil {
+		return
+	}
+	for _, field := range ft.Params.List {
+		typeName := extractTypeName(field.Type)
+		for _, name := range field.Names {
+			if typeName != "" {
+				s.locals[name.Name] = typeName
+			}
+		}
+	}
+}
+
+func (s *routeScope) child() *routeScope {
+	child := &routeScope{routers: make(map[string]*router), locals: make(map[string]string)}
+	for k, v := range s.routers {
+		child.routers[k] = v
+	}
+	for k, v := range s.locals {
+		child.locals[k] = v
+	}
+	return child
+}
+
+func (c *routeCollector) walk(body ast.Node, scope *routeScope) {
+	statements := make(map[*ast.CallExpr]bool) // Calls made for their effect, such as register(mux)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.ExprStmt:
+			if call, ok := t.X.(*ast.CallExpr); ok {
+				statements[call] = true
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range t.Lhs {
+				ident, ok := lhs.(*ast.Ident)
+				if !ok || i >= len(t.Rhs) || len(t.Lhs) != len(t.Rhs) {
+					continue
+				}
+				if r := c.routerOf(t.Rhs[i], scope, false); r != nil {
+					scope.routers[ident.Name] = r
+				}
+				if typeName := valueType(t.Rhs[i]); typeName != "" {
+					scope.locals[ident.Name] = typeName
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range t.Names {
+				if t.Type != nil {
+					scope.locals[name.Name] = extractTypeName(t.Type)
+				} else if i < len(t.Values) {
+					if typeName := valueType(t.Values[i]); typeName != "" {
+						scope.locals[name.Name] = typeName
+					}
+				}
+			}
+		case *ast.CallExpr:
+			return c.call(t, scope, statements[t])
+		}
+		return true
+	})
+}
+
+// call handles a call that may configure a router, and reports whether the
+// walk should descend into it.
+func (c *routeCollector) call(call *ast.CallExpr, scope *routeScope, statement bool) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return c.wrapper(call, scope, statement)
+	}
+
+	switch name := sel.Sel.Name; {
+	case name == "Use":
+		r := c.routerOf(sel.X, scope, true)
+		for _, arg := range call.Args {
+			r.middleware = append(r.middleware, stepOf(arg, scope))
+		}
+		return true
+
+	case name == "Route" && len(call.Args) == 2: // chi: r.Route("/orders", func(r chi.Router) {...})
+		prefix, ok := stringLit(call.Args[0])
+		lit, isLit := call.Args[1].(*ast.FuncLit)
+		if !ok || !isLit {
+			return true
+		}
+		c.walkGroup(lit, &router{parent: c.routerOf(sel.X, scope, true), prefix: prefix}, scope)
+		return false
+
+	case name == "Group" && len(call.Args) == 1: // chi: r.Group(func(r chi.Router) {...})
+		if lit, ok := call.Args[0].(*ast.FuncLit); ok {
+			c.walkGroup(lit, &router{parent: c.routerOf(sel.X, scope, true)}, scope)
+			return false
+		}
+
+	case name == "Mount" && len(call.Args) == 2: // chi: r.Mount("/admin", admin)
+		prefix, ok := stringLit(call.Args[0])
+		if ident, isIdent := call.Args[1].(*ast.Ident); ok && isIdent {
+			if sub := scope.routers[ident.Name]; sub != nil && sub.parent == nil {
+				sub.parent, sub.prefix = c.routerOf(sel.X, scope, true), prefix
+			}
+		}
+		return true
+
+	case name == "Methods": // gorilla/mux: r.HandleFunc("/orders", h).Methods("GET", "POST")
+		inner, ok := call.Fun.(*ast.SelectorExpr).X.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		innerSel, ok := inner.Fun.(*ast.SelectorExpr)
+		if !ok || (innerSel.Sel.Name != "HandleFunc" && innerSel.Sel.Name != "Handle") {
+			return true
+		}
+		for _, arg := range call.Args {
+			if method, ok := stringLit(arg); ok {
+				c.route(inner, innerSel, strings.ToUpper(method), scope)
+			}
+		}
+		return false
+
+	case name == "Method" || name == "MethodFunc" || name == "Add": // chi, echo and fiber: r.Method("GET", "/orders", h)
+		if len(call.Args) >= 3 {
+			if method, ok := stringLit(call.Args[0]); ok {
+				rest := &ast.CallExpr{Fun: call.Fun, Args: call.Args[1:], Lparen: call.Lparen}
+				c.route(rest, sel, strings.ToUpper(method), scope)
+			}
+		}
+		return true
+
+	case routeMethods[name] != "":
+		c.route(call, sel, routeMethods[name], scope)
+		return true
+	}
+	return c.wrapper(call, scope, statement)
+}
+
+// walkGroup walks a chi group function with its router parameter bound to r.
+func (c *routeCollector) walkGroup(lit *ast.FuncLit, r *router, scope *routeScope) {
+	inner := scope.child()
+	if params := lit.Type.Params; params != nil && len(params.List) > 0 && len(params.List[0].Names) > 0 {
+		inner.routers[params.List[0].Names[0].Name] = r
+	}
+	c.walk(lit.Body, inner)
+}
+
+// route records a route registered by call, a router method such as GET or
+// HandleFunc whose arguments are the path and the handlers.
+func (c *routeCollector) route(call *ast.CallExpr, sel *ast.SelectorExpr, method string, scope *routeScope) {
+	if len(call.Args) < 2 {
+		return
+	}
+	path, ok := stringLit(call.Args[0])
+	if !ok {
+		return
+	}
+	// Go 1.22 patterns carry the method: mux.HandleFunc("GET /orders", h)
+	if m, rest, found := strings.Cut(path, " "); found && method == "ANY" && strings.ToUpper(m) == m {
+		method, path = m, strings.TrimSpace(rest)
+	}
+
+	r := c.routerOf(sel.X, scope, true)
+	if !strings.HasPrefix(path, "/") && (path != "" || r.parent == nil) {
+		return // Not a route: cache.Get("key", &v) and the like
+	}
+
+	handlers := call.Args[1:]
+	handler := handlers[len(handlers)-1]
+	inline := handlers[:len(handlers)-1]
+	if c.handlerFirst {
+		handler, inline = handlers[0], handlers[1:]
+	}
+
+	facts := routeFacts{Method: method, Path: path, Chain: chainOf(handler, scope), Line: c.fset.Position(call.Pos()).Line}
+	for _, arg := range inline {
+		facts.Middleware = append(facts.Middleware, stepOf(arg, scope))
+	}
+	c.pending = append(c.pending, pendingRoute{router: r, facts: facts})
+}
+
+// wrapper records functions wrapping a whole router, as in
+// http.ListenAndServe(addr, Recover(Log(mux))). Calls made as statements,
+// such as registerRoutes(mux), configure the router rather than wrap it.
+func (c *routeCollector) wrapper(call *ast.CallExpr, scope *routeScope, statement bool) bool {
+	if statement || len(call.Args) != 1 {
+		return true
+	}
+	chain := chainOf(call, scope)
+	ident, ok := unwrapCalls(call).(*ast.Ident)
+	if !ok || scope.routers[ident.Name] == nil || len(chain) < 2 {
+		return true
+	}
+	r := scope.routers[ident.Name]
+	for _, ref := range chain[:len(chain)-1] {
+		r.wrappers = append(r.wrappers, ref.RouteStep)
+	}
+	return false
+}
+
+// routerOf returns the router an expression refers to: a variable bound to a
+// router, or a call deriving one, such as r.Group("/api") or
+// r.PathPrefix("/api").Subrouter(). Unknown expressions are new root routers
+// if create is set, and nil otherwise.
+func (c *routeCollector) routerOf(expr ast.Expr, scope *routeScope, create bool) *router {
+	if call, ok := expr.(*ast.CallExpr); ok {
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			switch sel.Sel.Name {
+			case "Group": // gin, echo and fiber: r.Group("/api", middleware...)
+				if len(call.Args) > 0 {
+					if prefix, ok := stringLit(call.Args[0]); ok {
+						g := &router{parent: c.routerOf(sel.X, scope, true), prefix: prefix}
+						for _, arg := range call.Args[1:] {
+							g.middleware = append(g.middleware, stepOf(arg, scope))
+						}
+						return g
+					}
+				}
+			case "With": // chi: r.With(Auth).Get(...)
+				g := &router{parent: c.routerOf(sel.X, scope, true)}
+				for _, arg := range call.Args {
+					g.middleware = append(g.middleware, stepOf(arg, scope))
+				}
+				return g
+			case "Subrouter": // gorilla/mux: r.PathPrefix("/api").Subrouter()
+				if inner, ok := sel.X.(*ast.CallExpr); ok {
+					if innerSel, ok := inner.Fun.(*ast.SelectorExpr); ok && innerSel.Sel.Name == "PathPrefix" && len(inner.Args) == 1 {
+						prefix, _ := stringLit(inner.Args[0])
+						return &router{parent: c.routerOf(innerSel.X, scope, true), prefix: prefix}
+					}
+				}
+			case "NewRouter", "NewServeMux", "New", "Default", "NewMux": // Constructors of root routers
+				if create || isRouterPackage(sel.X) {
+					return &router{}
+				}
+			}
+		}
+	}
+	if !create {
+		return nil
+	}
+
+	key := types.ExprString(expr)
+	if r := scope.routers[key]; r != nil {
+		return r
+	}
+	r := &router{}
+	scope.routers[key] = r
+	return r
+}
+
+// isRouterPackage reports whether expr names a package that provides routers.
+func isRouterPackage(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	switch ident.Name {
+	case "http", "chi", "mux", "gin", "echo", "fiber", "httprouter":
+		return true
+	}
+	return false
+}
+
+// materialize resolves a route's path and middleware through its routers.
+func (p pendingRoute) materialize() routeFacts {
+	var chain []*router
+	for r := p.router; r != nil; r = r.parent {
+		chain = append([]*router{r}, chain...)
+	}
+
+	facts := p.facts
+	var middleware []RouteStep
+	var prefix string
+	middleware = append(middleware, chain[0].wrappers...)
+	for _, r := range chain {
+		prefix = joinPath(prefix, r.prefix)
+		middleware = append(middleware, r.middleware...)
+	}
+	facts.Middleware = append(middleware, facts.Middleware...)
+	facts.Path = joinPath(prefix, facts.Path)
+	if facts.Path == "" {
+		facts.Path = "/"
+	}
+	return facts
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case path == "" || (path == "/" && prefix != ""):
+		return prefix
+	case prefix == "":
+		return path
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// chainOf unwraps a handler argument such as Auth(RateLimit(h.List)) into its
+// levels. Conversions to handler types, as in http.HandlerFunc(h.List), are
+// looked through.
+func chainOf(expr ast.Expr, scope *routeScope) []routeRef {
+	var chain []routeRef
+	for {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok {
+			return append(chain, routeRef{RouteStep: stepOf(expr, scope)})
+		}
+		if handlerTypes[qualifiedTypeName(call.Fun)] != "" && len(call.Args) == 1 {
+			expr = call.Args[0]
+			continue
+		}
+		ref := routeRef{RouteStep: stepOf(call.Fun, scope), Call: true}
+		if len(call.Args) != 1 {
+			return append(chain, ref)
+		}
+		chain = append(chain, ref)
+		expr = call.Args[0]
+	}
+}
+
+// unwrapCalls returns the innermost argument of nested single-argument calls.
+func unwrapCalls(expr ast.Expr) ast.Expr {
+	for {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return expr
+		}
+		expr = call.Args[0]
+	}
+}
+
+// stepOf names a middleware or handler expression, with the component it
+// likely belongs to. Method values on variables of known type are named by
+// the type, as in OrdersHandler.List; package-qualified functions drop the
+// package, as in Auth for middleware.Auth.
+func stepOf(expr ast.Expr, scope *routeScope) RouteStep {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if typeName := scope.locals[t.Name]; typeName != "" {
+			return RouteStep{Name: typeName, Component: typeName}
+		}
+		return RouteStep{Name: t.Name, Component: t.Name}
+	case *ast.SelectorExpr:
+		if ident, ok := t.X.(*ast.Ident); ok {
+			if typeName := scope.locals[ident.Name]; typeName != "" {
+				return RouteStep{Name: typeName + "." + t.Sel.Name, Component: typeName}
+			}
+			if _, isRouter := scope.routers[ident.Name]; !isRouter {
+				return RouteStep{Name: t.Sel.Name, Component: t.Sel.Name}
+			}
+		}
+	case *ast.CallExpr:
+		// A middleware constructor, such as RateLimit(100) in r.Use(RateLimit(100))
+		return stepOf(t.Fun, scope)
+	case *ast.FuncLit:
+		return RouteStep{Name: "func literal"}
+	}
+	return RouteStep{Name: types.ExprString(expr)}
+}
+
+// valueType returns the type of a value when it can be told from the
+// expression: &OrdersHandler{}, OrdersHandler{} or NewOrdersHandler(...).
+func valueType(expr ast.Expr) string {
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		expr = unary.X
+	}
+	switch t := expr.(type) {
+	case *ast.CompositeLit:
+		return extractTypeName(t.Type)
+	case *ast.CallExpr:
+		name := extractTypeName(t.Fun)
+		if typeName, ok := strings.CutPrefix(name, "New"); ok && typeName != "" && ast.IsExported(typeName) {
+			return typeName
+		}
+	}
+	return ""
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// resolveRoutes peels the middleware off each route's handler chain, and
+// links steps to components where one has the name. middleware holds the
+// names of everything applied as middleware, or declared as such.
+func resolveRoutes(files []fileFacts, middleware, components map[string]bool) []Route {
+	link := func(step RouteStep) RouteStep {
+		if !components[step.Component] {
+			step.Component = ""
+		}
+		return step
+	}
+
+	routes := []Route{}
+	for _, file := range files {
+		for _, rf := range file.Routes {
+			route := Route{Method: rf.Method, Path: rf.Path, Middleware: []RouteStep{}, FilePath: file.Path, Line: rf.Line}
+			for _, step := range rf.Middleware {
+				route.Middleware = append(route.Middleware, link(step))
+			}
+
+			i := 0
+			for ; i < len(rf.Chain)-1; i++ {
+				ref := rf.Chain[i]
+				if !middleware[ref.Component] && !middleware[ref.Name] {
+					break
+				}
+				route.Middleware = append(route.Middleware, link(ref.RouteStep))
+			}
+			handler := rf.Chain[i]
+			if handler.Call {
+				handler.Name += "()"
+			}
+			route.Handler = link(handler.RouteStep)
+			routes = append(routes, route)
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// usedAsMiddleware returns the names applied as middleware in any route.
+func usedAsMiddleware(files []fileFacts) map[string]bool {
+	names := make(map[string]bool)
+	for _, file := range files {
+		for _, rf := range file.Routes {
+			for _, step := range rf.Middleware {
+				names[step.Name] = true
+				if step.Component != "" {
+					names[step.Component] = true
+				}
+			}
+		}
+	}
+	return names
+}
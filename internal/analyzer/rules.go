@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity ranks how serious a rule violation is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Violation is a breach of one of the built-in architecture rules.
+type Violation struct {
+	Rule      string   `json:"rule"`
+	Severity  Severity `json:"severity"`
+	Component string   `json:"component"`
+	Target    string   `json:"target,omitempty"` // Dependency involved, if any
+	Message   string   `json:"message"`
+}
+
+// Built-in rule names.
+const (
+	RuleLayering        = "layering"          // Dependencies must point down the layers
+	RuleSkipServiceTier = "skip-service-tier" // Handlers should reach data through services
+	RuleDependencyCycle = "dependency-cycle"  // Components must not depend on each other in a cycle
+	RuleHighFanOut      = "high-fan-out"      // Components should not have too many dependencies
+)
+
+// MaxFanOut is the number of dependencies above which RuleHighFanOut fires.
+const MaxFanOut = 7
+
+// layerRank orders component types from the transport layer down.
+var layerRank = map[ComponentType]int{
+	ComponentMiddleware: 0,
+	ComponentHandler:    0,
+	ComponentService:    1,
+	ComponentAdapter:    2,
+	ComponentRepository: 2,
+}
+
+// CheckRules checks an architecture against the built-in rules. Violations
+// are ordered by severity, then component name.
+func CheckRules(arch *Architecture) []Violation {
+	violations := []Violation{}
+
+	types := make(map[string]ComponentType, len(arch.Components))
+	for _, comp := range arch.Components {
+		types[comp.Name] = comp.Type
+	}
+
+	for _, comp := range arch.Components {
+		for _, dep := range comp.Dependencies {
+			depType, ok := types[dep]
+			if !ok {
+				continue
+			}
+
+			if layerRank[depType] < layerRank[comp.Type] {
+				violations = append(violations, Violation{
+					Rule:      RuleLayering,
+					Severity:  SeverityError,
+					Component: comp.Name,
+					Target:    dep,
+					Message:   fmt.Sprintf("%s %s depends on %s %s in a higher layer", comp.Type, comp.Name, depType, dep),
+				})
+			}
+
+			if comp.Type == ComponentHandler && depType == ComponentRepository {
+				violations = append(violations, Violation{
+					Rule:      RuleSkipServiceTier,
+					Severity:  SeverityWarning,
+					Component: comp.Name,
+					Target:    dep,
+					Message:   fmt.Sprintf("handler %s uses repository %s directly instead of through a service", comp.Name, dep),
+				})
+			}
+		}
+
+		if len(comp.Dependencies) > MaxFanOut {
+			violations = append(violations, Violation{
+				Rule:      RuleHighFanOut,
+				Severity:  SeverityWarning,
+				Component: comp.Name,
+				Message:   fmt.Sprintf("%s has %d dependencies (more than %d)", comp.Name, len(comp.Dependencies), MaxFanOut),
+			})
+		}
+	}
+
+	for _, cycle := range arch.Cycles() {
+		violations = append(violations, Violation{
+			Rule:      RuleDependencyCycle,
+			Severity:  SeverityError,
+			Component: cycle[0],
+			Message:   fmt.Sprintf("dependency cycle between %s", strings.Join(cycle, ", ")),
+		})
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Severity != violations[j].Severity {
+			return violations[i].Severity == SeverityError
+		}
+		return violations[i].Component < violations[j].Component
+	})
+
+	return violations
+}
@@ -0,0 +1,311 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// System is a set of services and how they connect, from each service's own
+// analysis.
+type System struct {
+	Services  []Service         `json:"services"`
+	External  []ExternalSystem  `json:"external"`        // Hosts called over HTTP that no service owns
+	Links     []SystemLink      `json:"links"`           // From the calling or producing service
+	Unmatched []ServiceEndpoint `json:"unmatched"`       // gRPC clients and topics nothing else in the system serves or consumes
+	Build     *BuildConfig      `json:"build,omitempty"` // The build configuration all services were analyzed with, if any
+}
+
+// Service is one analyzed repository in a system.
+type Service struct {
+	Name       string     `json:"name"`
+	RepoPath   string     `json:"repoPath"`
+	Module     string     `json:"module,omitempty"` // Path of its root module
+	Components int        `json:"components"`
+	Routes     int        `json:"routes"`
+	Endpoints  []Endpoint `json:"endpoints"`
+	Hosts      []string   `json:"hosts,omitempty"` // Host names it is reached at, besides its own name
+
+	arch *Architecture
+}
+
+// ExternalSystem is a host services call over HTTP that is not one of them.
+type ExternalSystem struct {
+	Name string   `json:"name"` // The host, e.g. "api.stripe.com"
+	URLs []string `json:"urls"`
+}
+
+// SystemLink connects two services, or a service and an external system.
+type SystemLink struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Kind     EndpointKind `json:"kind"`
+	External bool         `json:"external,omitempty"` // To is an external system
+	Via      []LinkVia    `json:"via"`                // What connects them, e.g. one entry per route called
+}
+
+// LinkVia is one gRPC service, route, URL or topic behind a link, with where
+// the calling or producing service uses it.
+type LinkVia struct {
+	Name     string `json:"name"` // e.g. "PaymentService", "GET /orders/{id}" or "orders.created"
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+}
+
+// ServiceEndpoint is an endpoint together with the service it belongs to.
+type ServiceEndpoint struct {
+	Service string `json:"service"`
+	Endpoint
+}
+
+// NewService names an analyzed repository as a service. The name is the last
+// element of its root module path, without a major version suffix such as
+// /v2, or else of its directory.
+func NewService(repoPath string, arch *Architecture) Service {
+	svc := Service{
+		Name:       filepath.Base(filepath.Clean(repoPath)),
+		RepoPath:   repoPath,
+		Components: len(arch.Components),
+		Routes:     len(arch.Routes),
+		Endpoints:  arch.Endpoints,
+		arch:       arch,
+	}
+	for _, m := range arch.Modules {
+		if m.Dir == "." {
+			svc.Module = m.Path
+			svc.Name = path.Base(trimMajorVersion(m.Path))
+		}
+	}
+	if svc.Endpoints == nil {
+		svc.Endpoints = []Endpoint{}
+	}
+	return svc
+}
+
+// LinkServices connects services through what they serve and call. A gRPC
+// client links to the service registering that gRPC service, and a topic's
+// producers to its consumers. An HTTP URL links to the service its host names,
+// such as http://payments-svc:8080 for payments, or one of its Hosts, or else
+// to the one service serving a route that matches its path. URLs that match
+// no service are calls to external systems, unless they are local.
+func LinkServices(services []Service) *System {
+	sys := &System{Services: services, External: []ExternalSystem{}, Links: []SystemLink{}, Unmatched: []ServiceEndpoint{}}
+
+	for i, svc := range sys.Services {
+		if svc.arch == nil || svc.arch.Build == nil || (i > 0 && svc.arch.Build.String() != sys.Build.String()) {
+			sys.Build = nil
+			break
+		}
+		sys.Build = svc.arch.Build
+	}
+
+	// Deduplicate names, so links are unambiguous
+	seen := make(map[string]int)
+	for i := range sys.Services {
+		name := sys.Services[i].Name
+		if seen[name]++; seen[name] > 1 {
+			sys.Services[i].Name = fmt.Sprintf("%s-%d", name, seen[name])
+		}
+	}
+
+	providers := make(map[EndpointKind]map[string][]string) // Kind and name to the services providing it
+	for _, svc := range sys.Services {
+		for _, e := range svc.Endpoints {
+			if e.Role != RoleProvides {
+				continue
+			}
+			if providers[e.Kind] == nil {
+				providers[e.Kind] = make(map[string][]string)
+			}
+			providers[e.Kind][e.Name] = append(providers[e.Kind][e.Name], svc.Name)
+		}
+	}
+
+	type linkKey struct {
+		from, to string
+		kind     EndpointKind
+	}
+	links := make(map[linkKey]*SystemLink)
+	var order []linkKey
+	link := func(from, to string, kind EndpointKind, external bool, via LinkVia) {
+		key := linkKey{from, to, kind}
+		l, ok := links[key]
+		if !ok {
+			l = &SystemLink{From: from, To: to, Kind: kind, External: external}
+			links[key] = l
+			order = append(order, key)
+		}
+		for _, v := range l.Via {
+			if v.Name == via.Name {
+				return
+			}
+		}
+		l.Via = append(l.Via, via)
+	}
+
+	external := make(map[string]*ExternalSystem)
+	var externalOrder []string
+	for _, svc := range sys.Services {
+		for _, e := range svc.Endpoints {
+			if e.Role != RoleConsumes {
+				continue
+			}
+			via := LinkVia{Name: e.Name, FilePath: e.FilePath, Line: e.Line}
+
+			if e.Kind == EndpointHTTP {
+				to, route, ok := sys.httpTarget(svc.Name, e.Name)
+				if ok {
+					if route != "" {
+						via.Name = route
+					}
+					link(svc.Name, to, EndpointHTTP, false, via)
+					continue
+				}
+				host := urlHost(e.Name)
+				if host == "" || isLocalHost(host) {
+					continue
+				}
+				if external[host] == nil {
+					external[host] = &ExternalSystem{Name: host}
+					externalOrder = append(externalOrder, host)
+				}
+				if !slices.Contains(external[host].URLs, e.Name) {
+					external[host].URLs = append(external[host].URLs, e.Name)
+				}
+				link(svc.Name, host, EndpointHTTP, true, via)
+				continue
+			}
+
+			matched := false
+			for _, to := range providers[e.Kind][e.Name] {
+				if to != svc.Name {
+					link(svc.Name, to, e.Kind, false, via)
+					matched = true
+				}
+			}
+			if !matched {
+				sys.Unmatched = append(sys.Unmatched, ServiceEndpoint{Service: svc.Name, Endpoint: e})
+			}
+		}
+	}
+
+	for _, key := range order {
+		sys.Links = append(sys.Links, *links[key])
+	}
+	for _, host := range externalOrder {
+		sys.External = append(sys.External, *external[host])
+	}
+	sort.SliceStable(sys.Links, func(i, j int) bool {
+		if sys.Links[i].From != sys.Links[j].From {
+			return sys.Links[i].From < sys.Links[j].From
+		}
+		return sys.Links[i].To < sys.Links[j].To
+	})
+	return sys
+}
+
+// httpTarget finds the service a URL called from the named service goes to,
+// and the route it calls if that decided it.
+func (s *System) httpTarget(from, rawURL string) (string, string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "", "", false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, svc := range s.Services {
+		if svc.Name != from && svc.reachedAt(host) {
+			return svc.Name, "", true
+		}
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		return "", "", false
+	}
+	var owner, route string
+	for _, svc := range s.Services {
+		if svc.Name == from || svc.arch == nil {
+			continue
+		}
+		for _, r := range svc.arch.Routes {
+			if matchRoute(r.Path, u.Path) {
+				if owner != "" && owner != svc.Name {
+					return "", "", false // Ambiguous
+				}
+				owner, route = svc.Name, r.Method+" "+r.Path
+				break
+			}
+		}
+	}
+	return owner, route, owner != ""
+}
+
+// reachedAt reports whether a host names the service: one of its Hosts, its
+// own name as a bare host, e.g. payments-svc for payments, or a Kubernetes
+// service name such as payments.default.svc.cluster.local. Hosts with a
+// domain name only their own owner, so api.stripe.com is not a service "api".
+func (svc Service) reachedAt(host string) bool {
+	for _, h := range svc.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	name, domain, _ := strings.Cut(host, ".")
+	if domain != "" && domain != "svc" && !strings.HasSuffix(domain, ".svc") && !strings.HasSuffix(domain, ".svc.cluster.local") {
+		return false
+	}
+	return normalizeServiceName(name) == normalizeServiceName(svc.Name)
+}
+
+// matchRoute reports whether a URL path reaches a route pattern. Parameters
+// such as {id}, :id and * match any segment, and a URL path that is a prefix
+// of the pattern, such as a client's base path, matches too.
+func matchRoute(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pathSegments) > len(patternSegments) {
+		return false
+	}
+	for i, seg := range pathSegments {
+		p := patternSegments[i]
+		if strings.HasPrefix(p, "{") || strings.HasPrefix(p, ":") || p == "*" {
+			continue
+		}
+		if p != seg {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeServiceName reduces a service or host name to what the two share,
+// so that "payments-svc", "payments_service" and "Payments" all match.
+func normalizeServiceName(name string) string {
+	name = strings.ToLower(name)
+	for _, suffix := range []string{"-service", "_service", "-svc", "_svc", "-api", "_api", "service", "svc"} {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != "" {
+			name = trimmed
+		}
+	}
+	return strings.NewReplacer("-", "", "_", "").Replace(name)
+}
+
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func isLocalHost(host string) bool {
+	switch host {
+	case "localhost", "127.0.0.1", "0.0.0.0", "::1", "example.com":
+		return true
+	}
+	return strings.HasSuffix(host, ".example.com") || strings.HasSuffix(host, ".local")
+}
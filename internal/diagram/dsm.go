@@ -0,0 +1,82 @@
+package diagram
+
+import (
+	"sort"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+)
+
+// partitionDSM orders nodes for a design structure matrix. Consumers come
+// before the nodes they depend on, so dependencies sit above the diagonal and
+// anything below it is feedback. Nodes in the same dependency cycle are kept
+// adjacent and reported in cyclic.
+//
+// Nodes with no ordering constraint between them keep their relative order
+// from nodes.
+func partitionDSM(nodes []string, edges map[string][]string) (order []string, cyclic map[string]bool) {
+	components := analyzer.StronglyConnected(nodes, edges)
+
+	// Map every node to its component
+	position := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		position[node] = i
+	}
+	componentOf := make(map[string]int, len(nodes))
+	first := make([]int, len(components)) // earliest position of a member, for tie breaking
+	for c, component := range components {
+		sort.Slice(component, func(i, j int) bool { return position[component[i]] < position[component[j]] })
+		first[c] = position[component[0]]
+		for _, node := range component {
+			componentOf[node] = c
+		}
+	}
+
+	// Topologically sort the condensed graph, consumers first
+	indegree := make([]int, len(components))
+	successors := make([]map[int]bool, len(components))
+	for c := range components {
+		successors[c] = make(map[int]bool)
+	}
+	for _, node := range nodes {
+		for _, target := range edges[node] {
+			to, ok := componentOf[target]
+			from := componentOf[node]
+			if !ok || to == from || successors[from][to] {
+				continue
+			}
+			successors[from][to] = true
+			indegree[to]++
+		}
+	}
+
+	var ready []int
+	for c := range components {
+		if indegree[c] == 0 {
+			ready = append(ready, c)
+		}
+	}
+
+	cyclic = make(map[string]bool)
+	order = make([]string, 0, len(nodes))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return first[ready[i]] < first[ready[j]] })
+		c := ready[0]
+		ready = ready[1:]
+
+		component := components[c]
+		if len(component) > 1 {
+			for _, node := range component {
+				cyclic[node] = true
+			}
+		}
+		order = append(order, component...)
+
+		for to := range successors[c] {
+			indegree[to]--
+			if indegree[to] == 0 {
+				ready = append(ready, to)
+			}
+		}
+	}
+	return order, cyclic
+}
@@ -1,15 +1,18 @@
 package diagram
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/junkd0g/sharingan/internal/analyzer"
 )
 
 func TestGenerateHTML(t *testing.T) {
-	arch, err := analyzer.Analyze("/Users/iordanispaschalidis/gear/offsidecompass/ai-assistant")
+	arch, err := analyzer.Analyze(context.Background(), "/Users/iordanispaschalidis/gear/offsidecompass/ai-assistant", nil)
 	if err != nil {
 		t.Fatalf("Failed to analyze: %v", err)
 	}
@@ -41,7 +44,7 @@ func TestGenerateHTML(t *testing.T) {
 }
 
 func TestGenerateHTMLWithCustomWidgets(t *testing.T) {
-	arch, err := analyzer.Analyze("/Users/iordanispaschalidis/gear/offsidecompass/ai-assistant")
+	arch, err := analyzer.Analyze(context.Background(), "/Users/iordanispaschalidis/gear/offsidecompass/ai-assistant", nil)
 	if err != nil {
 		t.Fatalf("Failed to analyze: %v", err)
 	}
@@ -73,7 +76,7 @@ func TestGenerateHTMLWithCustomWidgets(t *testing.T) {
 }
 
 func TestGenerateHTMLLightTheme(t *testing.T) {
-	arch, err := analyzer.Analyze("/Users/iordanispaschalidis/gear/offsidecompass/ai-assistant")
+	arch, err := analyzer.Analyze(context.Background(), "/Users/iordanispaschalidis/gear/offsidecompass/ai-assistant", nil)
 	if err != nil {
 		t.Fatalf("Failed to analyze: %v", err)
 	}
@@ -94,3 +97,264 @@ func TestGenerateHTMLLightTheme(t *testing.T) {
 
 	t.Logf("Generated %s with light theme", outputPath)
 }
+
+func testArchitecture() *analyzer.Architecture {
+	return &analyzer.Architecture{
+		Components: []analyzer.Component{
+			{Name: "OrderHandler", Type: analyzer.ComponentHandler, Package: "http", FilePath: "internal/http/order.go", Dependencies: []string{"OrderService"}},
+			{Name: "OrderService", Type: analyzer.ComponentService, Package: "order", FilePath: "internal/order/service.go", Dependencies: []string{"OrderRepository"}},
+			{Name: "OrderRepository", Type: analyzer.ComponentRepository, Package: "postgres", FilePath: "internal/postgres/order.go"},
+		},
+		Dependencies: map[string][]string{
+			"OrderHandler": {"OrderService"},
+			"OrderService": {"OrderRepository"},
+		},
+	}
+}
+
+func TestGenerateHTMLEscapesConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Title = `<script>alert("title")</script>`
+	config.Description = `<img src=x onerror=alert(1)>`
+
+	outputPath := filepath.Join(t.TempDir(), "report.html")
+	if err := GenerateHTML(testArchitecture(), outputPath, config); err != nil {
+		t.Fatalf("Failed to generate HTML: %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read HTML: %v", err)
+	}
+	html := string(content)
+
+	for _, raw := range []string{config.Title, config.Description} {
+		if strings.Contains(html, raw) {
+			t.Errorf("HTML contains unescaped %q", raw)
+		}
+	}
+	if !strings.Contains(html, "&lt;script&gt;alert(&#34;title&#34;)&lt;/script&gt;") {
+		t.Error("HTML does not contain the escaped title")
+	}
+}
+
+func TestGenerateHTMLTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	override := `{{define "footer"}}<footer>custom footer</footer></div>{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "footer.tmpl"), []byte(override), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.TemplateDir = dir
+
+	outputPath := filepath.Join(t.TempDir(), "report.html")
+	if err := GenerateHTML(testArchitecture(), outputPath, config); err != nil {
+		t.Fatalf("Failed to generate HTML: %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read HTML: %v", err)
+	}
+	if !strings.Contains(string(content), "custom footer") {
+		t.Error("HTML does not use the overridden footer")
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	theme, err := LoadTheme(`{"name": "acme", "base": "light", "palette": {"handler": "#E4002B"}, "fonts": {"body": "Inter, sans-serif"}}`)
+	if err != nil {
+		t.Fatalf("Failed to load inline theme: %v", err)
+	}
+	if theme.Color(analyzer.ComponentHandler) != "#E4002B" {
+		t.Errorf("handler colour = %s, want #E4002B", theme.Color(analyzer.ComponentHandler))
+	}
+	if theme.Color(analyzer.ComponentService) != builtinThemes["light"].Palette[analyzer.ComponentService] {
+		t.Error("service colour was not inherited from the base theme")
+	}
+	if theme.Variables["--bg"] != lightVariables["--bg"] {
+		t.Error("variables were not inherited from the base theme")
+	}
+
+	invalid := []string{
+		`{"variables": {"--bg": "red; } body { display: none"}}`,
+		`{"palette": {"service": "rgb(0,0,0)"}}`,
+		`{"base": "solarized"}`,
+		"no-such-theme",
+	}
+	for _, spec := range invalid {
+		if _, err := LoadTheme(spec); err == nil {
+			t.Errorf("LoadTheme(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestBuiltinThemePalettes(t *testing.T) {
+	defaults := builtinThemes["dark"].Palette
+	for _, name := range ThemeNames() {
+		theme := builtinThemes[name]
+		used := make(map[string]analyzer.ComponentType)
+		shared := 0
+		for componentType := range categoryMap {
+			color := theme.Color(componentType)
+			if other, ok := used[color]; ok {
+				t.Errorf("%s theme: %s and %s are both %s", name, other, componentType, color)
+			}
+			used[color] = componentType
+			if color == defaults[componentType] {
+				shared++
+			}
+		}
+		// A palette is either the default one or its own, never a mix
+		if shared != 0 && shared != len(categoryMap) {
+			t.Errorf("%s theme shares %d of its colours with the default palette", name, shared)
+		}
+	}
+}
+
+func TestBuildMatrixData(t *testing.T) {
+	arch := &analyzer.Architecture{
+		Components: []analyzer.Component{
+			{Name: "UserRepository", Type: analyzer.ComponentRepository, Package: "postgres", FilePath: "internal/postgres/user.go"},
+			{Name: "UserService", Type: analyzer.ComponentService, Package: "user", FilePath: "internal/user/service.go", Dependencies: []string{"UserRepository", "AuditService"}},
+			{Name: "AuditService", Type: analyzer.ComponentService, Package: "user", FilePath: "internal/user/audit.go", Dependencies: []string{"UserService"}},
+			{Name: "UserHandler", Type: analyzer.ComponentHandler, Package: "http", FilePath: "internal/http/user.go", Dependencies: []string{"UserService"}},
+		},
+	}
+	builder := &HTMLBuilder{arch: arch, config: DefaultConfig(), theme: builtinThemes["dark"]}
+	matrix := builder.buildMatrixData(builder.buildComponentData())
+
+	wantLabels := []string{"UserHandler", "AuditService", "UserService", "UserRepository"}
+	if strings.Join(matrix.Labels, ",") != strings.Join(wantLabels, ",") {
+		t.Errorf("labels = %v, want %v", matrix.Labels, wantLabels)
+	}
+
+	wantGroups := []string{"internal/http", "internal/user", "internal/postgres"}
+	for i, group := range matrix.Groups {
+		if group.Name != wantGroups[i] {
+			t.Errorf("group %d = %s, want %s", i, group.Name, wantGroups[i])
+		}
+	}
+
+	wantCyclic := []bool{false, true, true, false}
+	for i, cyclic := range matrix.Cyclic {
+		if cyclic != wantCyclic[i] {
+			t.Errorf("cyclic[%s] = %v, want %v", matrix.Labels[i], cyclic, wantCyclic[i])
+		}
+	}
+
+	if len(matrix.Cells) != 4 {
+		t.Errorf("got %d cells, want 4", len(matrix.Cells))
+	}
+}
+
+func TestSourceURL(t *testing.T) {
+	arch := testArchitecture()
+	arch.Repository = analyzer.Repository{
+		Commit:    "0123abcd",
+		RemoteURL: "git@github.com:acme/orders.git",
+		Subdir:    "services/orders",
+	}
+
+	builder := &HTMLBuilder{arch: arch, config: DefaultConfig()}
+	want := "https://github.com/acme/orders/blob/0123abcd/services/orders/internal/http/order.go#L12"
+	if got := builder.sourceURL("internal/http/order.go", 12); got != want {
+		t.Errorf("sourceURL = %s, want %s", got, want)
+	}
+
+	builder.config.SourceURLTemplate = "javascript:alert('{path}')"
+	if got := builder.sourceURL("internal/http/order.go", 12); got != "" {
+		t.Errorf("sourceURL with a javascript: template = %s, want empty", got)
+	}
+}
+
+func TestRenderHTMLDiagnostics(t *testing.T) {
+	arch := testArchitecture()
+	arch.Diagnostics = []analyzer.Diagnostic{
+		{Kind: analyzer.DiagnosticParseError, FilePath: "internal/broken.go", Line: 3, Message: "expected ';', found <b>"},
+		{Kind: analyzer.DiagnosticExcludedStruct, FilePath: "internal/http/order.go", Line: 9, Component: "CreateOrderRequest", Message: "struct CreateOrderRequest was excluded"},
+	}
+
+	html, err := RenderHTML(arch, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to render HTML: %v", err)
+	}
+
+	for _, want := range []string{
+		`<option value="parse-error">Parse error (1)</option>`,
+		`<tr data-kind="excluded-struct">`,
+		"found &lt;b&gt;",
+	} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("HTML does not contain %q", want)
+		}
+	}
+}
+
+func TestRenderHTMLEscapesModulePath(t *testing.T) {
+	repo := t.TempDir()
+	module := "example.com/<img/src=x/onerror=alert(1)>"
+	files := map[string]string{
+		"go.mod": "module " + module + "\n",
+		"internal/service/order.go": `package service
+
+type OrderStore interface{ Save() }
+
+type OrderService struct {
+	store OrderStore
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	arch, err := analyzer.Analyze(context.Background(), repo, nil)
+	if err != nil {
+		t.Fatalf("Failed to analyze: %v", err)
+	}
+	if len(arch.Components) == 0 || arch.Components[0].Module != module {
+		t.Fatalf("components = %+v, want them in module %s", arch.Components, module)
+	}
+
+	html, err := RenderHTML(arch, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to render HTML: %v", err)
+	}
+	if strings.Contains(string(html), "<img/src=x") {
+		t.Error("HTML contains the unescaped module path")
+	}
+	// The module and generator reach the tooltip, which is HTML, as data
+	for _, field := range []string{"esc(p.data.module)", "esc(p.data.generated)"} {
+		if !strings.Contains(string(html), field) {
+			t.Errorf("tooltip does not escape %s", field)
+		}
+	}
+}
+
+func TestRenderSystemHTMLEscapesURLs(t *testing.T) {
+	sys := &analyzer.System{
+		Services: []analyzer.Service{{Name: "orders", Module: "example.com/orders"}},
+		External: []analyzer.ExternalSystem{{Name: "api.stripe.com", URLs: []string{"https://api.stripe.com/<img src=x onerror=alert(1)>"}}},
+		Links:    []analyzer.SystemLink{{From: "orders", To: "api.stripe.com", Kind: analyzer.EndpointHTTP}},
+	}
+
+	html, err := RenderSystemHTML(sys, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to render HTML: %v", err)
+	}
+	if strings.Contains(string(html), "<img src=x") {
+		t.Error("HTML contains the unescaped URL")
+	}
+	// Descriptions reach the tooltip, which is HTML, one escaped line at a time
+	if !strings.Contains(string(html), "p.data.description.split('\\n').map(esc)") {
+		t.Error("tooltip does not escape descriptions")
+	}
+}
@@ -1,8 +1,10 @@
 package diagram
 
 import (
-	"encoding/json"
 	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/junkd0g/sharingan/internal/analyzer"
@@ -20,6 +22,16 @@ const (
 	WidgetStatsCards        WidgetType = "stats_cards"
 	WidgetDependencyMatrix  WidgetType = "dependency_matrix"
 	WidgetPackageTree       WidgetType = "package_tree"
+	WidgetDiagnostics       WidgetType = "diagnostics"
+	WidgetRoutes            WidgetType = "routes"
+	WidgetContracts         WidgetType = "contracts"
+	WidgetAPI               WidgetType = "api"
+)
+
+// Dependency matrix groupings.
+const (
+	MatrixGroupByPackage = "package"
+	MatrixGroupByLayer   = "layer"
 )
 
 // HTMLConfig configures what to include in the HTML report.
@@ -27,7 +39,16 @@ type HTMLConfig struct {
 	Title       string
 	Description string
 	Widgets     []WidgetType
-	Theme       string // "dark" or "light"
+	Theme       string // Built-in theme name, path to a JSON theme file or inline JSON theme
+	TemplateDir string // Optional directory of *.tmpl files overriding the built-in templates
+
+	MatrixGroupBy string // MatrixGroupByPackage (default) or MatrixGroupByLayer
+
+	// SourceURLTemplate links components to a repository browser. The
+	// placeholders {commit}, {path} and {line} are substituted, e.g.
+	// "https://github.com/acme/orders/blob/{commit}/{path}#L{line}". When empty,
+	// a template is derived from the origin remote for GitHub, GitLab and Bitbucket.
+	SourceURLTemplate string
 }
 
 // DefaultConfig returns a full-featured default configuration.
@@ -44,6 +65,10 @@ func DefaultConfig() HTMLConfig {
 			WidgetLayerFlow,
 			WidgetDependencyMatrix,
 			WidgetComponentsTable,
+			WidgetRoutes,
+			WidgetAPI,
+			WidgetContracts,
+			WidgetDiagnostics,
 		},
 	}
 }
@@ -52,28 +77,103 @@ func DefaultConfig() HTMLConfig {
 type HTMLBuilder struct {
 	arch   *analyzer.Architecture
 	config HTMLConfig
+	theme  Theme
 	data   *ReportData
 }
 
 // ReportData holds all computed data for the report.
 type ReportData struct {
-	Components []ComponentData `json:"components"`
-	Graph      GraphData       `json:"graph"`
-	Stats      StatsData       `json:"stats"`
-	Layers     []LayerData     `json:"layers"`
-	Matrix     MatrixData      `json:"matrix"`
-	Packages   []PackageData   `json:"packages"`
+	Components []ComponentData   `json:"components"`
+	Graph      GraphData         `json:"graph"`
+	Stats      StatsData         `json:"stats"`
+	Layers     []LayerData       `json:"layers"`
+	Matrix     MatrixData        `json:"matrix"`
+	Packages   []PackageData     `json:"packages"`
+	Modules    []analyzer.Module `json:"modules"`
+
+	Diagnostics      []DiagnosticData  `json:"-"` // Rendered into the page, not needed by scripts
+	DiagnosticCounts []DiagnosticCount `json:"-"`
+	Routes           []RouteData       `json:"-"`
+	Contracts        []ContractData    `json:"-"`
+	API              APIData           `json:"-"`
 }
 
 type ComponentData struct {
-	Name         string   `json:"name"`
-	Type         string   `json:"type"`
-	Package      string   `json:"package"`
-	FilePath     string   `json:"filePath"`
-	Dependencies []string `json:"dependencies"`
-	DependedBy   []string `json:"dependedBy"`
-	Color        string   `json:"color"`
-	Category     int      `json:"category"`
+	Name         string          `json:"name"`
+	DisplayName  string          `json:"displayName"` // Name with type parameters, e.g. "Repository[T any]"
+	Type         string          `json:"type"`
+	Package      string          `json:"package"`
+	Module       string          `json:"module,omitempty"`
+	Generated    string          `json:"generated,omitempty"` // Generator of its file, if any
+	FilePath     string          `json:"filePath"`
+	Line         int             `json:"line"`
+	Doc          string          `json:"doc"`
+	Methods      []MethodData    `json:"methods"`
+	SourceURL    string          `json:"sourceUrl,omitempty"`
+	Dependencies []string        `json:"dependencies"`
+	DependedBy   []string        `json:"dependedBy"`
+	ElementTypes []string        `json:"elementTypes"`
+	Edges        []analyzer.Edge `json:"edges"` // How each dependency is held, in the same order
+	Calls        []CallData      `json:"calls"`
+	Color        string          `json:"color"`
+	Category     int             `json:"category"`
+}
+
+// RouteData is an HTTP route, with its middleware and handler coloured by
+// component type.
+type RouteData struct {
+	Method     string
+	Path       string
+	Middleware []RouteStepData
+	Handler    RouteStepData
+	FilePath   string
+	Line       int
+	SourceURL  string
+}
+
+// RouteStepData is a middleware or handler in a route's pipeline.
+type RouteStepData struct {
+	analyzer.RouteStep
+	Color string // Of the component it belongs to, if known
+}
+
+// APIData is the API surface from OpenAPI specs, with the handler of each
+// operation and the routes no spec documents.
+type APIData struct {
+	Specs         []analyzer.APISpec
+	Operations    []APIOperationData
+	Undocumented  []RouteData
+	Unimplemented int
+}
+
+// APIOperationData is a spec operation with its handler coloured like a route step.
+type APIOperationData struct {
+	analyzer.APIOperation
+	HandlerStep RouteStepData
+	SpecURL     string
+}
+
+// ContractData is a contract from generated code, with the components
+// implementing and using it shown like route steps.
+type ContractData struct {
+	analyzer.Contract
+	SourceURL    string
+	Implementors []RouteStepData
+	Users        []RouteStepData
+}
+
+// CallData is a call to a dependency, with a link to where it is made.
+type CallData struct {
+	analyzer.Call
+	SourceURL string `json:"sourceUrl,omitempty"`
+}
+
+type MethodData struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	FilePath  string `json:"filePath"`
+	Line      int    `json:"line"`
+	SourceURL string `json:"sourceUrl,omitempty"`
 }
 
 type GraphData struct {
@@ -83,16 +183,24 @@ type GraphData struct {
 }
 
 type GraphNode struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Category int    `json:"category"`
-	Value    int    `json:"value"`
-	Package  string `json:"package"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Category  int    `json:"category"`
+	Value     int    `json:"value"`
+	Package   string `json:"package"`
+	Module    string `json:"module,omitempty"`
+	Generated string `json:"generated,omitempty"`
 }
 
 type GraphLink struct {
-	Source string `json:"source"`
-	Target string `json:"target"`
+	Source    string            `json:"source"`
+	Target    string            `json:"target"`
+	Kind      analyzer.EdgeKind `json:"kind"`                // Styles the link: direct, collection, callback or channel
+	Direction string            `json:"direction,omitempty"` // Channel direction, which decides the arrow
+	Via       string            `json:"via,omitempty"`
+	Methods   []string          `json:"methods,omitempty"` // Methods of the target the source calls
+
+	CrossModule bool `json:"crossModule,omitempty"` // Between components in different modules
 }
 
 type GraphCategory struct {
@@ -117,13 +225,41 @@ type LayerData struct {
 	Order      int      `json:"order"`
 }
 
+// MatrixData is a partitioned dependency structure matrix. Labels are ordered
+// group by group, and each cell [row, col] means labels[row] depends on
+// labels[col].
 type MatrixData struct {
-	Labels []string `json:"labels"`
-	Data   [][]int  `json:"data"`
+	GroupBy string        `json:"groupBy"`
+	Labels  []string      `json:"labels"`
+	Cyclic  []bool        `json:"cyclic"`
+	Groups  []MatrixGroup `json:"groups"`
+	Cells   [][2]int      `json:"cells"`
+}
+
+// MatrixGroup is a contiguous range of matrix rows and columns.
+type MatrixGroup struct {
+	Name  string `json:"name"`
+	Start int    `json:"start"`
+	Size  int    `json:"size"`
+}
+
+// DiagnosticData is an analysis diagnostic, with a link to its source.
+type DiagnosticData struct {
+	analyzer.Diagnostic
+	Label     string
+	SourceURL string
+}
+
+// DiagnosticCount is the number of diagnostics of one kind.
+type DiagnosticCount struct {
+	Kind  analyzer.DiagnosticKind
+	Label string
+	Count int
 }
 
 type PackageData struct {
 	Name       string   `json:"name"`
+	Module     string   `json:"module,omitempty"`
 	Components []string `json:"components"`
 	Children   []string `json:"children"`
 }
@@ -133,13 +269,7 @@ var categoryMap = map[analyzer.ComponentType]int{
 	analyzer.ComponentService:    1,
 	analyzer.ComponentRepository: 2,
 	analyzer.ComponentAdapter:    3,
-}
-
-var colorMap = map[analyzer.ComponentType]string{
-	analyzer.ComponentHandler:    "#4A90D9",
-	analyzer.ComponentService:    "#50C878",
-	analyzer.ComponentRepository: "#FFB347",
-	analyzer.ComponentAdapter:    "#9B59B6",
+	analyzer.ComponentMiddleware: 4,
 }
 
 var typeLabels = map[analyzer.ComponentType]string{
@@ -147,33 +277,68 @@ var typeLabels = map[analyzer.ComponentType]string{
 	analyzer.ComponentService:    "Service",
 	analyzer.ComponentRepository: "Repository",
 	analyzer.ComponentAdapter:    "Adapter",
+	analyzer.ComponentMiddleware: "Middleware",
+}
+
+var diagnosticLabels = map[analyzer.DiagnosticKind]string{
+	analyzer.DiagnosticParseError:           "Parse error",
+	analyzer.DiagnosticReadError:            "Read error",
+	analyzer.DiagnosticUnresolvedDependency: "Unresolved dependency",
+	analyzer.DiagnosticExcludedStruct:       "Excluded struct",
+	analyzer.DiagnosticSkippedDirectory:     "Skipped directory",
+	analyzer.DiagnosticExcludedFile:         "Excluded file",
 }
 
 var layerOrder = map[analyzer.ComponentType]int{
-	analyzer.ComponentHandler:    0,
-	analyzer.ComponentService:    1,
-	analyzer.ComponentAdapter:    2,
-	analyzer.ComponentRepository: 3,
+	analyzer.ComponentMiddleware: 0,
+	analyzer.ComponentHandler:    1,
+	analyzer.ComponentService:    2,
+	analyzer.ComponentAdapter:    3,
+	analyzer.ComponentRepository: 4,
 }
 
 // GenerateHTML creates an interactive HTML report from the architecture.
 func GenerateHTML(arch *analyzer.Architecture, outputPath string, config HTMLConfig) error {
+	html, err := RenderHTML(arch, config)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileBytes(outputPath, html); err != nil {
+		return fmt.Errorf("failed to write HTML file: %w", err)
+	}
+
+	return nil
+}
+
+// RenderHTML renders the interactive HTML report without writing it to disk.
+func RenderHTML(arch *analyzer.Architecture, config HTMLConfig) ([]byte, error) {
+	theme, err := LoadTheme(config.Theme)
+	if err != nil {
+		return nil, err
+	}
+
 	builder := &HTMLBuilder{
 		arch:   arch,
 		config: config,
+		theme:  theme,
 	}
 
 	// Build all data
 	builder.data = builder.buildReportData()
 
-	// Generate HTML
-	html := builder.render()
+	tmpl, err := parseTemplates(config.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := writeFileBytes(outputPath, []byte(html)); err != nil {
-		return fmt.Errorf("failed to write HTML file: %w", err)
+	// Generate HTML
+	html, err := builder.render(tmpl)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return []byte(html), nil
 }
 
 func (b *HTMLBuilder) buildReportData() *ReportData {
@@ -185,9 +350,108 @@ func (b *HTMLBuilder) buildReportData() *ReportData {
 	data.Graph = b.buildGraphData(data.Components)
 	data.Matrix = b.buildMatrixData(data.Components)
 	data.Packages = b.buildPackageData()
+	data.Modules = b.arch.Modules
+	if data.Modules == nil {
+		data.Modules = []analyzer.Module{}
+	}
+	data.Diagnostics, data.DiagnosticCounts = b.buildDiagnosticData()
+	data.Routes = b.buildRouteData()
+	data.Contracts = b.buildContractData()
+	data.API = b.buildAPIData()
 	return data
 }
 
+func (b *HTMLBuilder) buildAPIData() APIData {
+	api := b.arch.API
+	data := APIData{Specs: api.Specs, Unimplemented: len(api.Unimplemented)}
+	for _, op := range api.Operations {
+		data.Operations = append(data.Operations, APIOperationData{
+			APIOperation: op,
+			HandlerStep:  b.stepData(analyzer.RouteStep{Name: op.Handler, Component: op.Component}),
+			SpecURL:      b.sourceURL(op.SpecPath, op.Line),
+		})
+	}
+	for _, r := range api.Undocumented {
+		data.Undocumented = append(data.Undocumented, RouteData{
+			Method:    r.Method,
+			Path:      r.Path,
+			Handler:   b.stepData(r.Handler),
+			FilePath:  r.FilePath,
+			Line:      r.Line,
+			SourceURL: b.sourceURL(r.FilePath, r.Line),
+		})
+	}
+	return data
+}
+
+// stepData colours a route step by the type of its component, if known.
+func (b *HTMLBuilder) stepData(s analyzer.RouteStep) RouteStepData {
+	data := RouteStepData{RouteStep: s}
+	if comp := b.arch.Component(s.Component); comp != nil {
+		data.Color = b.theme.Color(comp.Type)
+	}
+	return data
+}
+
+func (b *HTMLBuilder) buildContractData() []ContractData {
+	steps := func(names []string) []RouteStepData {
+		var data []RouteStepData
+		for _, name := range names {
+			data = append(data, b.stepData(analyzer.RouteStep{Name: name, Component: name}))
+		}
+		return data
+	}
+	contracts := make([]ContractData, 0, len(b.arch.Contracts))
+	for _, c := range b.arch.Contracts {
+		contracts = append(contracts, ContractData{
+			Contract:     c,
+			SourceURL:    b.sourceURL(c.FilePath, 1),
+			Implementors: steps(c.ImplementedBy),
+			Users:        steps(c.UsedBy),
+		})
+	}
+	return contracts
+}
+
+func (b *HTMLBuilder) buildRouteData() []RouteData {
+	routes := make([]RouteData, 0, len(b.arch.Routes))
+	for _, r := range b.arch.Routes {
+		data := RouteData{
+			Method:    r.Method,
+			Path:      r.Path,
+			Handler:   b.stepData(r.Handler),
+			FilePath:  r.FilePath,
+			Line:      r.Line,
+			SourceURL: b.sourceURL(r.FilePath, r.Line),
+		}
+		for _, m := range r.Middleware {
+			data.Middleware = append(data.Middleware, b.stepData(m))
+		}
+		routes = append(routes, data)
+	}
+	return routes
+}
+
+func (b *HTMLBuilder) buildDiagnosticData() ([]DiagnosticData, []DiagnosticCount) {
+	diagnostics := make([]DiagnosticData, 0, len(b.arch.Diagnostics))
+	for _, d := range b.arch.Diagnostics {
+		data := DiagnosticData{Diagnostic: d, Label: diagnosticLabels[d.Kind]}
+		if d.Line > 0 {
+			data.SourceURL = b.sourceURL(d.FilePath, d.Line)
+		}
+		diagnostics = append(diagnostics, data)
+	}
+
+	var counts []DiagnosticCount
+	byKind := b.arch.DiagnosticCounts()
+	for _, kind := range analyzer.DiagnosticKinds {
+		if byKind[kind] > 0 {
+			counts = append(counts, DiagnosticCount{Kind: kind, Label: diagnosticLabels[kind], Count: byKind[kind]})
+		}
+	}
+	return diagnostics, counts
+}
+
 func (b *HTMLBuilder) buildComponentData() []ComponentData {
 	// Build reverse dependency map
 	dependedBy := make(map[string][]string)
@@ -199,14 +463,57 @@ func (b *HTMLBuilder) buildComponentData() []ComponentData {
 
 	components := make([]ComponentData, 0, len(b.arch.Components))
 	for _, comp := range b.arch.Components {
+		// Scripts expect arrays, never null
+		deps := comp.Dependencies
+		if deps == nil {
+			deps = []string{}
+		}
+		users := dependedBy[comp.Name]
+		if users == nil {
+			users = []string{}
+		}
+		elements := comp.ElementTypes
+		if elements == nil {
+			elements = []string{}
+		}
+		edges := make([]analyzer.Edge, 0, len(deps))
+		for _, dep := range deps {
+			edges = append(edges, comp.Edge(dep))
+		}
+		calls := make([]CallData, 0, len(comp.Calls))
+		for _, c := range comp.Calls {
+			calls = append(calls, CallData{Call: c, SourceURL: b.sourceURL(c.FilePath, c.Line)})
+		}
+
+		methods := make([]MethodData, 0, len(comp.Methods))
+		for _, m := range comp.Methods {
+			methods = append(methods, MethodData{
+				Name:      m.Name,
+				Signature: m.Signature,
+				FilePath:  m.FilePath,
+				Line:      m.Line,
+				SourceURL: b.sourceURL(m.FilePath, m.Line),
+			})
+		}
+
 		components = append(components, ComponentData{
 			Name:         comp.Name,
+			DisplayName:  comp.DisplayName(),
 			Type:         string(comp.Type),
 			Package:      comp.Package,
+			Module:       comp.Module,
+			Generated:    comp.Generated,
 			FilePath:     comp.FilePath,
-			Dependencies: comp.Dependencies,
-			DependedBy:   dependedBy[comp.Name],
-			Color:        colorMap[comp.Type],
+			Line:         comp.Line,
+			Doc:          comp.Doc,
+			Methods:      methods,
+			SourceURL:    b.sourceURL(comp.FilePath, comp.Line),
+			Dependencies: deps,
+			DependedBy:   users,
+			ElementTypes: elements,
+			Edges:        edges,
+			Calls:        calls,
+			Color:        b.theme.Color(comp.Type),
 			Category:     categoryMap[comp.Type],
 		})
 	}
@@ -257,6 +564,7 @@ func (b *HTMLBuilder) buildLayerData() []LayerData {
 
 	layers := []LayerData{}
 	types := []analyzer.ComponentType{
+		analyzer.ComponentMiddleware,
 		analyzer.ComponentHandler,
 		analyzer.ComponentService,
 		analyzer.ComponentAdapter,
@@ -267,7 +575,7 @@ func (b *HTMLBuilder) buildLayerData() []LayerData {
 		if comps, ok := layerMap[t]; ok && len(comps) > 0 {
 			layers = append(layers, LayerData{
 				Name:       typeLabels[t],
-				Color:      colorMap[t],
+				Color:      b.theme.Color(t),
 				Components: comps,
 				Order:      layerOrder[t],
 			})
@@ -282,26 +590,35 @@ func (b *HTMLBuilder) buildGraphData(components []ComponentData) GraphData {
 		Nodes: make([]GraphNode, 0, len(components)),
 		Links: make([]GraphLink, 0),
 		Categories: []GraphCategory{
-			{Name: "Handler", Color: "#4A90D9"},
-			{Name: "Service", Color: "#50C878"},
-			{Name: "Repository", Color: "#FFB347"},
-			{Name: "Adapter", Color: "#9B59B6"},
+			{Name: "Handler", Color: b.theme.Color(analyzer.ComponentHandler)},
+			{Name: "Service", Color: b.theme.Color(analyzer.ComponentService)},
+			{Name: "Repository", Color: b.theme.Color(analyzer.ComponentRepository)},
+			{Name: "Adapter", Color: b.theme.Color(analyzer.ComponentAdapter)},
+			{Name: "Middleware", Color: b.theme.Color(analyzer.ComponentMiddleware)},
 		},
 	}
 
 	for _, comp := range components {
 		data.Nodes = append(data.Nodes, GraphNode{
-			ID:       comp.Name,
-			Name:     comp.Name,
-			Category: comp.Category,
-			Value:    len(comp.Dependencies) + len(comp.DependedBy) + 1,
-			Package:  comp.Package,
+			ID:        comp.Name,
+			Name:      comp.DisplayName,
+			Category:  comp.Category,
+			Value:     len(comp.Dependencies) + len(comp.DependedBy) + 1,
+			Package:   comp.Package,
+			Module:    comp.Module,
+			Generated: comp.Generated,
 		})
 
-		for _, dep := range comp.Dependencies {
+		for _, edge := range comp.Edges {
 			data.Links = append(data.Links, GraphLink{
-				Source: comp.Name,
-				Target: dep,
+				Source:    comp.Name,
+				Target:    edge.Target,
+				Kind:      edge.Kind,
+				Direction: edge.Direction,
+				Via:       edge.Via,
+				Methods:   edge.Methods,
+
+				CrossModule: edge.CrossModule,
 			})
 		}
 	}
@@ -310,538 +627,148 @@ func (b *HTMLBuilder) buildGraphData(components []ComponentData) GraphData {
 }
 
 func (b *HTMLBuilder) buildMatrixData(components []ComponentData) MatrixData {
-	n := len(components)
-	labels := make([]string, n)
-	nameToIdx := make(map[string]int)
+	groupBy := b.config.MatrixGroupBy
+	if groupBy != MatrixGroupByLayer {
+		groupBy = MatrixGroupByPackage
+	}
 
-	for i, comp := range components {
-		labels[i] = comp.Name
-		nameToIdx[comp.Name] = i
+	// Assign components to groups
+	var names, groupNames []string
+	groupMembers := make(map[string][]string)
+	groupOf := make(map[string]string)
+	groupRank := make(map[string]int)
+	deps := make(map[string][]string)
+	for _, comp := range components {
+		group := comp.Package
+		if groupBy == MatrixGroupByLayer {
+			compType := analyzer.ComponentType(comp.Type)
+			group = typeLabels[compType]
+			groupRank[group] = layerOrder[compType]
+		} else if comp.FilePath != "" {
+			group = filepath.ToSlash(filepath.Dir(comp.FilePath))
+		}
+
+		if _, ok := groupMembers[group]; !ok {
+			groupNames = append(groupNames, group)
+		}
+		groupMembers[group] = append(groupMembers[group], comp.Name)
+		groupOf[comp.Name] = group
+		names = append(names, comp.Name)
+		deps[comp.Name] = comp.Dependencies
+	}
+	sort.Strings(names)
+	sort.Strings(groupNames)
+
+	// Order groups: layers top-down, packages by partitioning the package graph
+	if groupBy == MatrixGroupByLayer {
+		sort.SliceStable(groupNames, func(i, j int) bool { return groupRank[groupNames[i]] < groupRank[groupNames[j]] })
+	} else {
+		groupDeps := make(map[string][]string)
+		for _, name := range names {
+			for _, dep := range deps[name] {
+				if target, ok := groupOf[dep]; ok && target != groupOf[name] {
+					groupDeps[groupOf[name]] = append(groupDeps[groupOf[name]], target)
+				}
+			}
+		}
+		groupNames, _ = partitionDSM(groupNames, groupDeps)
 	}
 
-	// Initialize matrix
-	matrix := make([][]int, n)
-	for i := range matrix {
-		matrix[i] = make([]int, n)
+	// Order components within each group, and flag every component in a cycle
+	_, cyclic := partitionDSM(names, deps)
+	matrix := MatrixData{GroupBy: groupBy}
+	for _, group := range groupNames {
+		members := groupMembers[group]
+		sort.Strings(members)
+		ordered, _ := partitionDSM(members, deps)
+
+		matrix.Groups = append(matrix.Groups, MatrixGroup{
+			Name:  group,
+			Start: len(matrix.Labels),
+			Size:  len(ordered),
+		})
+		for _, name := range ordered {
+			matrix.Labels = append(matrix.Labels, name)
+			matrix.Cyclic = append(matrix.Cyclic, cyclic[name])
+		}
 	}
 
-	// Fill matrix
-	for i, comp := range components {
-		for _, dep := range comp.Dependencies {
+	// Record dependencies as sparse [row, col] cells
+	nameToIdx := make(map[string]int, len(matrix.Labels))
+	for i, name := range matrix.Labels {
+		nameToIdx[name] = i
+	}
+	matrix.Cells = make([][2]int, 0)
+	for i, name := range matrix.Labels {
+		for _, dep := range deps[name] {
 			if j, ok := nameToIdx[dep]; ok {
-				matrix[i][j] = 1
+				matrix.Cells = append(matrix.Cells, [2]int{i, j})
 			}
 		}
 	}
 
-	return MatrixData{
-		Labels: labels,
-		Data:   matrix,
-	}
+	return matrix
 }
 
 func (b *HTMLBuilder) buildPackageData() []PackageData {
-	pkgMap := make(map[string][]string)
+	// Packages of the same name in different modules are different packages
+	type key struct{ module, name string }
+	pkgMap := make(map[key][]string)
+	var keys []key
 	for _, comp := range b.arch.Components {
-		pkgMap[comp.Package] = append(pkgMap[comp.Package], comp.Name)
+		k := key{comp.Module, comp.Package}
+		if _, ok := pkgMap[k]; !ok {
+			keys = append(keys, k)
+		}
+		pkgMap[k] = append(pkgMap[k], comp.Name)
 	}
 
 	packages := make([]PackageData, 0, len(pkgMap))
-	for name, comps := range pkgMap {
+	for _, k := range keys {
 		packages = append(packages, PackageData{
-			Name:       name,
-			Components: comps,
+			Name:       k.name,
+			Module:     k.module,
+			Components: pkgMap[k],
 		})
 	}
 	return packages
 }
 
-func (b *HTMLBuilder) render() string {
-	var sb strings.Builder
-
-	// Write HTML head
-	sb.WriteString(b.renderHead())
+// reportView is the value every report template is executed with.
+type reportView struct {
+	Config HTMLConfig
+	Theme  Theme
+	Data   *ReportData
+	Build  string // The build configuration of the analysis, shown in the header
+}
 
-	// Write body open and container
-	sb.WriteString(`<body><div class="container">`)
+func (b *HTMLBuilder) render(tmpl *template.Template) (string, error) {
+	var sb strings.Builder
+	view := reportView{Config: b.config, Theme: b.theme, Data: b.data, Build: b.arch.Build.String()}
 
-	// Header
-	sb.WriteString(b.renderHeader())
+	// Page head, header and the requested widgets
+	names := []string{"head", "header"}
+	for _, widget := range b.config.Widgets {
+		names = append(names, "widget/"+string(widget))
+	}
+	names = append(names, "footer", "panel", "data")
 
-	// Render requested widgets
+	// Chart scripts, for widgets that have one
 	for _, widget := range b.config.Widgets {
-		sb.WriteString(b.renderWidget(widget))
-	}
-
-	// Footer
-	sb.WriteString(b.renderFooter())
-
-	// Close container and body
-	sb.WriteString(`</div>`)
-
-	// Write scripts
-	sb.WriteString(b.renderScripts())
-
-	sb.WriteString(`</body></html>`)
-
-	return sb.String()
-}
-
-func (b *HTMLBuilder) renderHead() string {
-	theme := b.getThemeCSS()
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>%s</title>
-    <script src="https://cdn.jsdelivr.net/npm/echarts@5.4.3/dist/echarts.min.js"></script>
-    <style>%s</style>
-</head>`, b.config.Title, theme)
-}
-
-func (b *HTMLBuilder) getThemeCSS() string {
-	if b.config.Theme == "light" {
-		return lightThemeCSS
-	}
-	return darkThemeCSS
-}
-
-func (b *HTMLBuilder) renderHeader() string {
-	return fmt.Sprintf(`
-<header>
-    <h1>%s</h1>
-    <p>%s</p>
-</header>`, b.config.Title, b.config.Description)
-}
-
-func (b *HTMLBuilder) renderFooter() string {
-	return `<footer><p>Generated by Sharingan - Go Architecture Analyzer</p></footer>`
-}
-
-func (b *HTMLBuilder) renderWidget(widget WidgetType) string {
-	switch widget {
-	case WidgetStatsCards:
-		return b.renderStatsCards()
-	case WidgetArchitectureGraph:
-		return b.renderArchitectureGraph()
-	case WidgetComponentsPie:
-		return b.renderComponentsPie()
-	case WidgetDependenciesBar:
-		return b.renderDependenciesBar()
-	case WidgetLayerFlow:
-		return b.renderLayerFlow()
-	case WidgetDependencyMatrix:
-		return b.renderDependencyMatrix()
-	case WidgetComponentsTable:
-		return b.renderComponentsTable()
-	case WidgetPackageTree:
-		return b.renderPackageTree()
-	default:
-		return ""
-	}
-}
-
-func (b *HTMLBuilder) renderStatsCards() string {
-	return fmt.Sprintf(`
-<div class="widget stats-grid">
-    <div class="stat-card">
-        <div class="number">%d</div>
-        <div class="label">Components</div>
-    </div>
-    <div class="stat-card">
-        <div class="number">%d</div>
-        <div class="label">Dependencies</div>
-    </div>
-    <div class="stat-card">
-        <div class="number">%d</div>
-        <div class="label">Packages</div>
-    </div>
-    <div class="stat-card">
-        <div class="number">%.1f</div>
-        <div class="label">Avg Deps</div>
-    </div>
-</div>`,
-		b.data.Stats.TotalComponents,
-		b.data.Stats.TotalDeps,
-		b.data.Stats.PackageCount,
-		b.data.Stats.AvgDependencies)
-}
-
-func (b *HTMLBuilder) renderArchitectureGraph() string {
-	return `
-<div class="widget chart-box">
-    <h3>Architecture Graph</h3>
-    <div id="architecture-graph" class="chart-large"></div>
-    <div class="legend">
-        <div class="legend-item"><div class="legend-color" style="background:#4A90D9"></div><span>Handler</span></div>
-        <div class="legend-item"><div class="legend-color" style="background:#50C878"></div><span>Service</span></div>
-        <div class="legend-item"><div class="legend-color" style="background:#FFB347"></div><span>Repository</span></div>
-        <div class="legend-item"><div class="legend-color" style="background:#9B59B6"></div><span>Adapter</span></div>
-    </div>
-</div>`
-}
-
-func (b *HTMLBuilder) renderComponentsPie() string {
-	return `
-<div class="widget chart-box half">
-    <h3>Components by Type</h3>
-    <div id="components-pie" class="chart"></div>
-</div>`
-}
-
-func (b *HTMLBuilder) renderDependenciesBar() string {
-	return `
-<div class="widget chart-box half">
-    <h3>Top Dependencies</h3>
-    <div id="dependencies-bar" class="chart"></div>
-</div>`
-}
-
-func (b *HTMLBuilder) renderLayerFlow() string {
-	return `
-<div class="widget chart-box">
-    <h3>Layer Flow</h3>
-    <div id="layer-flow" class="chart-large"></div>
-</div>`
-}
-
-func (b *HTMLBuilder) renderDependencyMatrix() string {
-	if len(b.data.Components) > 20 {
-		return "" // Skip for large architectures
-	}
-	return `
-<div class="widget chart-box">
-    <h3>Dependency Matrix</h3>
-    <div id="dependency-matrix" class="chart-large"></div>
-</div>`
-}
-
-func (b *HTMLBuilder) renderComponentsTable() string {
-	var rows strings.Builder
-	for _, comp := range b.data.Components {
-		deps := strings.Join(comp.Dependencies, ", ")
-		if deps == "" {
-			deps = "-"
+		if tmpl.Lookup("script/"+string(widget)) != nil {
+			names = append(names, "script/"+string(widget))
 		}
-		rows.WriteString(fmt.Sprintf(`
-        <tr>
-            <td><strong>%s</strong></td>
-            <td><span class="badge" style="background:%s22;color:%s">%s</span></td>
-            <td>%s</td>
-            <td>%d</td>
-            <td class="deps-cell">%s</td>
-        </tr>`,
-			comp.Name, comp.Color, comp.Color, comp.Type,
-			comp.Package, len(comp.Dependencies), deps))
-	}
-
-	return fmt.Sprintf(`
-<div class="widget table-box">
-    <h3>All Components</h3>
-    <table>
-        <thead>
-            <tr><th>Name</th><th>Type</th><th>Package</th><th>Deps</th><th>Dependencies</th></tr>
-        </thead>
-        <tbody>%s</tbody>
-    </table>
-</div>`, rows.String())
-}
-
-func (b *HTMLBuilder) renderPackageTree() string {
-	return `
-<div class="widget chart-box">
-    <h3>Package Structure</h3>
-    <div id="package-tree" class="chart-large"></div>
-</div>`
-}
-
-func (b *HTMLBuilder) renderScripts() string {
-	dataJSON, _ := json.Marshal(b.data)
-
-	// Determine which charts to initialize based on widgets
-	var chartInits strings.Builder
+	}
+	names = append(names, "end")
 
-	for _, widget := range b.config.Widgets {
-		switch widget {
-		case WidgetArchitectureGraph:
-			chartInits.WriteString(architectureGraphScript)
-		case WidgetComponentsPie:
-			chartInits.WriteString(componentsPieScript)
-		case WidgetDependenciesBar:
-			chartInits.WriteString(dependenciesBarScript)
-		case WidgetLayerFlow:
-			chartInits.WriteString(layerFlowScript)
-		case WidgetDependencyMatrix:
-			if len(b.data.Components) <= 20 {
-				chartInits.WriteString(dependencyMatrixScript)
-			}
-		case WidgetPackageTree:
-			chartInits.WriteString(packageTreeScript)
+	for _, name := range names {
+		if tmpl.Lookup(name) == nil {
+			continue // Unknown widget
+		}
+		if err := tmpl.ExecuteTemplate(&sb, name, view); err != nil {
+			return "", fmt.Errorf("failed to render %s: %w", name, err)
 		}
 	}
 
-	return fmt.Sprintf(`
-<script>
-const data = %s;
-const charts = [];
-
-%s
-
-window.addEventListener('resize', () => charts.forEach(c => c.resize()));
-</script>`, string(dataJSON), chartInits.String())
-}
-
-// Chart initialization scripts
-const architectureGraphScript = `
-(function() {
-    const el = document.getElementById('architecture-graph');
-    if (!el) return;
-    const chart = echarts.init(el);
-    charts.push(chart);
-    chart.setOption({
-        tooltip: {
-            trigger: 'item',
-            formatter: p => p.dataType === 'node'
-                ? '<strong>' + p.data.name + '</strong><br/>Package: ' + p.data.package
-                : p.data.source + ' → ' + p.data.target
-        },
-        series: [{
-            type: 'graph',
-            layout: 'force',
-            roam: true,
-            draggable: true,
-            data: data.graph.nodes.map(n => ({
-                ...n,
-                symbolSize: Math.max(35, n.value * 12),
-                itemStyle: { color: data.graph.categories[n.category].color },
-                label: { show: true, position: 'bottom', formatter: n.name, fontSize: 11, color: '#aaa' }
-            })),
-            links: data.graph.links.map(l => ({
-                ...l,
-                lineStyle: { color: '#555', width: 2, curveness: 0.2 }
-            })),
-            categories: data.graph.categories,
-            force: { repulsion: 400, gravity: 0.1, edgeLength: [80, 180] },
-            emphasis: { focus: 'adjacency', lineStyle: { width: 4 } }
-        }]
-    });
-})();
-`
-
-const componentsPieScript = `
-(function() {
-    const el = document.getElementById('components-pie');
-    if (!el) return;
-    const chart = echarts.init(el);
-    charts.push(chart);
-    const colors = { Handler: '#4A90D9', Service: '#50C878', Repository: '#FFB347', Adapter: '#9B59B6' };
-    chart.setOption({
-        tooltip: { trigger: 'item', formatter: '{b}: {c} ({d}%)' },
-        series: [{
-            type: 'pie',
-            radius: ['40%', '70%'],
-            itemStyle: { borderRadius: 8, borderColor: '#1a1a2e', borderWidth: 2 },
-            label: { color: '#aaa' },
-            data: Object.entries(data.stats.componentsByType).map(([name, value]) => ({
-                name, value, itemStyle: { color: colors[name] }
-            }))
-        }]
-    });
-})();
-`
-
-const dependenciesBarScript = `
-(function() {
-    const el = document.getElementById('dependencies-bar');
-    if (!el) return;
-    const chart = echarts.init(el);
-    charts.push(chart);
-    const sorted = [...data.components].sort((a, b) => b.dependencies.length - a.dependencies.length).slice(0, 10);
-    chart.setOption({
-        tooltip: { trigger: 'axis' },
-        grid: { left: '3%', right: '4%', bottom: '3%', containLabel: true },
-        xAxis: { type: 'value', axisLine: { lineStyle: { color: '#555' } }, axisLabel: { color: '#888' }, splitLine: { lineStyle: { color: '#333' } } },
-        yAxis: { type: 'category', data: sorted.map(c => c.name), axisLine: { lineStyle: { color: '#555' } }, axisLabel: { color: '#888' } },
-        series: [{ type: 'bar', data: sorted.map(c => ({ value: c.dependencies.length, itemStyle: { color: c.color } })), barWidth: '60%', itemStyle: { borderRadius: [0, 4, 4, 0] } }]
-    });
-})();
-`
-
-const layerFlowScript = `
-(function() {
-    const el = document.getElementById('layer-flow');
-    if (!el) return;
-    const chart = echarts.init(el);
-    charts.push(chart);
-
-    const nodes = [];
-    const links = [];
-
-    data.layers.forEach(layer => {
-        layer.components.forEach(comp => {
-            nodes.push({ name: comp });
-        });
-    });
-
-    data.components.forEach(comp => {
-        comp.dependencies.forEach(dep => {
-            links.push({ source: comp.name, target: dep, value: 1 });
-        });
-    });
-
-    chart.setOption({
-        tooltip: { trigger: 'item' },
-        series: [{
-            type: 'sankey',
-            layout: 'none',
-            emphasis: { focus: 'adjacency' },
-            data: nodes,
-            links: links,
-            lineStyle: { color: 'gradient', curveness: 0.5 },
-            itemStyle: { borderWidth: 1, borderColor: '#aaa' },
-            label: { color: '#ccc' }
-        }]
-    });
-})();
-`
-
-const dependencyMatrixScript = `
-(function() {
-    const el = document.getElementById('dependency-matrix');
-    if (!el) return;
-    const chart = echarts.init(el);
-    charts.push(chart);
-
-    const matrixData = [];
-    data.matrix.data.forEach((row, i) => {
-        row.forEach((val, j) => {
-            matrixData.push([j, i, val]);
-        });
-    });
-
-    chart.setOption({
-        tooltip: {
-            formatter: p => p.data[2] ? data.matrix.labels[p.data[1]] + ' → ' + data.matrix.labels[p.data[0]] : ''
-        },
-        grid: { top: '10%', left: '15%', right: '5%', bottom: '15%' },
-        xAxis: { type: 'category', data: data.matrix.labels, axisLabel: { rotate: 45, color: '#888', fontSize: 10 }, axisLine: { lineStyle: { color: '#555' } } },
-        yAxis: { type: 'category', data: data.matrix.labels, axisLabel: { color: '#888', fontSize: 10 }, axisLine: { lineStyle: { color: '#555' } } },
-        visualMap: { show: false, min: 0, max: 1, inRange: { color: ['#1a1a2e', '#50C878'] } },
-        series: [{ type: 'heatmap', data: matrixData, itemStyle: { borderColor: '#333', borderWidth: 1 } }]
-    });
-})();
-`
-
-const packageTreeScript = `
-(function() {
-    const el = document.getElementById('package-tree');
-    if (!el) return;
-    const chart = echarts.init(el);
-    charts.push(chart);
-
-    const treeData = {
-        name: 'packages',
-        children: data.packages.map(pkg => ({
-            name: pkg.name,
-            children: pkg.components.map(c => ({ name: c }))
-        }))
-    };
-
-    chart.setOption({
-        tooltip: { trigger: 'item' },
-        series: [{
-            type: 'tree',
-            data: [treeData],
-            top: '10%', left: '10%', bottom: '10%', right: '10%',
-            symbol: 'circle',
-            symbolSize: 10,
-            orient: 'TB',
-            label: { position: 'bottom', rotate: 0, fontSize: 11, color: '#aaa' },
-            leaves: { label: { position: 'bottom' } },
-            expandAndCollapse: true,
-            animationDuration: 500,
-            lineStyle: { color: '#555', width: 1.5, curveness: 0.5 }
-        }]
-    });
-})();
-`
-
-// Theme CSS
-const darkThemeCSS = `
-* { margin: 0; padding: 0; box-sizing: border-box; }
-body {
-    font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
-    background: linear-gradient(135deg, #1a1a2e 0%, #16213e 100%);
-    min-height: 100vh;
-    color: #e4e4e4;
-}
-.container { max-width: 1600px; margin: 0 auto; padding: 20px; }
-header { text-align: center; padding: 30px 0; border-bottom: 1px solid #333; margin-bottom: 30px; }
-header h1 { font-size: 2.5rem; background: linear-gradient(90deg, #4A90D9, #50C878); -webkit-background-clip: text; -webkit-text-fill-color: transparent; margin-bottom: 10px; }
-header p { color: #888; font-size: 1.1rem; }
-.widget { margin-bottom: 25px; }
-.stats-grid { display: grid; grid-template-columns: repeat(auto-fit, minmax(180px, 1fr)); gap: 20px; }
-.stat-card { background: rgba(255,255,255,0.05); border-radius: 12px; padding: 20px; text-align: center; border: 1px solid rgba(255,255,255,0.1); transition: transform 0.2s; }
-.stat-card:hover { transform: translateY(-5px); }
-.stat-card .number { font-size: 2.5rem; font-weight: bold; background: linear-gradient(90deg, #4A90D9, #50C878); -webkit-background-clip: text; -webkit-text-fill-color: transparent; }
-.stat-card .label { color: #888; margin-top: 5px; }
-.chart-box { background: rgba(255,255,255,0.05); border-radius: 12px; padding: 20px; border: 1px solid rgba(255,255,255,0.1); }
-.chart-box.half { display: inline-block; width: calc(50% - 12px); vertical-align: top; }
-.chart-box.half:nth-of-type(odd) { margin-right: 20px; }
-@media (max-width: 900px) { .chart-box.half { width: 100%; margin-right: 0; } }
-.chart-box h3 { margin-bottom: 15px; color: #fff; font-size: 1.2rem; }
-.chart { width: 100%; height: 350px; }
-.chart-large { width: 100%; height: 500px; }
-.legend { display: flex; justify-content: center; gap: 25px; margin-top: 15px; flex-wrap: wrap; }
-.legend-item { display: flex; align-items: center; gap: 8px; }
-.legend-color { width: 14px; height: 14px; border-radius: 3px; }
-.table-box { background: rgba(255,255,255,0.05); border-radius: 12px; padding: 20px; border: 1px solid rgba(255,255,255,0.1); overflow-x: auto; }
-.table-box h3 { margin-bottom: 15px; color: #fff; font-size: 1.2rem; }
-table { width: 100%; border-collapse: collapse; }
-th, td { padding: 12px 15px; text-align: left; border-bottom: 1px solid rgba(255,255,255,0.1); }
-th { background: rgba(255,255,255,0.05); font-weight: 600; }
-tr:hover { background: rgba(255,255,255,0.03); }
-.badge { display: inline-block; padding: 4px 12px; border-radius: 20px; font-size: 0.85rem; font-weight: 500; }
-.deps-cell { font-size: 0.85rem; color: #888; max-width: 300px; }
-footer { text-align: center; padding: 30px 0; color: #666; border-top: 1px solid #333; margin-top: 30px; }
-`
-
-const lightThemeCSS = `
-* { margin: 0; padding: 0; box-sizing: border-box; }
-body {
-    font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
-    background: linear-gradient(135deg, #f5f7fa 0%, #e4e8ec 100%);
-    min-height: 100vh;
-    color: #333;
-}
-.container { max-width: 1600px; margin: 0 auto; padding: 20px; }
-header { text-align: center; padding: 30px 0; border-bottom: 1px solid #ddd; margin-bottom: 30px; }
-header h1 { font-size: 2.5rem; background: linear-gradient(90deg, #4A90D9, #50C878); -webkit-background-clip: text; -webkit-text-fill-color: transparent; margin-bottom: 10px; }
-header p { color: #666; font-size: 1.1rem; }
-.widget { margin-bottom: 25px; }
-.stats-grid { display: grid; grid-template-columns: repeat(auto-fit, minmax(180px, 1fr)); gap: 20px; }
-.stat-card { background: #fff; border-radius: 12px; padding: 20px; text-align: center; border: 1px solid #e0e0e0; box-shadow: 0 2px 8px rgba(0,0,0,0.05); transition: transform 0.2s; }
-.stat-card:hover { transform: translateY(-5px); box-shadow: 0 8px 20px rgba(0,0,0,0.1); }
-.stat-card .number { font-size: 2.5rem; font-weight: bold; background: linear-gradient(90deg, #4A90D9, #50C878); -webkit-background-clip: text; -webkit-text-fill-color: transparent; }
-.stat-card .label { color: #666; margin-top: 5px; }
-.chart-box { background: #fff; border-radius: 12px; padding: 20px; border: 1px solid #e0e0e0; box-shadow: 0 2px 8px rgba(0,0,0,0.05); }
-.chart-box.half { display: inline-block; width: calc(50% - 12px); vertical-align: top; }
-.chart-box.half:nth-of-type(odd) { margin-right: 20px; }
-@media (max-width: 900px) { .chart-box.half { width: 100%; margin-right: 0; } }
-.chart-box h3 { margin-bottom: 15px; color: #333; font-size: 1.2rem; }
-.chart { width: 100%; height: 350px; }
-.chart-large { width: 100%; height: 500px; }
-.legend { display: flex; justify-content: center; gap: 25px; margin-top: 15px; flex-wrap: wrap; }
-.legend-item { display: flex; align-items: center; gap: 8px; }
-.legend-color { width: 14px; height: 14px; border-radius: 3px; }
-.table-box { background: #fff; border-radius: 12px; padding: 20px; border: 1px solid #e0e0e0; box-shadow: 0 2px 8px rgba(0,0,0,0.05); overflow-x: auto; }
-.table-box h3 { margin-bottom: 15px; color: #333; font-size: 1.2rem; }
-table { width: 100%; border-collapse: collapse; }
-th, td { padding: 12px 15px; text-align: left; border-bottom: 1px solid #eee; }
-th { background: #f9f9f9; font-weight: 600; }
-tr:hover { background: #f5f5f5; }
-.badge { display: inline-block; padding: 4px 12px; border-radius: 20px; font-size: 0.85rem; font-weight: 500; }
-.deps-cell { font-size: 0.85rem; color: #666; max-width: 300px; }
-footer { text-align: center; padding: 30px 0; color: #999; border-top: 1px solid #ddd; margin-top: 30px; }
-`
+	return sb.String(), nil
+}
@@ -0,0 +1,76 @@
+package diagram
+
+import (
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sourceURL builds a repository browser link for a file and line, or returns
+// "" when no usable URL template is available.
+func (b *HTMLBuilder) sourceURL(filePath string, line int) string {
+	tmpl := b.config.SourceURLTemplate
+	if tmpl == "" {
+		tmpl = remoteURLTemplate(b.arch.Repository.RemoteURL)
+	}
+	if tmpl == "" || filePath == "" {
+		return ""
+	}
+
+	// Only link to web pages, never javascript: or data: URLs
+	lower := strings.ToLower(tmpl)
+	if !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "http://") {
+		return ""
+	}
+
+	commit := b.arch.Repository.Commit
+	if commit == "" {
+		commit = "HEAD"
+	}
+	filePath = filepath.ToSlash(filePath)
+	if b.arch.Repository.Subdir != "" {
+		filePath = path.Join(b.arch.Repository.Subdir, filePath)
+	}
+
+	return strings.NewReplacer(
+		"{commit}", commit,
+		"{path}", filePath,
+		"{line}", strconv.Itoa(line),
+	).Replace(tmpl)
+}
+
+// remoteURLTemplate derives a source URL template from a git remote URL on a
+// well-known host.
+func remoteURLTemplate(remote string) string {
+	if remote == "" {
+		return ""
+	}
+
+	// Normalise git@host:owner/repo.git and ssh://git@host/owner/repo.git
+	url := strings.TrimSuffix(remote, ".git")
+	if rest, ok := strings.CutPrefix(url, "git@"); ok {
+		url = "https://" + strings.Replace(rest, ":", "/", 1)
+	} else if rest, ok := strings.CutPrefix(url, "ssh://git@"); ok {
+		url = "https://" + rest
+	}
+	if !strings.HasPrefix(url, "https://") {
+		return ""
+	}
+
+	host := strings.TrimPrefix(url, "https://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	switch host {
+	case "github.com":
+		return url + "/blob/{commit}/{path}#L{line}"
+	case "gitlab.com":
+		return url + "/-/blob/{commit}/{path}#L{line}"
+	case "bitbucket.org":
+		return url + "/src/{commit}/{path}#lines-{line}"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,149 @@
+package diagram
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+)
+
+// Colours of the C4 model's containers and external systems.
+const (
+	c4ServiceColor  = "#438DD5"
+	c4ExternalColor = "#999999"
+)
+
+// SystemData is everything the system topology page shows.
+type SystemData struct {
+	Nodes         []SystemNode      `json:"nodes"`
+	Links         []SystemGraphLink `json:"links"`
+	ServiceColor  string            `json:"-"`
+	ExternalColor string            `json:"-"`
+	System        *analyzer.System  `json:"-"` // Rendered into the tables, not needed by scripts
+}
+
+// SystemNode is a service or external system in the topology graph.
+type SystemNode struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Technology  string `json:"technology"`  // The C4 technology label, e.g. "Container: Go"
+	Description string `json:"description"` // Lines separated by newlines
+	Color       string `json:"color"`
+}
+
+// SystemGraphLink is a connection in the topology graph.
+type SystemGraphLink struct {
+	Source string                `json:"source"`
+	Target string                `json:"target"`
+	Kind   analyzer.EndpointKind `json:"kind"`
+	Label  string                `json:"label"`
+	Via    []string              `json:"via"`
+}
+
+// systemView is the value the system page templates are executed with.
+type systemView struct {
+	Config HTMLConfig
+	Theme  Theme
+	Data   *SystemData
+	Build  string
+}
+
+// protocolLabels name link kinds the way C4 relationships describe them.
+var protocolLabels = map[analyzer.EndpointKind]string{
+	analyzer.EndpointHTTP:  "HTTP",
+	analyzer.EndpointGRPC:  "gRPC",
+	analyzer.EndpointTopic: "Messages",
+}
+
+func buildSystemData(sys *analyzer.System) *SystemData {
+	data := &SystemData{
+		Nodes:         []SystemNode{},
+		Links:         []SystemGraphLink{},
+		ServiceColor:  c4ServiceColor,
+		ExternalColor: c4ExternalColor,
+		System:        sys,
+	}
+	for _, svc := range sys.Services {
+		description := fmt.Sprintf("%d components, %d routes", svc.Components, svc.Routes)
+		if svc.Module != "" {
+			description = svc.Module + "\n" + description
+		}
+		data.Nodes = append(data.Nodes, SystemNode{ID: svc.Name, Name: svc.Name, Technology: "Container: Go", Description: description, Color: c4ServiceColor})
+	}
+	for _, ext := range sys.External {
+		data.Nodes = append(data.Nodes, SystemNode{ID: ext.Name, Name: ext.Name, Technology: "External system", Description: strings.Join(ext.URLs, "\n"), Color: c4ExternalColor})
+	}
+	for _, l := range sys.Links {
+		link := SystemGraphLink{Source: l.From, Target: l.To, Kind: l.Kind, Label: protocolLabels[l.Kind]}
+		for _, via := range l.Via {
+			link.Via = append(link.Via, via.Name)
+		}
+		data.Links = append(data.Links, link)
+	}
+	return data
+}
+
+// RenderSystemHTML renders the system topology page: a C4 container diagram
+// with each service as a node, and a table of the connections behind it.
+// Title, description, theme and template overrides come from config.
+func RenderSystemHTML(sys *analyzer.System, config HTMLConfig) ([]byte, error) {
+	theme, err := LoadTheme(config.Theme)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := parseTemplates(config.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	view := systemView{Config: config, Theme: theme, Data: buildSystemData(sys), Build: sys.Build.String()}
+	for _, name := range []string{"head", "header", "system/diagram", "system/links", "footer", "data", "system/script", "end"} {
+		if err := tmpl.ExecuteTemplate(&sb, name, view); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", name, err)
+		}
+	}
+	return []byte(sb.String()), nil
+}
+
+// GenerateSystemHTML writes the system topology page to outputPath.
+func GenerateSystemHTML(sys *analyzer.System, outputPath string, config HTMLConfig) error {
+	html, err := RenderSystemHTML(sys, config)
+	if err != nil {
+		return err
+	}
+	if err := writeFileBytes(outputPath, html); err != nil {
+		return fmt.Errorf("failed to write HTML file: %w", err)
+	}
+	return nil
+}
+
+var mermaidID = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// SystemMermaid returns the system as a Mermaid C4Container diagram, for
+// embedding in Markdown.
+func SystemMermaid(sys *analyzer.System, title string) string {
+	id := func(name string) string { return mermaidID.ReplaceAllString(name, "_") }
+	quote := func(s string) string { return strings.ReplaceAll(s, `"`, `'`) }
+
+	var sb strings.Builder
+	sb.WriteString("C4Container\n")
+	if title != "" {
+		fmt.Fprintf(&sb, "    title %s\n", title)
+	}
+	for _, svc := range sys.Services {
+		fmt.Fprintf(&sb, "    Container(%s, \"%s\", \"Go\", \"%s\")\n", id(svc.Name), quote(svc.Name), quote(svc.Module))
+	}
+	for _, ext := range sys.External {
+		fmt.Fprintf(&sb, "    System_Ext(%s, \"%s\", \"External HTTP API\")\n", id(ext.Name), quote(ext.Name))
+	}
+	for _, l := range sys.Links {
+		var via []string
+		for _, v := range l.Via {
+			via = append(via, v.Name)
+		}
+		fmt.Fprintf(&sb, "    Rel(%s, %s, \"%s\", \"%s\")\n", id(l.From), id(l.To), quote(strings.Join(via, ", ")), protocolLabels[l.Kind])
+	}
+	return sb.String()
+}
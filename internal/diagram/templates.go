@@ -0,0 +1,48 @@
+package diagram
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"path/filepath"
+)
+
+// The report is rendered from named html/template definitions:
+//   - "head", "header", "footer", "panel", "data" and "end" lay out the page
+//   - "theme" holds the stylesheet, which takes its colours and fonts from the
+//     CSS variables of the active Theme
+//   - "widget/<type>" holds the markup for a WidgetType
+//   - "script/<type>" holds the chart script for a WidgetType, if any
+//   - "system/diagram", "system/links" and "system/script" make up the system page
+//
+// Any of these can be replaced by defining a template with the same name in a
+// *.tmpl file inside HTMLConfig.TemplateDir. Themes themselves are not
+// templates: they are chosen by name or given as JSON, see LoadTheme.
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+func parseTemplates(overrideDir string) (*template.Template, error) {
+	tmpl, err := template.New("report").ParseFS(templateFS, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse built-in templates: %w", err)
+	}
+
+	if overrideDir == "" {
+		return tmpl, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(overrideDir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates in %s: %w", overrideDir, err)
+	}
+	if len(matches) == 0 {
+		return tmpl, nil
+	}
+
+	tmpl, err = tmpl.ParseFiles(matches...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates in %s: %w", overrideDir, err)
+	}
+	return tmpl, nil
+}
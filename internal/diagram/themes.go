@@ -0,0 +1,336 @@
+package diagram
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+)
+
+// Theme defines the look of a report: the CSS variables used by the stylesheet,
+// the colour of each component type, fonts and an optional ECharts theme.
+//
+// Custom themes are JSON documents with the same shape. Anything a custom theme
+// leaves out is taken from its Base theme, which defaults to "dark".
+type Theme struct {
+	Name      string                            `json:"name"`
+	Base      string                            `json:"base,omitempty"`
+	Variables map[string]string                 `json:"variables,omitempty"` // CSS custom properties, e.g. "--bg"
+	Palette   map[analyzer.ComponentType]string `json:"palette,omitempty"`   // #rrggbb colour per component type
+	Fonts     ThemeFonts                        `json:"fonts,omitempty"`
+	ECharts   map[string]any                    `json:"echarts,omitempty"` // Passed to echarts.registerTheme
+}
+
+// ThemeFonts holds the font stacks used by a theme.
+type ThemeFonts struct {
+	Body string `json:"body,omitempty"`
+	Mono string `json:"mono,omitempty"`
+}
+
+const defaultThemeName = "dark"
+
+const (
+	systemFont = "-apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif"
+	monoFont   = "SFMono-Regular, Menlo, Consolas, monospace"
+)
+
+var darkVariables = map[string]string{
+	"--bg":               "linear-gradient(135deg, #1a1a2e 0%, #16213e 100%)",
+	"--text":             "#e4e4e4",
+	"--text-muted":       "#888",
+	"--text-faint":       "#666",
+	"--heading":          "#fff",
+	"--border":           "#333",
+	"--surface":          "rgba(255,255,255,0.05)",
+	"--surface-border":   "rgba(255,255,255,0.1)",
+	"--shadow":           "none",
+	"--shadow-hover":     "none",
+	"--table-head":       "rgba(255,255,255,0.05)",
+	"--row-border":       "rgba(255,255,255,0.1)",
+	"--row-hover":        "rgba(255,255,255,0.03)",
+	"--accent-gradient":  "linear-gradient(90deg, #4A90D9, #50C878)",
+	"--chart-label":      "#aaa",
+	"--chart-axis-label": "#888",
+	"--chart-line":       "#555",
+	"--chart-grid":       "#333",
+	"--chart-surface":    "#1a1a2e",
+	"--chart-accent":     "#50C878",
+	"--chart-cross":      "#FF6B6B",
+	"--panel-bg":         "#1a1a2e",
+	"--link":             "#4A90D9",
+}
+
+var lightVariables = map[string]string{
+	"--bg":               "linear-gradient(135deg, #f5f7fa 0%, #e4e8ec 100%)",
+	"--text":             "#333",
+	"--text-muted":       "#666",
+	"--text-faint":       "#999",
+	"--heading":          "#333",
+	"--border":           "#ddd",
+	"--surface":          "#fff",
+	"--surface-border":   "#e0e0e0",
+	"--shadow":           "0 2px 8px rgba(0,0,0,0.05)",
+	"--shadow-hover":     "0 8px 20px rgba(0,0,0,0.1)",
+	"--table-head":       "#f9f9f9",
+	"--row-border":       "#eee",
+	"--row-hover":        "#f5f5f5",
+	"--accent-gradient":  "linear-gradient(90deg, #4A90D9, #50C878)",
+	"--chart-label":      "#555",
+	"--chart-axis-label": "#666",
+	"--chart-line":       "#bbb",
+	"--chart-grid":       "#eee",
+	"--chart-surface":    "#fff",
+	"--chart-accent":     "#50C878",
+	"--chart-cross":      "#DC2626",
+	"--panel-bg":         "#fff",
+	"--link":             "#2563EB",
+}
+
+var highContrastVariables = map[string]string{
+	"--bg":               "#000",
+	"--text":             "#fff",
+	"--text-muted":       "#e0e0e0",
+	"--text-faint":       "#ccc",
+	"--heading":          "#fff",
+	"--border":           "#fff",
+	"--surface":          "#000",
+	"--surface-border":   "#fff",
+	"--shadow":           "none",
+	"--shadow-hover":     "0 0 0 2px #ffd700",
+	"--table-head":       "#1a1a1a",
+	"--row-border":       "#fff",
+	"--row-hover":        "#1a1a1a",
+	"--accent-gradient":  "linear-gradient(90deg, #ffd700, #00e5ff)",
+	"--chart-label":      "#fff",
+	"--chart-axis-label": "#fff",
+	"--chart-line":       "#fff",
+	"--chart-grid":       "#666",
+	"--chart-surface":    "#000",
+	"--chart-accent":     "#ffd700",
+	"--chart-cross":      "#ff3b3b",
+	"--panel-bg":         "#000",
+	"--link":             "#00e5ff",
+}
+
+// builtinThemes are the themes selectable by name.
+var builtinThemes = map[string]Theme{
+	"dark": {
+		Name:      "dark",
+		Variables: darkVariables,
+		Palette: map[analyzer.ComponentType]string{
+			analyzer.ComponentHandler:    "#4A90D9",
+			analyzer.ComponentService:    "#50C878",
+			analyzer.ComponentRepository: "#FFB347",
+			analyzer.ComponentAdapter:    "#9B59B6",
+			analyzer.ComponentMiddleware: "#1ABC9C",
+		},
+		Fonts: ThemeFonts{Body: systemFont, Mono: monoFont},
+	},
+	"light": {
+		Name:      "light",
+		Variables: lightVariables,
+		Palette: map[analyzer.ComponentType]string{
+			analyzer.ComponentHandler:    "#4A90D9",
+			analyzer.ComponentService:    "#50C878",
+			analyzer.ComponentRepository: "#FFB347",
+			analyzer.ComponentAdapter:    "#9B59B6",
+			analyzer.ComponentMiddleware: "#1ABC9C",
+		},
+		Fonts: ThemeFonts{Body: systemFont, Mono: monoFont},
+	},
+	"high-contrast": {
+		Name:      "high-contrast",
+		Variables: highContrastVariables,
+		Palette: map[analyzer.ComponentType]string{
+			analyzer.ComponentHandler:    "#00E5FF",
+			analyzer.ComponentService:    "#FFD700",
+			analyzer.ComponentRepository: "#FF6EC7",
+			analyzer.ComponentAdapter:    "#7CFC00",
+			analyzer.ComponentMiddleware: "#FF8C00",
+		},
+		Fonts: ThemeFonts{Body: systemFont, Mono: monoFont},
+	},
+	// Okabe-Ito palette, distinguishable with the common forms of colour blindness.
+	"colorblind": {
+		Name:      "colorblind",
+		Variables: mergeVariables(lightVariables, map[string]string{"--accent-gradient": "linear-gradient(90deg, #0072B2, #009E73)", "--chart-accent": "#0072B2", "--chart-cross": "#D55E00"}),
+		Palette: map[analyzer.ComponentType]string{
+			analyzer.ComponentHandler:    "#0072B2",
+			analyzer.ComponentService:    "#009E73",
+			analyzer.ComponentRepository: "#E69F00",
+			analyzer.ComponentAdapter:    "#CC79A7",
+			analyzer.ComponentMiddleware: "#56B4E9",
+		},
+		Fonts: ThemeFonts{Body: systemFont, Mono: monoFont},
+	},
+}
+
+// ThemeNames returns the names of the built-in themes.
+func ThemeNames() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadTheme resolves a theme from a built-in theme name, the path to a JSON
+// theme file or an inline JSON theme. An empty spec selects the dark theme.
+func LoadTheme(spec string) (Theme, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = defaultThemeName
+	}
+
+	if theme, ok := builtinThemes[spec]; ok {
+		return theme, nil
+	}
+
+	var raw []byte
+	var fallbackName string
+	if strings.HasPrefix(spec, "{") {
+		raw = []byte(spec)
+		fallbackName = "custom"
+	} else {
+		content, err := os.ReadFile(spec)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return Theme{}, fmt.Errorf("unknown theme %q: not a built-in theme (%s) or a theme file", spec, strings.Join(ThemeNames(), ", "))
+			}
+			return Theme{}, fmt.Errorf("failed to read theme file: %w", err)
+		}
+		raw = content
+		fallbackName = strings.TrimSuffix(filepath.Base(spec), filepath.Ext(spec))
+	}
+
+	var custom Theme
+	if err := json.Unmarshal(raw, &custom); err != nil {
+		return Theme{}, fmt.Errorf("failed to parse theme: %w", err)
+	}
+	if custom.Name == "" {
+		custom.Name = fallbackName
+	}
+
+	theme, err := custom.withBase()
+	if err != nil {
+		return Theme{}, err
+	}
+	if err := theme.validate(); err != nil {
+		return Theme{}, fmt.Errorf("invalid theme %q: %w", theme.Name, err)
+	}
+	return theme, nil
+}
+
+// withBase fills everything the theme leaves unset from its base theme.
+func (t Theme) withBase() (Theme, error) {
+	baseName := t.Base
+	if baseName == "" {
+		baseName = defaultThemeName
+	}
+	base, ok := builtinThemes[baseName]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown base theme %q", baseName)
+	}
+
+	merged := Theme{
+		Name:      t.Name,
+		Base:      baseName,
+		Variables: mergeVariables(base.Variables, t.Variables),
+		Palette:   make(map[analyzer.ComponentType]string),
+		Fonts:     base.Fonts,
+		ECharts:   t.ECharts,
+	}
+	for compType, color := range base.Palette {
+		merged.Palette[compType] = color
+	}
+	for compType, color := range t.Palette {
+		merged.Palette[compType] = color
+	}
+	if t.Fonts.Body != "" {
+		merged.Fonts.Body = t.Fonts.Body
+	}
+	if t.Fonts.Mono != "" {
+		merged.Fonts.Mono = t.Fonts.Mono
+	}
+	return merged, nil
+}
+
+func mergeVariables(base, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range overrides {
+		merged[name] = value
+	}
+	return merged
+}
+
+var (
+	cssVariableName = regexp.MustCompile(`^--[A-Za-z0-9-]+$`)
+	hexColor        = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+)
+
+// validate rejects values that could break out of the stylesheet. Theme values
+// are written into the page verbatim, so this is what keeps them safe.
+func (t Theme) validate() error {
+	for name, value := range t.Variables {
+		if !cssVariableName.MatchString(name) {
+			return fmt.Errorf("variable %q is not a CSS custom property name", name)
+		}
+		if !safeCSSValue(value) {
+			return fmt.Errorf("variable %s has an unsafe value %q", name, value)
+		}
+	}
+	for compType, color := range t.Palette {
+		if !hexColor.MatchString(color) {
+			return fmt.Errorf("palette colour for %s must be #rrggbb, got %q", compType, color)
+		}
+	}
+	for _, font := range []string{t.Fonts.Body, t.Fonts.Mono} {
+		if !safeCSSValue(font) {
+			return fmt.Errorf("font %q has unsafe characters", font)
+		}
+	}
+	return nil
+}
+
+func safeCSSValue(value string) bool {
+	return !strings.ContainsAny(value, ";{}<>\\\n") && !strings.Contains(strings.ToLower(value), "url(")
+}
+
+// CSSVariables renders the theme's custom properties, fonts included, as
+// declarations for a :root rule.
+func (t Theme) CSSVariables() template.CSS {
+	vars := mergeVariables(t.Variables, map[string]string{
+		"--font-body": t.Fonts.Body,
+		"--font-mono": t.Fonts.Mono,
+	})
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("    %s: %s;\n", name, vars[name]))
+	}
+	return template.CSS(sb.String())
+}
+
+// Color returns the palette colour for a component type.
+func (t Theme) Color(compType analyzer.ComponentType) string {
+	if color, ok := t.Palette[compType]; ok {
+		return color
+	}
+	return builtinThemes[defaultThemeName].Palette[compType]
+}
@@ -1,12 +1,214 @@
 package prompts
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/junkd0g/sharingan/internal/resources"
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// Register registers all prompts with the MCP server.
-func Register(s *server.MCPServer) {
-	// TODO: Register your prompts here
-	// Example:
-	// s.AddPrompt(myPrompt, myPromptHandler)
+// Register registers all prompts with the MCP server, embedding analyses from
+// cache. Each prompt handler closes over it.
+func Register(s *server.MCPServer, cache *analyzer.Cache) {
+	s.AddPrompt(mcp.NewPrompt("review_architecture",
+		mcp.WithPromptDescription("Review the architecture of a Go repository, grounded in its analyzed components, metrics and rule violations"),
+		mcp.WithArgument("repo_path",
+			mcp.ArgumentDescription("The absolute path to the Go service repository"),
+			mcp.RequiredArgument(),
+		),
+		mcp.WithArgument("focus",
+			mcp.ArgumentDescription("Optional area to concentrate on, e.g. 'layering' or 'the payments package'"),
+		),
+	), reviewArchitectureHandler(cache))
+
+	s.AddPrompt(mcp.NewPrompt("explain_component",
+		mcp.WithPromptDescription("Explain what a component does, what it depends on and what depends on it"),
+		mcp.WithArgument("repo_path",
+			mcp.ArgumentDescription("The absolute path to the Go service repository"),
+			mcp.RequiredArgument(),
+		),
+		mcp.WithArgument("component",
+			mcp.ArgumentDescription("Name of the component, e.g. 'OrderService'"),
+			mcp.RequiredArgument(),
+		),
+	), explainComponentHandler(cache))
+
+	s.AddPrompt(mcp.NewPrompt("refactor_cycles",
+		mcp.WithPromptDescription("Propose refactorings that break the dependency cycles in a repository"),
+		mcp.WithArgument("repo_path",
+			mcp.ArgumentDescription("The absolute path to the Go service repository"),
+			mcp.RequiredArgument(),
+		),
+	), refactorCyclesHandler(cache))
+
+	s.AddPrompt(mcp.NewPrompt("onboard",
+		mcp.WithPromptDescription("Walk a new engineer through the structure of a service"),
+		mcp.WithArgument("repo_path",
+			mcp.ArgumentDescription("The absolute path to the Go service repository"),
+			mcp.RequiredArgument(),
+		),
+		mcp.WithArgument("role",
+			mcp.ArgumentDescription("Optional role of the engineer, e.g. 'backend', 'SRE' or 'frontend'"),
+		),
+	), onboardHandler(cache))
+}
+
+func reviewArchitectureHandler(cache *analyzer.Cache) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		repoPath, arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+
+		instructions := fmt.Sprintf(`Review the architecture of the Go service at %s.
+
+The attached resources contain the analyzed components and their dependencies, coupling metrics and violations of the built-in architecture rules. Base every finding on them and name the components involved.
+
+Cover:
+1. How well the handler, service, adapter and repository layers are separated
+2. Each rule violation: why it matters and how to fix it
+3. Coupling hot spots, using fan-in, fan-out and instability
+4. The three changes with the best payoff, in priority order`, repoPath)
+		if focus := request.Params.Arguments["focus"]; focus != "" {
+			instructions += fmt.Sprintf("\n\nConcentrate on: %s", focus)
+		}
+
+		return buildResult("Architecture review of "+repoPath, instructions, repoPath, arch)
+	}
+}
+
+func explainComponentHandler(cache *analyzer.Cache) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		name := request.Params.Arguments["component"]
+		if name == "" {
+			return nil, fmt.Errorf("component is required")
+		}
+
+		repoPath, arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+
+		component := arch.Component(name)
+		dependedBy := arch.Dependents(name)
+		if component == nil {
+			return nil, fmt.Errorf("component %q not found in %s", name, repoPath)
+		}
+
+		instructions := fmt.Sprintf(`Explain the %s component %s in the Go service at %s.
+
+It is declared in %s:%d. It depends on: %s. It is used by: %s.
+
+Using the attached resources, describe its responsibility, its public methods, how it fits into the layers of the service and what would be affected by changing it.`,
+			component.Type, component.Name, repoPath,
+			component.FilePath, component.Line, listOrNone(component.Dependencies), listOrNone(dependedBy))
+
+		return buildResult("Explanation of "+name, instructions, repoPath, arch)
+	}
+}
+
+func refactorCyclesHandler(cache *analyzer.Cache) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		repoPath, arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+
+		cycles := arch.Cycles()
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Propose refactorings that break the dependency cycles in the Go service at %s.\n\n", repoPath))
+		if len(cycles) == 0 {
+			sb.WriteString("The analysis found no dependency cycles between components. Confirm this from the attached resources and point out any near-cycles or layering violations that could turn into one.")
+		} else {
+			sb.WriteString("The analysis found these cycles:\n")
+			for _, cycle := range cycles {
+				sb.WriteString(fmt.Sprintf("- %s\n", strings.Join(cycle, " ↔ ")))
+			}
+			sb.WriteString(`
+For each cycle, name the dependency edge to cut and how: introduce an interface owned by the consumer, move shared logic into a new component, or invert the dependency with events or callbacks. Prefer changes that also fix the layering violations in the attached resources, and order the steps so the code compiles after each one.`)
+		}
+
+		return buildResult("Cycle refactoring for "+repoPath, sb.String(), repoPath, arch)
+	}
+}
+
+func onboardHandler(cache *analyzer.Cache) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		repoPath, arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+
+		instructions := fmt.Sprintf(`Onboard me to the Go service at %s.
+
+Using the attached resources, give me:
+1. A one-paragraph summary of what the service does, inferred from its components
+2. A tour of the layers, from the handlers down to the repositories and adapters, naming the key components in each
+3. The path a typical request takes through the components
+4. The most central components to read first, with their file paths
+5. Known problem areas from the rule violations, so I know where to tread carefully`, repoPath)
+		if role := request.Params.Arguments["role"]; role != "" {
+			instructions += fmt.Sprintf("\n\nTailor the walkthrough to a %s engineer.", role)
+		}
+
+		return buildResult("Onboarding to "+repoPath, instructions, repoPath, arch)
+	}
+}
+
+// contextResources are embedded into every prompt, in order, each rendered
+// from the analysis.
+var contextResources = []struct {
+	name   string
+	render func(arch *analyzer.Architecture) any
+}{
+	{"architecture", func(arch *analyzer.Architecture) any { return arch }},
+	{"metrics", func(arch *analyzer.Architecture) any { return analyzer.ComputeMetrics(arch) }},
+	{"violations", func(arch *analyzer.Architecture) any { return analyzer.CheckRules(arch) }},
+}
+
+func analyzeRepo(ctx context.Context, cache *analyzer.Cache, request mcp.GetPromptRequest) (string, *analyzer.Architecture, error) {
+	repoPath := request.Params.Arguments["repo_path"]
+	if repoPath == "" {
+		return "", nil, fmt.Errorf("repo_path is required")
+	}
+
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("repository path does not exist: %s", repoPath)
+	}
+
+	arch, err := cache.Analyze(ctx, repoPath, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to analyze repository: %w", err)
+	}
+	return repoPath, arch, nil
+}
+
+// buildResult creates a prompt with the instructions followed by the
+// analysis resources as embedded context.
+func buildResult(description, instructions, repoPath string, arch *analyzer.Architecture) (*mcp.GetPromptResult, error) {
+	messages := []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(instructions)),
+	}
+
+	for _, res := range contextResources {
+		contents, err := resources.JSON(resources.URI(repoPath, res.name), res.render(arch))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(contents)))
+	}
+
+	return mcp.NewGetPromptResult(description, messages), nil
+}
+
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
 }
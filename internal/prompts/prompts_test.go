@@ -0,0 +1,161 @@
+package prompts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/junkd0g/sharingan/internal/resources"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// writeRepo creates a repository with a handler, a service and a store.
+func writeRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"internal/handler/order.go": `package handler
+
+type OrderService interface {
+	Place(ctx context.Context, id string) error
+}
+
+type OrderHandler struct {
+	orders OrderService
+}
+`,
+		"internal/service/order.go": `package service
+
+type OrderStore interface {
+	Save(ctx context.Context, id string) error
+}
+
+type OrderService struct {
+	repo OrderStore
+}
+
+func (s *OrderService) Place(ctx context.Context, id string) error { return s.repo.Save(ctx, id) }
+`,
+		"internal/store/order.go": `package store
+
+type OrderStore struct{}
+
+func (s OrderStore) Save(ctx context.Context, id string) error { return nil }
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return root
+}
+
+func promptRequest(arguments map[string]string) mcp.GetPromptRequest {
+	var request mcp.GetPromptRequest
+	request.Params.Arguments = arguments
+	return request
+}
+
+// instructions returns the text of a prompt's first message, and checks the
+// analysis resources follow it.
+func instructions(t *testing.T, repoPath string, result *mcp.GetPromptResult) string {
+	t.Helper()
+	if len(result.Messages) != 1+len(contextResources) {
+		t.Fatalf("got %d messages, want the instructions and %d resources", len(result.Messages), len(contextResources))
+	}
+	text, ok := result.Messages[0].Content.(mcp.TextContent)
+	if !ok || result.Messages[0].Role != mcp.RoleUser {
+		t.Fatalf("first message = %+v, want user instructions", result.Messages[0])
+	}
+	for i, res := range contextResources {
+		embedded, ok := result.Messages[i+1].Content.(mcp.EmbeddedResource)
+		if !ok {
+			t.Fatalf("message %d = %+v, want an embedded resource", i+1, result.Messages[i+1])
+		}
+		contents, ok := embedded.Resource.(mcp.TextResourceContents)
+		if !ok || contents.URI != resources.URI(repoPath, res.name) || !json.Valid([]byte(contents.Text)) {
+			t.Errorf("resource %s = %+v, want JSON at %s", res.name, embedded.Resource, resources.URI(repoPath, res.name))
+		}
+	}
+	return text.Text
+}
+
+func TestReviewArchitecturePrompt(t *testing.T) {
+	repo := writeRepo(t)
+	handler := reviewArchitectureHandler(analyzer.NewCache(""))
+
+	result, err := handler(t.Context(), promptRequest(map[string]string{"repo_path": repo, "focus": "layering"}))
+	if err != nil {
+		t.Fatalf("review_architecture: %v", err)
+	}
+	text := instructions(t, repo, result)
+	if !strings.Contains(text, repo) || !strings.HasSuffix(text, "Concentrate on: layering") {
+		t.Errorf("instructions = %q, want the repository and the focus", text)
+	}
+
+	result, err = handler(t.Context(), promptRequest(map[string]string{"repo_path": repo}))
+	if err != nil {
+		t.Fatalf("review_architecture: %v", err)
+	}
+	if text := instructions(t, repo, result); strings.Contains(text, "Concentrate on") {
+		t.Errorf("instructions = %q, want no focus when none is given", text)
+	}
+}
+
+func TestExplainComponentPrompt(t *testing.T) {
+	repo := writeRepo(t)
+	handler := explainComponentHandler(analyzer.NewCache(""))
+
+	result, err := handler(t.Context(), promptRequest(map[string]string{"repo_path": repo, "component": "OrderService"}))
+	if err != nil {
+		t.Fatalf("explain_component: %v", err)
+	}
+	want := "It depends on: OrderStore. It is used by: OrderHandler."
+	if text := instructions(t, repo, result); !strings.Contains(text, "service component OrderService") || !strings.Contains(text, want) {
+		t.Errorf("instructions = %q, want OrderService described with %q", text, want)
+	}
+
+	for _, tc := range []struct {
+		arguments map[string]string
+		want      string
+	}{
+		{map[string]string{"repo_path": repo}, "component is required"},
+		{map[string]string{"component": "OrderService"}, "repo_path is required"},
+		{map[string]string{"repo_path": repo, "component": "Nope"}, `component "Nope" not found`},
+		{map[string]string{"repo_path": filepath.Join(repo, "missing"), "component": "OrderService"}, "does not exist"},
+	} {
+		if _, err := handler(t.Context(), promptRequest(tc.arguments)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("arguments %v: err = %v, want %q", tc.arguments, err, tc.want)
+		}
+	}
+}
+
+func TestRefactorCyclesPrompt(t *testing.T) {
+	repo := writeRepo(t)
+	result, err := refactorCyclesHandler(analyzer.NewCache(""))(t.Context(), promptRequest(map[string]string{"repo_path": repo}))
+	if err != nil {
+		t.Fatalf("refactor_cycles: %v", err)
+	}
+	if text := instructions(t, repo, result); !strings.Contains(text, "found no dependency cycles") {
+		t.Errorf("instructions = %q, want no cycles reported", text)
+	}
+}
+
+func TestOnboardPrompt(t *testing.T) {
+	repo := writeRepo(t)
+	result, err := onboardHandler(analyzer.NewCache(""))(t.Context(), promptRequest(map[string]string{"repo_path": repo, "role": "SRE"}))
+	if err != nil {
+		t.Fatalf("onboard: %v", err)
+	}
+	if text := instructions(t, repo, result); !strings.HasSuffix(text, "Tailor the walkthrough to a SRE engineer.") {
+		t.Errorf("instructions = %q, want them tailored to the role", text)
+	}
+}
@@ -1,12 +1,197 @@
 package resources
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/junkd0g/sharingan/internal/diagram"
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// Register registers all resources with the MCP server.
-func Register(s *server.MCPServer) {
-	// TODO: Register your resources here
-	// Example:
-	// s.AddResource(myResource, myResourceHandler)
+// Resource URI templates. {+path} is the absolute repository path, either
+// verbatim or percent-encoded, e.g. sharingan://repo//home/me/orders/architecture.
+const (
+	architectureURI = "sharingan://repo/{+path}/architecture"
+	componentURI    = "sharingan://repo/{+path}/components/{id}"
+	metricsURI      = "sharingan://repo/{+path}/metrics"
+	violationsURI   = "sharingan://repo/{+path}/violations"
+	reportURI       = "sharingan://repo/{+path}/report.html"
+)
+
+// URI returns the URI of a repository resource, e.g. URI("/home/me/orders", "metrics").
+func URI(repoPath, name string) string {
+	return "sharingan://repo/" + repoPath + "/" + name
+}
+
+// Register registers all resources with the MCP server, reading analyses from
+// cache, which every handler closes over.
+func Register(s *server.MCPServer, cache *analyzer.Cache) {
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(architectureURI, "Architecture",
+			mcp.WithTemplateDescription("Analyzed architecture of a Go repository: components, their dependencies and source locations"),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		architectureHandler(cache),
+	)
+
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(componentURI, "Component",
+			mcp.WithTemplateDescription("A single architectural component by name, with its dependencies, dependents and methods"),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		componentHandler(cache),
+	)
+
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(metricsURI, "Architecture metrics",
+			mcp.WithTemplateDescription("Coupling metrics: component counts, fan-in, fan-out, instability and dependency cycles"),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		metricsHandler(cache),
+	)
+
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(violationsURI, "Rule violations",
+			mcp.WithTemplateDescription("Breaches of the built-in architecture rules: layering, skipped service tier, dependency cycles and high fan-out"),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		violationsHandler(cache),
+	)
+
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(reportURI, "Architecture report",
+			mcp.WithTemplateDescription("Interactive HTML architecture report, rendered with the default configuration"),
+			mcp.WithTemplateMIMEType("text/html"),
+		),
+		reportHandler(cache),
+	)
+}
+
+func architectureHandler(cache *analyzer.Cache) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+		return jsonContents(request.Params.URI, arch)
+	}
+}
+
+func componentHandler(cache *analyzer.Cache) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		id := argument(request, "id")
+		if id == "" {
+			return nil, fmt.Errorf("component id is required")
+		}
+
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+
+		detail := arch.Detail(id)
+		if detail == nil {
+			return nil, fmt.Errorf("component %q not found", id)
+		}
+		return jsonContents(request.Params.URI, detail)
+	}
+}
+
+func metricsHandler(cache *analyzer.Cache) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+		return jsonContents(request.Params.URI, analyzer.ComputeMetrics(arch))
+	}
+}
+
+func violationsHandler(cache *analyzer.Cache) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+		return jsonContents(request.Params.URI, analyzer.CheckRules(arch))
+	}
+}
+
+func reportHandler(cache *analyzer.Cache) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return nil, err
+		}
+
+		html, err := diagram.RenderHTML(arch, diagram.DefaultConfig())
+		if err != nil {
+			return nil, fmt.Errorf("failed to render report: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "text/html",
+				Text:     string(html),
+			},
+		}, nil
+	}
+}
+
+func analyzeRepo(ctx context.Context, cache *analyzer.Cache, request mcp.ReadResourceRequest) (*analyzer.Architecture, error) {
+	repoPath := argument(request, "path")
+	if repoPath == "" {
+		return nil, fmt.Errorf("repository path is required")
+	}
+
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("repository path does not exist: %s", repoPath)
+	}
+
+	arch, err := cache.Analyze(ctx, repoPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze repository: %w", err)
+	}
+	return arch, nil
+}
+
+// argument returns a URI template variable. Depending on the template
+// expression, matched values arrive as a string or a list of strings.
+func argument(request mcp.ReadResourceRequest, name string) string {
+	switch v := request.Params.Arguments[name].(type) {
+	case string:
+		return v
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func jsonContents(uri string, v any) ([]mcp.ResourceContents, error) {
+	contents, err := JSON(uri, v)
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{contents}, nil
+}
+
+// JSON encodes v as indented JSON resource contents.
+func JSON(uri string, v any) (mcp.TextResourceContents, error) {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return mcp.TextResourceContents{}, fmt.Errorf("failed to encode resource: %w", err)
+	}
+
+	return mcp.TextResourceContents{
+		URI:      uri,
+		MIMEType: "application/json",
+		Text:     string(content),
+	}, nil
 }
@@ -0,0 +1,187 @@
+package resources
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// writeRepo creates a repository with a handler, a service and a store.
+func writeRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"internal/handler/order.go": `package handler
+
+type OrderService interface {
+	Place(ctx context.Context, id string) error
+}
+
+type OrderHandler struct {
+	orders OrderService
+}
+
+func (h *OrderHandler) Create(w http.ResponseWriter, r *http.Request) {}
+`,
+		"internal/service/order.go": `package service
+
+type OrderStore interface {
+	Save(ctx context.Context, id string) error
+}
+
+type OrderService struct {
+	repo OrderStore
+}
+
+func (s *OrderService) Place(ctx context.Context, id string) error { return s.repo.Save(ctx, id) }
+`,
+		"internal/store/order.go": `package store
+
+type OrderStore struct{}
+
+func (s OrderStore) Save(ctx context.Context, id string) error { return nil }
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return root
+}
+
+func readRequest(uri string, arguments map[string]any) mcp.ReadResourceRequest {
+	var request mcp.ReadResourceRequest
+	request.Params.URI = uri
+	request.Params.Arguments = arguments
+	return request
+}
+
+// decode reads the only JSON contents of a resource into v.
+func decode(t *testing.T, contents []mcp.ResourceContents, v any) {
+	t.Helper()
+	if len(contents) != 1 {
+		t.Fatalf("got %d contents, want 1", len(contents))
+	}
+	text, ok := contents[0].(mcp.TextResourceContents)
+	if !ok || text.MIMEType != "application/json" {
+		t.Fatalf("contents = %+v, want JSON text", contents[0])
+	}
+	if err := json.Unmarshal([]byte(text.Text), v); err != nil {
+		t.Fatalf("Failed to decode %s: %v", text.Text, err)
+	}
+}
+
+func TestArchitectureResource(t *testing.T) {
+	repo := writeRepo(t)
+	handler := architectureHandler(analyzer.NewCache(""))
+
+	uri := URI(repo, "architecture")
+	contents, err := handler(t.Context(), readRequest(uri, map[string]any{"path": []string{repo}}))
+	if err != nil {
+		t.Fatalf("architecture: %v", err)
+	}
+	if text := contents[0].(mcp.TextResourceContents); text.URI != uri {
+		t.Errorf("URI = %q, want %q", text.URI, uri)
+	}
+	var arch struct {
+		Components []struct {
+			Name         string   `json:"name"`
+			Type         string   `json:"type"`
+			Dependencies []string `json:"dependencies"`
+		} `json:"components"`
+	}
+	decode(t, contents, &arch)
+	deps := make(map[string][]string)
+	for _, comp := range arch.Components {
+		deps[comp.Name] = comp.Dependencies
+	}
+	if len(deps) != 3 || len(deps["OrderHandler"]) != 1 || deps["OrderHandler"][0] != "OrderService" {
+		t.Errorf("components = %+v, want OrderHandler, OrderService and OrderStore", arch.Components)
+	}
+
+	for _, arguments := range []map[string]any{{}, {"path": ""}} {
+		if _, err := handler(t.Context(), readRequest(uri, arguments)); err == nil || !strings.Contains(err.Error(), "required") {
+			t.Errorf("arguments %v: err = %v, want the path required", arguments, err)
+		}
+	}
+	missing := filepath.Join(repo, "missing")
+	if _, err := handler(t.Context(), readRequest(URI(missing, "architecture"), map[string]any{"path": missing})); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("missing repository: err = %v, want it reported", err)
+	}
+}
+
+func TestComponentResource(t *testing.T) {
+	repo := writeRepo(t)
+	handler := componentHandler(analyzer.NewCache(""))
+
+	contents, err := handler(t.Context(), readRequest(URI(repo, "components/OrderService"), map[string]any{"path": repo, "id": "OrderService"}))
+	if err != nil {
+		t.Fatalf("component: %v", err)
+	}
+	var detail struct {
+		Name         string   `json:"name"`
+		Type         string   `json:"type"`
+		Dependencies []string `json:"dependencies"`
+		DependedBy   []string `json:"dependedBy"`
+	}
+	decode(t, contents, &detail)
+	if detail.Name != "OrderService" || detail.Type != "service" ||
+		len(detail.Dependencies) != 1 || detail.Dependencies[0] != "OrderStore" ||
+		len(detail.DependedBy) != 1 || detail.DependedBy[0] != "OrderHandler" {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	if _, err := handler(t.Context(), readRequest(URI(repo, "components/Nope"), map[string]any{"path": repo, "id": "Nope"})); err == nil || !strings.Contains(err.Error(), `"Nope" not found`) {
+		t.Errorf("unknown component: err = %v, want it not found", err)
+	}
+	if _, err := handler(t.Context(), readRequest(URI(repo, "components/"), map[string]any{"path": repo})); err == nil || !strings.Contains(err.Error(), "id is required") {
+		t.Errorf("no id: err = %v, want the id required", err)
+	}
+}
+
+func TestMetricsAndViolationsResources(t *testing.T) {
+	repo := writeRepo(t)
+	cache := analyzer.NewCache("")
+
+	contents, err := metricsHandler(cache)(t.Context(), readRequest(URI(repo, "metrics"), map[string]any{"path": repo}))
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	var metrics analyzer.Metrics
+	decode(t, contents, &metrics)
+	if metrics.TotalComponents != 3 || metrics.TotalDependencies != 2 {
+		t.Errorf("metrics = %+v, want 3 components and 2 dependencies", metrics)
+	}
+
+	contents, err = violationsHandler(cache)(t.Context(), readRequest(URI(repo, "violations"), map[string]any{"path": repo}))
+	if err != nil {
+		t.Fatalf("violations: %v", err)
+	}
+	var violations []analyzer.Violation
+	decode(t, contents, &violations)
+	if len(violations) != 0 {
+		t.Errorf("violations = %+v, want none for a layered repository", violations)
+	}
+}
+
+func TestReportResource(t *testing.T) {
+	repo := writeRepo(t)
+	contents, err := reportHandler(analyzer.NewCache(""))(t.Context(), readRequest(URI(repo, "report.html"), map[string]any{"path": repo}))
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	text, ok := contents[0].(mcp.TextResourceContents)
+	if len(contents) != 1 || !ok || text.MIMEType != "text/html" || !strings.Contains(text.Text, "OrderService") {
+		t.Errorf("report = %T %q..., want an HTML report naming the components", contents[0], text.Text[:min(len(text.Text), 80)])
+	}
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func registerImpactTool(s *server.MCPServer, cache *analyzer.Cache) {
+	s.AddTool(mcp.NewTool("analyze_impact",
+		mcp.WithDescription(`Computes the blast radius of a change as JSON. Changed files are mapped to the components declared in them; files that declare no component, such as interface definitions, map to the components in the same package.
+
+Reverse dependencies are then followed up to the handlers. The result lists the changed components, every affected component and package ranked by distance from the change, and the affected entry points: handlers with the methods that serve requests.
+
+Give at least one of 'files', 'diff' or 'components'.`),
+		mcp.WithString("repo_path",
+			mcp.Required(),
+			mcp.Description("The absolute path to the Go service repository to analyze"),
+		),
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("files",
+			mcp.Description("Comma- or newline-separated changed files, absolute or relative to the repository"),
+		),
+		mcp.WithString("diff",
+			mcp.Description("A unified diff, e.g. the output of 'git diff main'. Paths are taken as relative to the repository root"),
+		),
+		mcp.WithString("components",
+			mcp.Description("Comma-separated names of changed components, e.g. 'OrderRepository'"),
+		),
+	), impactHandler(cache))
+}
+
+func impactHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		filesStr, _ := request.Params.Arguments["files"].(string)
+		diff, _ := request.Params.Arguments["diff"].(string)
+		componentsStr, _ := request.Params.Arguments["components"].(string)
+		if strings.TrimSpace(filesStr+diff+componentsStr) == "" {
+			return newToolResultError("one of files, diff or components is required"), nil
+		}
+
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		repoPath := request.Params.Arguments["repo_path"].(string)
+
+		var files []string
+		for _, file := range splitList(filesStr, ",\n") {
+			if filepath.IsAbs(file) {
+				if rel, err := filepath.Rel(repoPath, file); err == nil {
+					file = rel
+				}
+			}
+			files = append(files, file)
+		}
+
+		// Diff paths are relative to the root of the git repository, which is
+		// above repo_path when it is a subdirectory.
+		for _, file := range analyzer.DiffFiles(diff) {
+			if subdir := arch.Repository.Subdir; subdir != "" {
+				rel, ok := strings.CutPrefix(file, subdir+"/")
+				if !ok {
+					continue
+				}
+				file = rel
+			}
+			files = append(files, file)
+		}
+
+		impact, err := arch.Impact(files, splitList(componentsStr, ","))
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+
+		return jsonResult(impact)
+	}
+}
+
+// splitList splits s on any of the separator characters, dropping blanks.
+func splitList(s, separators string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(separators, r) }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
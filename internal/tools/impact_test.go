@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+)
+
+func TestImpactTool(t *testing.T) {
+	repo := writeRepo(t, ordersRepo)
+	handler := impactHandler(analyzer.NewCache(""))
+
+	affected := func(impact analyzer.Impact) map[string]int {
+		distances := make(map[string]int)
+		for _, a := range impact.Affected {
+			distances[a.Name] = a.Distance
+		}
+		return distances
+	}
+
+	// Absolute paths are taken relative to the repository
+	var impact analyzer.Impact
+	callToolJSON(t, handler, map[string]any{
+		"repo_path": repo,
+		"files":     filepath.Join(repo, "internal", "store", "order.go") + "\nREADME.md",
+	}, &impact)
+	if len(impact.Changed) != 1 || impact.Changed[0].Name != "OrderStore" {
+		t.Errorf("changed = %+v, want OrderStore", impact.Changed)
+	}
+	if want := map[string]int{"OrderStore": 0, "OrderService": 1, "AuditService": 1, "OrderHandler": 2}; !reflect.DeepEqual(affected(impact), want) {
+		t.Errorf("affected = %+v, want %v", impact.Affected, want)
+	}
+	if len(impact.EntryPoints) != 1 || impact.EntryPoints[0].Name != "OrderHandler" {
+		t.Errorf("entry points = %+v, want OrderHandler", impact.EntryPoints)
+	}
+	if !reflect.DeepEqual(impact.Unmatched, []string{"README.md"}) {
+		t.Errorf("unmatched = %v, want README.md", impact.Unmatched)
+	}
+
+	diff := `diff --git a/internal/service/audit.go b/internal/service/audit.go
+--- a/internal/service/audit.go
++++ b/internal/service/audit.go
+@@ -1,3 +1,3 @@
+ package service
+`
+	impact = analyzer.Impact{}
+	callToolJSON(t, handler, map[string]any{"repo_path": repo, "diff": diff, "components": "OrderService"}, &impact)
+	var changed []string
+	for _, c := range impact.Changed {
+		changed = append(changed, c.Name)
+	}
+	if !reflect.DeepEqual(changed, []string{"OrderService", "AuditService"}) {
+		t.Errorf("changed = %v, want OrderService by name and AuditService from the diff", changed)
+	}
+	if want := map[string]int{"AuditService": 0, "OrderService": 0, "OrderHandler": 1}; !reflect.DeepEqual(affected(impact), want) {
+		t.Errorf("affected = %+v, want %v", impact.Affected, want)
+	}
+
+	wantToolError(t, handler, map[string]any{"repo_path": repo}, "one of files, diff or components is required")
+	wantToolError(t, handler, map[string]any{"repo_path": repo, "components": "Nope"}, `component "Nope" not found`)
+	wantToolError(t, handler, map[string]any{"components": "OrderStore"}, "repo_path is required")
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// progressNotifier sends MCP progress notifications for a tool call. Progress
+// counts the parsed files, then one step for building the architecture and
+// one for each further stage, such as rendering a report.
+type progressNotifier struct {
+	ctx    context.Context
+	server *server.MCPServer
+	token  mcp.ProgressToken
+	stages int // Steps after parsing, building included
+
+	files int     // Files to parse, once known
+	last  float64 // Last progress sent
+	sent  bool
+}
+
+// newProgressNotifier returns a notifier for the request, or nil if the client
+// did not ask for progress. stages is the number of steps after parsing.
+func newProgressNotifier(ctx context.Context, request mcp.CallToolRequest, stages int) *progressNotifier {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	return &progressNotifier{ctx: ctx, server: srv, token: request.Params.Meta.ProgressToken, stages: stages}
+}
+
+// withAnalysis returns a context that reports analysis progress through n.
+func (n *progressNotifier) withAnalysis(ctx context.Context) context.Context {
+	if n == nil {
+		return ctx
+	}
+	return analyzer.WithProgress(ctx, n.analysis)
+}
+
+func (n *progressNotifier) analysis(p analyzer.Progress) {
+	switch p.Phase {
+	case analyzer.PhaseScan:
+		n.send(0, "Scanning repository")
+	case analyzer.PhaseParse:
+		n.files = p.Total
+		n.send(float64(p.Current), fmt.Sprintf("Parsed %d of %d files", p.Current, p.Total))
+	case analyzer.PhaseBuild:
+		n.send(float64(n.files+1), "Resolving components and dependencies")
+	}
+}
+
+// stage reports the start of a step after building, numbered from 2.
+func (n *progressNotifier) stage(step int, message string) {
+	if n == nil {
+		return
+	}
+	n.send(float64(n.files+step), message)
+}
+
+func (n *progressNotifier) send(progress float64, message string) {
+	// Progress must increase with every notification
+	if n.sent && progress <= n.last {
+		return
+	}
+	n.last, n.sent = progress, true
+
+	params := map[string]any{
+		"progressToken": n.token,
+		"progress":      progress,
+		"message":       message,
+	}
+	if n.files > 0 {
+		params["total"] = float64(n.files + n.stages)
+	}
+	// Progress is best effort: a full notification channel drops the update
+	_ = n.server.SendNotificationToClient(n.ctx, "notifications/progress", params)
+}
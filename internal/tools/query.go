@@ -0,0 +1,612 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultMaxPaths caps find_paths when max_paths is not set, since the number
+// of paths grows quickly in densely connected graphs.
+const defaultMaxPaths = 100
+
+// moduleOption narrows a tool to one module of a multi-module repository.
+var moduleOption = mcp.WithString("module",
+	mcp.Description("Only this module of a multi-module repository or go.work workspace, by module path, e.g. 'github.com/acme/orders', or directory, e.g. 'services/orders'. Defaults to every module"),
+)
+
+// buildOptions select the files analyzed by their build constraints. Without
+// them, every file is analyzed.
+var buildOptions mcp.ToolOption = func(tool *mcp.Tool) {
+	for _, option := range []mcp.ToolOption{
+		mcp.WithString("build_tags",
+			mcp.Description("Comma-separated build tags, e.g. 'integration,cgo'. Setting this, goos or goarch analyzes only the files whose //go:build constraints and _GOOS/_GOARCH file name suffixes match, as go build would"),
+		),
+		mcp.WithString("goos",
+			mcp.Description("Target operating system for build constraints, e.g. 'linux'. Defaults to the server's when build_tags or goarch is set"),
+		),
+		mcp.WithString("goarch",
+			mcp.Description("Target architecture for build constraints, e.g. 'arm64'. Defaults to the server's when build_tags or goos is set"),
+		),
+	} {
+		option(tool)
+	}
+}
+
+// generatedOption decides how components from generated files appear.
+var generatedOption = mcp.WithString("generated",
+	mcp.Description("How components declared in generated files ('Code generated ... DO NOT EDIT.') appear: 'show' (default) like any other, marked with their generator; 'hide' to leave them out; or 'collapse' into one component per generator, e.g. 'generated/sqlc'"),
+)
+
+func registerQueryTools(s *server.MCPServer, cache *analyzer.Cache) {
+	repoPath := mcp.WithString("repo_path",
+		mcp.Required(),
+		mcp.Description("The absolute path to the Go service repository to analyze"),
+	)
+
+	s.AddTool(mcp.NewTool("list_components",
+		mcp.WithDescription("Lists the architectural components of a Go repository as JSON, optionally filtered by type, package or name"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("type",
+			mcp.Description("Comma-separated component types to include: handler, service, repository, adapter, middleware"),
+		),
+		mcp.WithString("package",
+			mcp.Description("Package name, e.g. 'payments', or directory relative to the repository, e.g. 'internal/payments'. Directories match their subdirectories too"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Case-insensitive substring of the component name"),
+		),
+	), listComponentsHandler(cache))
+
+	s.AddTool(mcp.NewTool("get_component",
+		mcp.WithDescription("Returns a component as JSON: location, doc comment, methods, dependencies annotated with the methods it calls on them, each of those calls with its location, and the components that depend on it. Calls are traced through receiver fields, local variables holding them and closures; calls on method parameters, on values returned by other calls or on package variables are not, so a dependency can have fewer calls listed than it receives"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("component",
+			mcp.Required(),
+			mcp.Description("Name of the component, e.g. 'OrderService'"),
+		),
+	), getComponentHandler(cache))
+
+	s.AddTool(mcp.NewTool("get_dependencies",
+		mcp.WithDescription("Returns the components a component depends on, directly or transitively, with their distance in dependency edges"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("component",
+			mcp.Required(),
+			mcp.Description("Name of the component, e.g. 'OrderService'"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("How many dependency edges to follow. Defaults to 1 (direct dependencies); 0 follows them all"),
+		),
+	), neighborsHandler(cache, false))
+
+	s.AddTool(mcp.NewTool("get_dependents",
+		mcp.WithDescription("Returns the components that depend on a component, directly or transitively, with their distance in dependency edges. Answers questions like 'what talks to the payments repository?'"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("component",
+			mcp.Required(),
+			mcp.Description("Name of the component, e.g. 'PaymentRepository'"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("How many dependency edges to follow. Defaults to 1 (direct dependents); 0 follows them all"),
+		),
+	), neighborsHandler(cache, true))
+
+	s.AddTool(mcp.NewTool("find_paths",
+		mcp.WithDescription("Returns every dependency path from one component to another, shortest first"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("Name of the component the paths start at, e.g. 'OrderHandler'"),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("Name of the component the paths end at, e.g. 'PaymentRepository'"),
+		),
+		mcp.WithNumber("max_paths",
+			mcp.Description(fmt.Sprintf("Maximum number of paths to return. Defaults to %d; 0 returns them all", defaultMaxPaths)),
+		),
+	), findPathsHandler(cache))
+
+	s.AddTool(mcp.NewTool("get_diagnostics",
+		mcp.WithDescription("Returns what the analysis skipped, dropped or failed on, as JSON: files that do not parse or could not be read, skipped directories, structs excluded as noise with the reason, and dependencies dropped because they are not components. Use it to find out why a component or dependency is missing"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("kind",
+			mcp.Description("Comma-separated kinds to include: parse-error, read-error, skipped-directory, excluded-file, excluded-struct, unresolved-dependency"),
+		),
+		mcp.WithString("component",
+			mcp.Description("Only diagnostics about this struct or component"),
+		),
+	), diagnosticsHandler(cache))
+
+	s.AddTool(mcp.NewTool("explain_component",
+		mcp.WithDescription("Explains how the analysis treated a struct, as JSON: for a component, the evidence for its type (package path segment, name match, dependency count and threshold, config-package rule); for any struct, each classification heuristic tried, which field types counted as dependencies, and the shouldSkipStruct rule that excluded it, if one did. Works on structs that are not components"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("component",
+			mcp.Required(),
+			mcp.Description("Name of the struct, e.g. 'OrderService' or 'CreateOrderRequest'"),
+		),
+	), explainComponentHandler(cache))
+
+	s.AddTool(mcp.NewTool("list_routes",
+		mcp.WithDescription("Lists the HTTP routes registered in a Go repository as JSON, each with the middleware its requests pass through in order and the handler, e.g. 'GET /orders → Auth → RateLimit → OrdersHandler.List'. Covers net/http, gorilla/mux, chi, gin, echo and fiber routers and groups"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+		mcp.WithString("path",
+			mcp.Description("Only routes whose path starts with this prefix, e.g. '/api/orders'"),
+		),
+		mcp.WithString("middleware",
+			mcp.Description("Only routes passing through this middleware, e.g. 'Auth'"),
+		),
+	), listRoutesHandler(cache))
+
+	s.AddTool(mcp.NewTool("list_modules",
+		mcp.WithDescription("Lists the Go modules of a repository as JSON, from its go.work workspace or its go.mod files, with how many components each holds and the dependencies between components in different modules"),
+		repoPath,
+	), listModulesHandler(cache))
+
+	s.AddTool(mcp.NewTool("list_contracts",
+		mcp.WithDescription("Lists the contracts declared in generated code as JSON, taking it as the authoritative source for them: gRPC services from protoc-gen-go-grpc, OpenAPI server interfaces from oapi-codegen and database queries from sqlc. Each comes with its operations, the components implementing it and the components using it"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		mcp.WithString("kind",
+			mcp.Description("Only contracts of this kind: grpc, openapi or db"),
+		),
+	), listContractsHandler(cache))
+
+	s.AddTool(mcp.NewTool("get_api_surface",
+		mcp.WithDescription("Returns the API surface as JSON: the OpenAPI 3 specs in the repository, each operation with the route and handler implementing it, the operations nothing implements and the routes no spec documents"),
+		repoPath,
+		moduleOption,
+		buildOptions,
+		generatedOption,
+	), apiSurfaceHandler(cache))
+}
+
+// componentSummary is the short form of a component returned by list_components.
+type componentSummary struct {
+	Name         string                 `json:"name"`
+	Type         analyzer.ComponentType `json:"type"`
+	Package      string                 `json:"package"`
+	FilePath     string                 `json:"filePath"`
+	Line         int                    `json:"line"`
+	Dependencies int                    `json:"dependencies"`
+	DependedBy   int                    `json:"dependedBy"`
+}
+
+func listComponentsHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+
+		types := make(map[analyzer.ComponentType]bool)
+		if typesStr, ok := request.Params.Arguments["type"].(string); ok && typesStr != "" {
+			for _, part := range strings.Split(typesStr, ",") {
+				compType := analyzer.ComponentType(strings.TrimSpace(strings.ToLower(part)))
+				switch compType {
+				case analyzer.ComponentHandler, analyzer.ComponentService, analyzer.ComponentRepository, analyzer.ComponentAdapter, analyzer.ComponentMiddleware:
+					types[compType] = true
+				default:
+					return newToolResultError(fmt.Sprintf("invalid type %q: use handler, service, repository, adapter or middleware", part)), nil
+				}
+			}
+		}
+		pkg, _ := request.Params.Arguments["package"].(string)
+		name, _ := request.Params.Arguments["name"].(string)
+
+		components := []componentSummary{}
+		for _, comp := range arch.Components {
+			if len(types) > 0 && !types[comp.Type] {
+				continue
+			}
+			if pkg != "" && !inPackage(comp, pkg) {
+				continue
+			}
+			if name != "" && !strings.Contains(strings.ToLower(comp.Name), strings.ToLower(name)) {
+				continue
+			}
+			components = append(components, componentSummary{
+				Name:         comp.Name,
+				Type:         comp.Type,
+				Package:      comp.Package,
+				FilePath:     comp.FilePath,
+				Line:         comp.Line,
+				Dependencies: len(comp.Dependencies),
+				DependedBy:   len(arch.Dependents(comp.Name)),
+			})
+		}
+
+		return jsonResult(components)
+	}
+}
+
+// inPackage reports whether a component belongs to a package, given by name
+// or by slash-separated directory relative to the repository.
+func inPackage(comp analyzer.Component, pkg string) bool {
+	if comp.Package == pkg {
+		return true
+	}
+	dir := filepath.ToSlash(filepath.Dir(comp.FilePath))
+	pkg = strings.Trim(pkg, "/")
+	return dir == pkg || strings.HasPrefix(dir, pkg+"/")
+}
+
+func getComponentHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arch, comp, errResult := analyzeComponent(ctx, cache, request, "component")
+		if errResult != nil {
+			return errResult, nil
+		}
+
+		return jsonResult(arch.Detail(comp.Name))
+	}
+}
+
+// neighborsHandler serves get_dependencies or, when reverse is set, get_dependents.
+func neighborsHandler(cache *analyzer.Cache, reverse bool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arch, comp, errResult := analyzeComponent(ctx, cache, request, "component")
+		if errResult != nil {
+			return errResult, nil
+		}
+
+		depth := 1
+		if d, ok := request.Params.Arguments["depth"].(float64); ok {
+			depth = int(d)
+		}
+
+		neighbors := arch.Reachable(comp.Name, depth, reverse)
+		result := struct {
+			Component string           `json:"component"`
+			Depth     int              `json:"depth"`
+			Results   []neighborResult `json:"results"`
+		}{Component: comp.Name, Depth: depth, Results: []neighborResult{}}
+		for _, n := range neighbors {
+			neighbor := arch.Component(n.Name)
+			result.Results = append(result.Results, neighborResult{
+				Name:     n.Name,
+				Distance: n.Distance,
+				Type:     neighbor.Type,
+				Package:  neighbor.Package,
+				FilePath: neighbor.FilePath,
+			})
+		}
+
+		return jsonResult(result)
+	}
+}
+
+// neighborResult is a component returned by get_dependencies or get_dependents.
+type neighborResult struct {
+	Name     string                 `json:"name"`
+	Distance int                    `json:"distance"` // Dependency edges from the queried component
+	Type     analyzer.ComponentType `json:"type"`
+	Package  string                 `json:"package"`
+	FilePath string                 `json:"filePath"`
+}
+
+func findPathsHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arch, from, errResult := analyzeComponent(ctx, cache, request, "from")
+		if errResult != nil {
+			return errResult, nil
+		}
+
+		toName, _ := request.Params.Arguments["to"].(string)
+		to := arch.Component(toName)
+		if to == nil {
+			return newToolResultError(fmt.Sprintf("component %q not found", toName)), nil
+		}
+
+		maxPaths := defaultMaxPaths
+		if m, ok := request.Params.Arguments["max_paths"].(float64); ok {
+			maxPaths = int(m)
+		}
+
+		// Looking for one path more than max_paths tells whether there are more
+		limit := maxPaths
+		if limit > 0 {
+			limit++
+		}
+		paths, err := arch.Paths(ctx, from.Name, to.Name, limit)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("failed to find paths: %v", err)), nil
+		}
+		truncated := maxPaths > 0 && len(paths) > maxPaths
+		if truncated {
+			paths = paths[:maxPaths]
+		}
+		return jsonResult(struct {
+			From      string     `json:"from"`
+			To        string     `json:"to"`
+			Paths     [][]string `json:"paths"`
+			Truncated bool       `json:"truncated"` // More paths exist than max_paths
+		}{
+			From:      from.Name,
+			To:        to.Name,
+			Paths:     paths,
+			Truncated: truncated,
+		})
+	}
+}
+
+func diagnosticsHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		kinds := make(map[analyzer.DiagnosticKind]bool)
+		if kindsStr, ok := request.Params.Arguments["kind"].(string); ok {
+			for _, kind := range splitList(kindsStr, ",") {
+				if !slices.Contains(analyzer.DiagnosticKinds, analyzer.DiagnosticKind(kind)) {
+					return newToolResultError(fmt.Sprintf("invalid kind %q", kind)), nil
+				}
+				kinds[analyzer.DiagnosticKind(kind)] = true
+			}
+		}
+		component, _ := request.Params.Arguments["component"].(string)
+
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+
+		diagnostics := []analyzer.Diagnostic{}
+		for _, d := range arch.Diagnostics {
+			if len(kinds) > 0 && !kinds[d.Kind] {
+				continue
+			}
+			if component != "" && d.Component != component {
+				continue
+			}
+			diagnostics = append(diagnostics, d)
+		}
+
+		return jsonResult(struct {
+			Counts      map[analyzer.DiagnosticKind]int `json:"counts"`
+			Diagnostics []analyzer.Diagnostic           `json:"diagnostics"`
+		}{Counts: arch.DiagnosticCounts(), Diagnostics: diagnostics})
+	}
+}
+
+func explainComponentHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, ok := request.Params.Arguments["component"].(string)
+		if !ok || name == "" {
+			return newToolResultError("component is required"), nil
+		}
+
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+
+		explanations := arch.Explain(name)
+		if len(explanations) == 0 {
+			return newToolResultError(fmt.Sprintf("struct %q not found", name)), nil
+		}
+		return jsonResult(explanations)
+	}
+}
+
+// analyzeRepo analyzes the repository named by the repo_path argument,
+// reporting progress if the client asked for it.
+func analyzeRepo(ctx context.Context, cache *analyzer.Cache, request mcp.CallToolRequest) (*analyzer.Architecture, error) {
+	repoPath, ok := request.Params.Arguments["repo_path"].(string)
+	if !ok || repoPath == "" {
+		return nil, fmt.Errorf("repo_path is required")
+	}
+
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("repository path does not exist: %s", repoPath)
+	}
+
+	progress := newProgressNotifier(ctx, request, 1)
+	arch, err := cache.Analyze(progress.withAnalysis(ctx), repoPath, buildConfig(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze repository: %v", err)
+	}
+	if arch, err = selectModule(arch, request); err != nil {
+		return nil, err
+	}
+	return selectGenerated(arch, request)
+}
+
+// buildConfig returns the build configuration given by the build_tags, goos
+// and goarch arguments, or nil if none is given.
+func buildConfig(request mcp.CallToolRequest) *analyzer.BuildConfig {
+	tags, _ := request.Params.Arguments["build_tags"].(string)
+	goos, _ := request.Params.Arguments["goos"].(string)
+	goarch, _ := request.Params.Arguments["goarch"].(string)
+	if tags == "" && goos == "" && goarch == "" {
+		return nil
+	}
+	return &analyzer.BuildConfig{GOOS: goos, GOARCH: goarch, Tags: splitList(tags, ",")}
+}
+
+// selectModule narrows an architecture to the module named by the module
+// argument, if there is one.
+func selectModule(arch *analyzer.Architecture, request mcp.CallToolRequest) (*analyzer.Architecture, error) {
+	module, _ := request.Params.Arguments["module"].(string)
+	if module == "" {
+		return arch, nil
+	}
+	return arch.ForModule(module)
+}
+
+// selectGenerated shows, hides or collapses generated components as the
+// generated argument asks.
+func selectGenerated(arch *analyzer.Architecture, request mcp.CallToolRequest) (*analyzer.Architecture, error) {
+	mode, _ := request.Params.Arguments["generated"].(string)
+	return arch.WithGenerated(analyzer.GeneratedMode(mode))
+}
+
+// analyzeComponent analyzes the repository and looks up the component named by
+// the given argument. On failure it returns the tool error to report.
+func analyzeComponent(ctx context.Context, cache *analyzer.Cache, request mcp.CallToolRequest, argument string) (*analyzer.Architecture, *analyzer.Component, *mcp.CallToolResult) {
+	name, ok := request.Params.Arguments[argument].(string)
+	if !ok || name == "" {
+		return nil, nil, newToolResultError(fmt.Sprintf("%s is required", argument))
+	}
+
+	arch, err := analyzeRepo(ctx, cache, request)
+	if err != nil {
+		return nil, nil, newToolResultError(err.Error())
+	}
+
+	comp := arch.Component(name)
+	if comp == nil {
+		return nil, nil, newToolResultError(fmt.Sprintf("component %q not found", name))
+	}
+	return arch, comp, nil
+}
+
+func jsonResult(v any) (*mcp.CallToolResult, error) {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return newToolResultError(fmt.Sprintf("failed to encode result: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// routeSummary is a route with its pipeline spelled out, as list_routes returns it.
+type routeSummary struct {
+	analyzer.Route
+	Pipeline string `json:"pipeline"`
+}
+
+func listRoutesHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		prefix, _ := request.Params.Arguments["path"].(string)
+		middleware, _ := request.Params.Arguments["middleware"].(string)
+
+		routes := []routeSummary{}
+		for _, r := range arch.Routes {
+			if !strings.HasPrefix(r.Path, prefix) {
+				continue
+			}
+			if middleware != "" && !slices.ContainsFunc(r.Middleware, func(s analyzer.RouteStep) bool {
+				return s.Name == middleware || s.Component == middleware
+			}) {
+				continue
+			}
+			routes = append(routes, routeSummary{Route: r, Pipeline: r.Pipeline()})
+		}
+
+		return jsonResult(routes)
+	}
+}
+
+// moduleSummary is a module as list_modules returns it.
+type moduleSummary struct {
+	analyzer.Module
+	Components int `json:"components"`
+}
+
+// crossModuleDependency is a dependency between components in different modules.
+type crossModuleDependency struct {
+	From       string `json:"from"`
+	FromModule string `json:"fromModule"`
+	To         string `json:"to"`
+	ToModule   string `json:"toModule"`
+}
+
+func listModulesHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+
+		counts := make(map[string]int)
+		for _, comp := range arch.Components {
+			counts[comp.Module]++
+		}
+		modules := []moduleSummary{}
+		for _, m := range arch.Modules {
+			modules = append(modules, moduleSummary{Module: m, Components: counts[m.Path]})
+		}
+
+		cross := []crossModuleDependency{}
+		for _, comp := range arch.Components {
+			for _, e := range comp.Edges {
+				if !e.CrossModule {
+					continue
+				}
+				cross = append(cross, crossModuleDependency{From: comp.Name, FromModule: comp.Module, To: e.Target, ToModule: e.TargetModule})
+			}
+		}
+
+		return jsonResult(struct {
+			Workspace    bool                    `json:"workspace"`
+			Modules      []moduleSummary         `json:"modules"`
+			Dependencies []crossModuleDependency `json:"crossModuleDependencies"`
+		}{Workspace: arch.Workspace, Modules: modules, Dependencies: cross})
+	}
+}
+
+func listContractsHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		kind, _ := request.Params.Arguments["kind"].(string)
+		if kind != "" && !slices.Contains(analyzer.ContractKinds, analyzer.ContractKind(kind)) {
+			return newToolResultError(fmt.Sprintf("invalid kind %q", kind)), nil
+		}
+
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+
+		contracts := []analyzer.Contract{}
+		for _, c := range arch.Contracts {
+			if kind == "" || string(c.Kind) == kind {
+				contracts = append(contracts, c)
+			}
+		}
+		return jsonResult(contracts)
+	}
+}
+
+func apiSurfaceHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arch, err := analyzeRepo(ctx, cache, request)
+		if err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		return jsonResult(arch.API)
+	}
+}
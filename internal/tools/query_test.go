@@ -0,0 +1,265 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// writeRepo creates a repository from a map of slash-separated paths to file contents.
+func writeRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return root
+}
+
+// ordersRepo is a handler over two services sharing a store, so that there
+// are three paths from the handler to the store:
+//
+//	OrderHandler → OrderService → OrderStore
+//	OrderHandler → AuditService → OrderStore
+//	OrderHandler → OrderService → AuditService → OrderStore
+var ordersRepo = map[string]string{
+	"internal/handler/order.go": `package handler
+
+type OrderService interface{ Place() }
+
+type AuditService interface{ Record() }
+
+type OrderHandler struct {
+	orders OrderService
+	audit  AuditService
+}
+`,
+	"internal/service/order.go": `package service
+
+type OrderStore interface{ Save() }
+
+type AuditService interface{ Record() }
+
+type OrderService struct {
+	store OrderStore
+	audit AuditService
+}
+`,
+	"internal/service/audit.go": `package service
+
+type AuditService struct {
+	store OrderStore
+}
+`,
+	"internal/store/order.go": `package store
+
+type OrderStore struct{}
+
+func (s OrderStore) Save() {}
+`,
+}
+
+// callTool calls a tool handler with the given arguments, and returns the
+// text of its result and whether it is an error.
+func callTool(t *testing.T, handler server.ToolHandlerFunc, arguments map[string]any) (string, bool) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments
+	result, err := handler(t.Context(), request)
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if len(result.Content) != 1 {
+		t.Fatalf("result = %+v, want one content", result)
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("content = %+v, want text", result.Content[0])
+	}
+	return text.Text, result.IsError
+}
+
+// callToolJSON calls a tool handler that should succeed and decodes its
+// result into v.
+func callToolJSON(t *testing.T, handler server.ToolHandlerFunc, arguments map[string]any, v any) {
+	t.Helper()
+	text, isError := callTool(t, handler, arguments)
+	if isError {
+		t.Fatalf("arguments %v: error %q", arguments, text)
+	}
+	if err := json.Unmarshal([]byte(text), v); err != nil {
+		t.Fatalf("Failed to decode %s: %v", text, err)
+	}
+}
+
+// wantToolError checks that a tool call fails with a message containing want.
+func wantToolError(t *testing.T, handler server.ToolHandlerFunc, arguments map[string]any, want string) {
+	t.Helper()
+	text, isError := callTool(t, handler, arguments)
+	if !isError || !strings.Contains(text, want) {
+		t.Errorf("arguments %v: result %q (error %v), want an error containing %q", arguments, text, isError, want)
+	}
+}
+
+func TestListComponentsTool(t *testing.T) {
+	repo := writeRepo(t, ordersRepo)
+	handler := listComponentsHandler(analyzer.NewCache(""))
+
+	var all []componentSummary
+	callToolJSON(t, handler, map[string]any{"repo_path": repo}, &all)
+	counts := make(map[string][2]int)
+	for _, c := range all {
+		counts[c.Name] = [2]int{c.Dependencies, c.DependedBy}
+	}
+	want := map[string][2]int{"OrderHandler": {2, 0}, "OrderService": {2, 1}, "AuditService": {1, 2}, "OrderStore": {0, 2}}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("dependencies and dependents = %v, want %v", counts, want)
+	}
+
+	names := func(arguments map[string]any) []string {
+		arguments["repo_path"] = repo
+		var components []componentSummary
+		callToolJSON(t, handler, arguments, &components)
+		var names []string
+		for _, c := range components {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+	if got := names(map[string]any{"type": "service, repository", "name": "order"}); !reflect.DeepEqual(got, []string{"OrderService", "OrderStore"}) {
+		t.Errorf("order services and repositories = %v", got)
+	}
+	if got := names(map[string]any{"package": "internal/store"}); !reflect.DeepEqual(got, []string{"OrderStore"}) {
+		t.Errorf("components in internal/store = %v", got)
+	}
+
+	wantToolError(t, handler, map[string]any{"repo_path": repo, "type": "controller"}, `invalid type "controller"`)
+	wantToolError(t, handler, map[string]any{}, "repo_path is required")
+	wantToolError(t, handler, map[string]any{"repo_path": filepath.Join(repo, "missing")}, "does not exist")
+}
+
+func TestGetComponentTool(t *testing.T) {
+	repo := writeRepo(t, ordersRepo)
+	handler := getComponentHandler(analyzer.NewCache(""))
+
+	var detail analyzer.ComponentDetail
+	callToolJSON(t, handler, map[string]any{"repo_path": repo, "component": "AuditService"}, &detail)
+	if detail.Name != "AuditService" || detail.Type != analyzer.ComponentService ||
+		!reflect.DeepEqual(detail.Dependencies, []string{"OrderStore"}) ||
+		!reflect.DeepEqual(detail.DependedBy, []string{"OrderHandler", "OrderService"}) {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	wantToolError(t, handler, map[string]any{"repo_path": repo}, "component is required")
+	wantToolError(t, handler, map[string]any{"repo_path": repo, "component": "Nope"}, `component "Nope" not found`)
+}
+
+func TestNeighborsTools(t *testing.T) {
+	repo := writeRepo(t, ordersRepo)
+	cache := analyzer.NewCache("")
+
+	type neighbors struct {
+		Component string           `json:"component"`
+		Depth     int              `json:"depth"`
+		Results   []neighborResult `json:"results"`
+	}
+	distances := func(n neighbors) map[string]int {
+		d := make(map[string]int)
+		for _, r := range n.Results {
+			d[r.Name] = r.Distance
+		}
+		return d
+	}
+
+	var deps neighbors
+	callToolJSON(t, neighborsHandler(cache, false), map[string]any{"repo_path": repo, "component": "OrderHandler", "depth": float64(2)}, &deps)
+	if want := map[string]int{"OrderService": 1, "AuditService": 1, "OrderStore": 2}; deps.Depth != 2 || !reflect.DeepEqual(distances(deps), want) {
+		t.Errorf("dependencies = %+v, want %v", deps, want)
+	}
+
+	var dependents neighbors
+	callToolJSON(t, neighborsHandler(cache, true), map[string]any{"repo_path": repo, "component": "OrderStore"}, &dependents)
+	if want := map[string]int{"OrderService": 1, "AuditService": 1}; dependents.Depth != 1 || !reflect.DeepEqual(distances(dependents), want) {
+		t.Errorf("dependents = %+v, want %v", dependents, want)
+	}
+	if dependents.Results[0].Type != analyzer.ComponentService || dependents.Results[0].Package != "service" {
+		t.Errorf("dependent = %+v, want a service in package service", dependents.Results[0])
+	}
+
+	wantToolError(t, neighborsHandler(cache, false), map[string]any{"repo_path": repo, "component": "Nope"}, `component "Nope" not found`)
+}
+
+func TestFindPathsTool(t *testing.T) {
+	repo := writeRepo(t, ordersRepo)
+	handler := findPathsHandler(analyzer.NewCache(""))
+
+	type paths struct {
+		From      string     `json:"from"`
+		To        string     `json:"to"`
+		Paths     [][]string `json:"paths"`
+		Truncated bool       `json:"truncated"`
+	}
+	var all paths
+	callToolJSON(t, handler, map[string]any{"repo_path": repo, "from": "OrderHandler", "to": "OrderStore"}, &all)
+	if all.From != "OrderHandler" || all.To != "OrderStore" || len(all.Paths) != 3 || all.Truncated {
+		t.Errorf("paths = %+v, want all three", all)
+	}
+
+	// The shortest paths are kept, and the result says there are more
+	var limited paths
+	callToolJSON(t, handler, map[string]any{"repo_path": repo, "from": "OrderHandler", "to": "OrderStore", "max_paths": float64(2)}, &limited)
+	if len(limited.Paths) != 2 || !limited.Truncated || len(limited.Paths[0]) != 3 || len(limited.Paths[1]) != 3 {
+		t.Errorf("paths = %+v, want the two shortest, truncated", limited)
+	}
+
+	var exact paths
+	callToolJSON(t, handler, map[string]any{"repo_path": repo, "from": "OrderHandler", "to": "OrderStore", "max_paths": float64(3)}, &exact)
+	if len(exact.Paths) != 3 || exact.Truncated {
+		t.Errorf("paths = %+v, want all three, not truncated", exact)
+	}
+
+	var none paths
+	callToolJSON(t, handler, map[string]any{"repo_path": repo, "from": "OrderStore", "to": "OrderHandler"}, &none)
+	if none.Paths == nil || len(none.Paths) != 0 {
+		t.Errorf("paths = %+v, want an empty list against the dependencies", none)
+	}
+
+	wantToolError(t, handler, map[string]any{"repo_path": repo, "to": "OrderStore"}, "from is required")
+	wantToolError(t, handler, map[string]any{"repo_path": repo, "from": "OrderHandler", "to": "Nope"}, `component "Nope" not found`)
+}
+
+func TestExplainAndDiagnosticsTools(t *testing.T) {
+	repo := writeRepo(t, ordersRepo)
+	cache := analyzer.NewCache("")
+
+	var explanations []analyzer.Explanation
+	callToolJSON(t, explainComponentHandler(cache), map[string]any{"repo_path": repo, "component": "OrderStore"}, &explanations)
+	if len(explanations) != 1 || !explanations[0].Component || explanations[0].Type != analyzer.ComponentRepository {
+		t.Errorf("explanations = %+v, want OrderStore as a repository", explanations)
+	}
+	wantToolError(t, explainComponentHandler(cache), map[string]any{"repo_path": repo, "component": "Nope"}, `struct "Nope" not found`)
+
+	var diagnostics struct {
+		Counts      map[analyzer.DiagnosticKind]int `json:"counts"`
+		Diagnostics []analyzer.Diagnostic           `json:"diagnostics"`
+	}
+	callToolJSON(t, diagnosticsHandler(cache), map[string]any{"repo_path": repo, "kind": "parse-error"}, &diagnostics)
+	if diagnostics.Diagnostics == nil || len(diagnostics.Diagnostics) != 0 {
+		t.Errorf("diagnostics = %+v, want no parse errors", diagnostics)
+	}
+	wantToolError(t, diagnosticsHandler(cache), map[string]any{"repo_path": repo, "kind": "typo"}, `invalid kind "typo"`)
+	wantToolError(t, listContractsHandler(cache), map[string]any{"repo_path": repo, "kind": "gprc"}, `invalid kind "gprc"`)
+}
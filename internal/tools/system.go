@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+	"github.com/junkd0g/sharingan/internal/diagram"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func registerSystemTool(s *server.MCPServer, cache *analyzer.Cache) {
+	s.AddTool(mcp.NewTool("generate_system_diagram",
+		mcp.WithDescription(`Analyzes several Go service repositories and links them into one system topology, drawn as a C4 container diagram with each service as a node.
+
+Services are linked through:
+- gRPC: clients created with NewXClient(conn) to the service calling RegisterXServer
+- HTTP: URLs a service requests, with http.Get, http.NewRequest or a client's Get, Post and the like, to the service its host names exactly, e.g. http://payments-svc:8080 or http://payments.default.svc.cluster.local, or a host given in service_hosts, or else to the one service with a matching route. Other hosts become external systems
+- Messages: producers of a topic or subject to its consumers, e.g. kafka-go Writers and Readers, or NATS Publish and Subscribe
+
+gRPC clients and topics that nothing in the system serves are listed as unmatched.`),
+		mcp.WithString("repo_paths",
+			mcp.Required(),
+			mcp.Description("Comma- or newline-separated absolute paths to the service repositories, one per service"),
+		),
+		mcp.WithString("service_hosts",
+			mcp.Description("Comma- or newline-separated service=host pairs for services reached at host names other than their own, e.g. 'payments=api.payments.internal'. Services are named after their module path, or else their directory"),
+		),
+		mcp.WithString("format",
+			mcp.Description("'html' (default) writes an interactive report, 'mermaid' returns a Mermaid C4Container diagram and 'json' the system as JSON"),
+		),
+		mcp.WithString("output_path",
+			mcp.Description("The output path for the HTML file. Defaults to ./system.html in the first repository"),
+		),
+		mcp.WithString("title",
+			mcp.Description("Custom title for the diagram. Defaults to 'System Topology'"),
+		),
+		mcp.WithString("theme",
+			mcp.Description("Report theme, as for generate_architecture_diagram. Defaults to 'dark'"),
+		),
+		buildOptions,
+	), systemHandler(cache))
+}
+
+func systemHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pathsStr, _ := request.Params.Arguments["repo_paths"].(string)
+		repoPaths := splitList(pathsStr, ",\n")
+		if len(repoPaths) == 0 {
+			return newToolResultError("repo_paths is required"), nil
+		}
+
+		format := "html"
+		if f, ok := request.Params.Arguments["format"].(string); ok && f != "" {
+			format = f
+		}
+		if format != "html" && format != "mermaid" && format != "json" {
+			return newToolResultError(fmt.Sprintf("invalid format %q: use 'html', 'mermaid' or 'json'", format)), nil
+		}
+
+		title := "System Topology"
+		if t, ok := request.Params.Arguments["title"].(string); ok && t != "" {
+			title = t
+		}
+
+		hosts := make(map[string][]string)
+		hostsStr, _ := request.Params.Arguments["service_hosts"].(string)
+		for _, pair := range splitList(hostsStr, ",\n") {
+			name, host, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(host) == "" {
+				return newToolResultError(fmt.Sprintf("invalid service_hosts entry %q: use service=host", pair)), nil
+			}
+			name = strings.TrimSpace(name)
+			hosts[name] = append(hosts[name], strings.TrimSpace(host))
+		}
+
+		build := buildConfig(request)
+		var services []analyzer.Service
+		for _, repoPath := range repoPaths {
+			if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+				return newToolResultError(fmt.Sprintf("repository path does not exist: %s", repoPath)), nil
+			}
+			arch, err := cache.Analyze(ctx, repoPath, build)
+			if err != nil {
+				return newToolResultError(fmt.Sprintf("failed to analyze repository %s: %v", repoPath, err)), nil
+			}
+			svc := analyzer.NewService(repoPath, arch)
+			svc.Hosts = hosts[svc.Name]
+			services = append(services, svc)
+		}
+		sys := analyzer.LinkServices(services)
+
+		switch format {
+		case "json":
+			return jsonResult(sys)
+		case "mermaid":
+			return mcp.NewToolResultText(diagram.SystemMermaid(sys, title)), nil
+		}
+
+		outputPath := filepath.Join(repoPaths[0], "system.html")
+		if op, ok := request.Params.Arguments["output_path"].(string); ok && op != "" {
+			outputPath = op
+		}
+
+		config := diagram.DefaultConfig()
+		config.Title = title
+		config.Description = fmt.Sprintf("%d services and how they connect", len(sys.Services))
+		if theme, ok := request.Params.Arguments["theme"].(string); ok && theme != "" {
+			config.Theme = theme
+		}
+		if _, err := diagram.LoadTheme(config.Theme); err != nil {
+			return newToolResultError(fmt.Sprintf("invalid theme: %v", err)), nil
+		}
+
+		if err := diagram.GenerateSystemHTML(sys, outputPath, config); err != nil {
+			return newToolResultError(fmt.Sprintf("failed to generate report: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(buildSystemSummary(sys, outputPath)), nil
+	}
+}
+
+func buildSystemSummary(sys *analyzer.System, outputPath string) string {
+	summary := fmt.Sprintf("System topology generated!\n\nOutput: %s\n\nServices:\n", outputPath)
+	for _, svc := range sys.Services {
+		summary += fmt.Sprintf("  - %s: %d components, %d routes\n", svc.Name, svc.Components, svc.Routes)
+	}
+
+	if len(sys.External) > 0 {
+		summary += "\nExternal systems:\n"
+		for _, ext := range sys.External {
+			summary += fmt.Sprintf("  - %s\n", ext.Name)
+		}
+	}
+
+	summary += fmt.Sprintf("\nConnections: %d\n", len(sys.Links))
+	for _, l := range sys.Links {
+		summary += fmt.Sprintf("  - %s → %s (%s, %d via)\n", l.From, l.To, l.Kind, len(l.Via))
+	}
+
+	if len(sys.Unmatched) > 0 {
+		summary += fmt.Sprintf("\nUnmatched: %d gRPC clients or topics nothing in the system serves\n", len(sys.Unmatched))
+	}
+	return summary
+}
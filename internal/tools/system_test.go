@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/junkd0g/sharingan/internal/analyzer"
+)
+
+func TestSystemTool(t *testing.T) {
+	orders := writeRepo(t, map[string]string{
+		"go.mod": "module example.com/orders/v2\n",
+		"main.go": `package main
+
+func main() {
+	_ = pb.NewPaymentServiceClient(conn)
+	http.Post("https://ledger.acme.internal/entries", "application/json", nil)
+	http.Get("https://api.stripe.com/v1/charges")
+}
+`,
+	})
+	payments := writeRepo(t, map[string]string{
+		"go.mod": "module example.com/payments\n",
+		"main.go": `package main
+
+func main() {
+	pb.RegisterPaymentServiceServer(srv, &server{})
+}
+`,
+	})
+	handler := systemHandler(analyzer.NewCache(""))
+	repoPaths := orders + "\n" + payments
+
+	var sys analyzer.System
+	callToolJSON(t, handler, map[string]any{
+		"repo_paths":    repoPaths,
+		"service_hosts": "payments=ledger.acme.internal",
+		"format":        "json",
+	}, &sys)
+	var services, links []string
+	for _, svc := range sys.Services {
+		services = append(services, svc.Name)
+	}
+	for _, l := range sys.Links {
+		links = append(links, fmt.Sprintf("%s → %s %s", l.From, l.To, l.Kind))
+	}
+	if !reflect.DeepEqual(services, []string{"orders", "payments"}) {
+		t.Errorf("services = %v, want orders without its major version, and payments", services)
+	}
+	want := []string{"orders → api.stripe.com http", "orders → payments grpc", "orders → payments http"}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("links = %q, want %q", links, want)
+	}
+	if len(sys.Services[1].Hosts) != 1 || sys.Services[1].Hosts[0] != "ledger.acme.internal" {
+		t.Errorf("payments hosts = %v, want the one given", sys.Services[1].Hosts)
+	}
+
+	text, isError := callTool(t, handler, map[string]any{"repo_paths": repoPaths, "format": "mermaid"})
+	if isError || !strings.HasPrefix(text, "C4Container") || !strings.Contains(text, "api.stripe.com") {
+		t.Errorf("mermaid = %q, want a C4Container diagram", text)
+	}
+
+	output := filepath.Join(t.TempDir(), "system.html")
+	text, isError = callTool(t, handler, map[string]any{"repo_paths": repoPaths, "output_path": output})
+	if isError || !strings.Contains(text, output) {
+		t.Errorf("summary = %q, want the report written to %s", text, output)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("report not written: %v", err)
+	}
+
+	wantToolError(t, handler, map[string]any{}, "repo_paths is required")
+	wantToolError(t, handler, map[string]any{"repo_paths": repoPaths, "format": "svg"}, `invalid format "svg"`)
+	wantToolError(t, handler, map[string]any{"repo_paths": repoPaths, "service_hosts": "payments"}, `invalid service_hosts entry "payments"`)
+	wantToolError(t, handler, map[string]any{"repo_paths": repoPaths, "theme": "neon"}, "invalid theme")
+	wantToolError(t, handler, map[string]any{"repo_paths": filepath.Join(orders, "missing")}, "does not exist")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/junkd0g/sharingan/internal/analyzer"
@@ -13,30 +14,43 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// Register registers all tools with the MCP server.
-func Register(s *server.MCPServer) {
-	registerArchDiagramTool(s)
+// Register registers all tools with the MCP server. Repositories are analyzed
+// through cache, which the handlers close over and which may be shared with
+// other registrations.
+func Register(s *server.MCPServer, cache *analyzer.Cache) {
+	registerArchDiagramTool(s, cache)
+	registerQueryTools(s, cache)
+	registerImpactTool(s, cache)
+	registerSystemTool(s, cache)
 }
 
-func registerArchDiagramTool(s *server.MCPServer) {
+func registerArchDiagramTool(s *server.MCPServer, cache *analyzer.Cache) {
 	tool := mcp.NewTool("generate_architecture_diagram",
 		mcp.WithDescription(`Generates an interactive HTML architecture report from a Go service repository.
 
 The report includes various visualizations powered by ECharts:
-- Architecture Graph: Interactive force-directed graph showing components and dependencies
+- Architecture Graph: Interactive force-directed graph with search, type/package filters and an N-hop focus mode, shareable via the URL
 - Components Pie: Pie chart showing component distribution by type
 - Dependencies Bar: Bar chart showing top components by dependency count
 - Layer Flow: Sankey diagram showing data flow between architectural layers
-- Dependency Matrix: Heatmap showing which components depend on which
+- Dependency Matrix: Dependency structure matrix, partitioned and grouped by package or layer
 - Components Table: Detailed table of all components
+- Component Panel: Click a graph node or table row to see its doc comment, methods and source links
 - Package Tree: Tree visualization of package structure
+- Routes: HTTP routes with the middleware each passes through
+- API Surface: OpenAPI operations with their handlers, unimplemented operations and undocumented routes
+- Contracts: gRPC services, OpenAPI servers and sqlc queries from generated code, with their implementations
 - Stats Cards: Key metrics overview
+- Diagnostics: Files that failed to parse, skipped directories, excluded structs and dropped dependencies
 
 You can customize which widgets appear in the report using the 'widgets' parameter.`),
 		mcp.WithString("repo_path",
 			mcp.Required(),
 			mcp.Description("The absolute path to the Go service repository to analyze"),
 		),
+		moduleOption,
+		buildOptions,
+		generatedOption,
 		mcp.WithString("output_path",
 			mcp.Description("The output path for the HTML file. Defaults to ./architecture.html in the repo"),
 		),
@@ -47,7 +61,21 @@ You can customize which widgets appear in the report using the 'widgets' paramet
 			mcp.Description("Custom description shown below the title"),
 		),
 		mcp.WithString("theme",
-			mcp.Description("Color theme: 'dark' (default) or 'light'"),
+			mcp.Description(`Report theme. One of:
+- a built-in theme: 'dark' (default), 'light', 'high-contrast' or 'colorblind'
+- the path to a JSON theme file
+- an inline JSON theme, e.g. {"name": "acme", "base": "light", "palette": {"handler": "#E4002B"}, "variables": {"--accent-gradient": "linear-gradient(90deg, #E4002B, #222)"}, "fonts": {"body": "Inter, sans-serif"}, "echarts": {}}
+
+Custom themes inherit anything they leave out from 'base' (default 'dark').`),
+		),
+		mcp.WithString("matrix_group_by",
+			mcp.Description("How the dependency matrix groups components: 'package' (default) or 'layer'"),
+		),
+		mcp.WithString("source_url_template",
+			mcp.Description("URL template linking components to a repository browser, with {commit}, {path} and {line} placeholders, e.g. 'https://github.com/acme/orders/blob/{commit}/{path}#L{line}'. Defaults to one derived from the origin remote on GitHub, GitLab or Bitbucket"),
+		),
+		mcp.WithString("template_dir",
+			mcp.Description("Optional directory of *.tmpl files (html/template) overriding built-in templates such as 'header', 'theme' (the stylesheet) or 'widget/components_table'. Colours and fonts are set with 'theme' instead"),
 		),
 		mcp.WithString("widgets",
 			mcp.Description(`Comma-separated list of widgets to include. Available widgets:
@@ -56,74 +84,106 @@ You can customize which widgets appear in the report using the 'widgets' paramet
 - components_pie: Component type distribution
 - dependencies_bar: Top dependencies chart
 - layer_flow: Sankey diagram of layer dependencies
-- dependency_matrix: Heatmap of dependencies (max 20 components)
+- dependency_matrix: Dependency structure matrix with collapsible groups
 - components_table: Detailed component table
 - package_tree: Package structure tree
+- routes: HTTP routes with their middleware pipelines
+- api: OpenAPI operations matched to routes and handlers
+- contracts: Contracts from generated code and the components implementing them
+- diagnostics: What the analysis skipped, dropped or failed on
 
 Default: all widgets. Example: "stats_cards,architecture_graph,components_table"`),
 		),
 	)
 
-	s.AddTool(tool, archDiagramHandler)
+	s.AddTool(tool, archDiagramHandler(cache))
 }
 
-func archDiagramHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	repoPath, ok := request.Params.Arguments["repo_path"].(string)
-	if !ok {
-		return newToolResultError("repo_path is required"), nil
-	}
+func archDiagramHandler(cache *analyzer.Cache) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		repoPath, ok := request.Params.Arguments["repo_path"].(string)
+		if !ok {
+			return newToolResultError("repo_path is required"), nil
+		}
 
-	// Validate repo path exists
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-		return newToolResultError(fmt.Sprintf("repository path does not exist: %s", repoPath)), nil
-	}
+		// Validate repo path exists
+		if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+			return newToolResultError(fmt.Sprintf("repository path does not exist: %s", repoPath)), nil
+		}
 
-	// Determine output path
-	outputPath := filepath.Join(repoPath, "architecture.html")
-	if op, ok := request.Params.Arguments["output_path"].(string); ok && op != "" {
-		outputPath = op
-	}
+		// Determine output path
+		outputPath := filepath.Join(repoPath, "architecture.html")
+		if op, ok := request.Params.Arguments["output_path"].(string); ok && op != "" {
+			outputPath = op
+		}
 
-	// Build config
-	config := diagram.DefaultConfig()
+		// Build config
+		config := diagram.DefaultConfig()
 
-	if title, ok := request.Params.Arguments["title"].(string); ok && title != "" {
-		config.Title = title
-	}
+		if title, ok := request.Params.Arguments["title"].(string); ok && title != "" {
+			config.Title = title
+		}
 
-	if desc, ok := request.Params.Arguments["description"].(string); ok && desc != "" {
-		config.Description = desc
-	}
+		if desc, ok := request.Params.Arguments["description"].(string); ok && desc != "" {
+			config.Description = desc
+		}
 
-	if theme, ok := request.Params.Arguments["theme"].(string); ok && theme != "" {
-		if theme == "light" || theme == "dark" {
+		if theme, ok := request.Params.Arguments["theme"].(string); ok && theme != "" {
 			config.Theme = theme
 		}
-	}
 
-	if widgetsStr, ok := request.Params.Arguments["widgets"].(string); ok && widgetsStr != "" {
-		config.Widgets = parseWidgets(widgetsStr)
-	}
+		theme, err := diagram.LoadTheme(config.Theme)
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("invalid theme: %v", err)), nil
+		}
 
-	// Analyze the repository
-	arch, err := analyzer.Analyze(repoPath)
-	if err != nil {
-		return newToolResultError(fmt.Sprintf("failed to analyze repository: %v", err)), nil
-	}
+		if groupBy, ok := request.Params.Arguments["matrix_group_by"].(string); ok && groupBy != "" {
+			if groupBy != diagram.MatrixGroupByPackage && groupBy != diagram.MatrixGroupByLayer {
+				return newToolResultError(fmt.Sprintf("invalid matrix_group_by %q: use 'package' or 'layer'", groupBy)), nil
+			}
+			config.MatrixGroupBy = groupBy
+		}
 
-	if len(arch.Components) == 0 {
-		return newToolResultError("no architectural components found in the repository"), nil
-	}
+		if urlTemplate, ok := request.Params.Arguments["source_url_template"].(string); ok && urlTemplate != "" {
+			config.SourceURLTemplate = urlTemplate
+		}
 
-	// Generate the HTML report
-	if err := diagram.GenerateHTML(arch, outputPath, config); err != nil {
-		return newToolResultError(fmt.Sprintf("failed to generate report: %v", err)), nil
-	}
+		if dir, ok := request.Params.Arguments["template_dir"].(string); ok && dir != "" {
+			config.TemplateDir = dir
+		}
+
+		if widgetsStr, ok := request.Params.Arguments["widgets"].(string); ok && widgetsStr != "" {
+			config.Widgets = parseWidgets(widgetsStr)
+		}
+
+		// Analyze the repository, then render: two stages after parsing
+		progress := newProgressNotifier(ctx, request, 2)
+		arch, err := cache.Analyze(progress.withAnalysis(ctx), repoPath, buildConfig(request))
+		if err != nil {
+			return newToolResultError(fmt.Sprintf("failed to analyze repository: %v", err)), nil
+		}
+		if arch, err = selectModule(arch, request); err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+		if arch, err = selectGenerated(arch, request); err != nil {
+			return newToolResultError(err.Error()), nil
+		}
+
+		if len(arch.Components) == 0 {
+			return newToolResultError("no architectural components found in the repository"), nil
+		}
+
+		// Generate the HTML report
+		progress.stage(2, "Rendering report")
+		if err := diagram.GenerateHTML(arch, outputPath, config); err != nil {
+			return newToolResultError(fmt.Sprintf("failed to generate report: %v", err)), nil
+		}
 
-	// Build summary
-	summary := buildSummary(arch, outputPath, config)
+		// Build summary
+		summary := buildSummary(arch, outputPath, config, theme.Name)
 
-	return mcp.NewToolResultText(summary), nil
+		return mcp.NewToolResultText(summary), nil
+	}
 }
 
 func parseWidgets(widgetsStr string) []diagram.WidgetType {
@@ -136,6 +196,10 @@ func parseWidgets(widgetsStr string) []diagram.WidgetType {
 		"dependency_matrix":  diagram.WidgetDependencyMatrix,
 		"components_table":   diagram.WidgetComponentsTable,
 		"package_tree":       diagram.WidgetPackageTree,
+		"routes":             diagram.WidgetRoutes,
+		"api":                diagram.WidgetAPI,
+		"contracts":          diagram.WidgetContracts,
+		"diagnostics":        diagram.WidgetDiagnostics,
 	}
 
 	var widgets []diagram.WidgetType
@@ -166,19 +230,24 @@ func newToolResultError(message string) *mcp.CallToolResult {
 	}
 }
 
-func buildSummary(arch *analyzer.Architecture, outputPath string, config diagram.HTMLConfig) string {
+func buildSummary(arch *analyzer.Architecture, outputPath string, config diagram.HTMLConfig, themeName string) string {
 	counts := make(map[analyzer.ComponentType]int)
 	for _, comp := range arch.Components {
 		counts[comp.Type]++
 	}
 
-	summary := fmt.Sprintf("Interactive architecture report generated!\n\nOutput: %s\nTheme: %s\n\nComponents found:\n", outputPath, config.Theme)
+	summary := fmt.Sprintf("Interactive architecture report generated!\n\nOutput: %s\nTheme: %s\n", outputPath, themeName)
+	if arch.Build != nil {
+		summary += fmt.Sprintf("Build: %s\n", arch.Build)
+	}
+	summary += "\nComponents found:\n"
 
 	// List components in layer order
 	typeLabels := []struct {
 		Type  analyzer.ComponentType
 		Label string
 	}{
+		{analyzer.ComponentMiddleware, "Middleware (Transport)"},
 		{analyzer.ComponentHandler, "Handlers (Transport)"},
 		{analyzer.ComponentService, "Services (Business Logic)"},
 		{analyzer.ComponentAdapter, "Adapters (External)"},
@@ -191,6 +260,10 @@ func buildSummary(arch *analyzer.Architecture, outputPath string, config diagram
 		}
 	}
 
+	if len(arch.Modules) > 1 {
+		summary += fmt.Sprintf("\nModules: %d (use list_modules for the dependencies between them)\n", len(arch.Modules))
+	}
+
 	// List dependency connections
 	depCount := 0
 	for _, deps := range arch.Dependencies {
@@ -200,6 +273,44 @@ func buildSummary(arch *analyzer.Architecture, outputPath string, config diagram
 		summary += fmt.Sprintf("\nDependencies: %d connections\n", depCount)
 	}
 
+	if len(arch.Routes) > 0 {
+		summary += fmt.Sprintf("\nRoutes: %d (use list_routes for their middleware)\n", len(arch.Routes))
+	}
+
+	if len(arch.Generated) > 0 {
+		var generators []string
+		for _, g := range arch.Generated {
+			if !slices.Contains(generators, g.Generator) {
+				generators = append(generators, g.Generator)
+			}
+		}
+		summary += fmt.Sprintf("\nGenerated files: %d (%s)\n", len(arch.Generated), strings.Join(generators, ", "))
+	}
+	if len(arch.API.Specs) > 0 {
+		summary += fmt.Sprintf("API: %d operations in %d OpenAPI specs, %d not implemented, %d undocumented routes (use get_api_surface)\n",
+			len(arch.API.Operations), len(arch.API.Specs), len(arch.API.Unimplemented), len(arch.API.Undocumented))
+	}
+	if len(arch.Contracts) > 0 {
+		summary += fmt.Sprintf("Contracts: %d (use list_contracts for their implementations)\n", len(arch.Contracts))
+	}
+
+	// Summarise diagnostics, listing the errors that may hide components
+	if len(arch.Diagnostics) > 0 {
+		counts := arch.DiagnosticCounts()
+		summary += "\nDiagnostics:\n"
+		for _, kind := range analyzer.DiagnosticKinds {
+			if counts[kind] > 0 {
+				summary += fmt.Sprintf("  - %s: %d\n", kind, counts[kind])
+			}
+		}
+		for _, d := range arch.Diagnostics {
+			if d.Kind == analyzer.DiagnosticParseError || d.Kind == analyzer.DiagnosticReadError {
+				summary += fmt.Sprintf("  ! %s: %s\n", d.FilePath, d.Message)
+			}
+		}
+		summary += "Use get_diagnostics for details.\n"
+	}
+
 	// List included widgets
 	summary += "\nIncluded visualizations:\n"
 	for _, w := range config.Widgets {